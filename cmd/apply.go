@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/drduker/xstrapolate/pkg/spec"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply -f cluster.yaml",
+	Short: "Create or update a cluster from a ClusterSpec file",
+	Long: `Bring a cluster to the state described by a ClusterSpec file.
+
+The file is validated against the ClusterSpec JSON schema (print it with
+--print-schema), then compared with the running cluster:
+
+  + create cluster         the cluster does not exist yet
+  + spec.nodePools[name]   a node pool is added
+  ~ ...count: 2 -> 3       a node pool is resized in place
+  ! ...                    the change needs the cluster to be recreated
+
+Network, bootstrap, GitOps and tag settings are used when the cluster is
+created and are not compared afterwards. The spec wins over the config file
+and the active context.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if printSchema, _ := cmd.Flags().GetBool("print-schema"); printSchema {
+			_, err := os.Stdout.Write(spec.Schema())
+			return err
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		filename, _ := cmd.Flags().GetString("filename")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if filename == "" {
+			return fmt.Errorf("a cluster spec must be given with -f")
+		}
+
+		clusterSpec, err := spec.Load(filename)
+		if err != nil {
+			return err
+		}
+		name, desired := clusterSpec.Metadata.Name, clusterSpec.Spec
+		if cmd.Flags().Changed("cloud") && viper.GetString("provider") != desired.Provider {
+			return fmt.Errorf("--cloud %s conflicts with spec.provider %s", viper.GetString("provider"), desired.Provider)
+		}
+
+		conf, err := config.Load()
+		if err != nil {
+			return err
+		}
+		clusterSpec.ApplyTo(conf)
+		if desired.Region != "" {
+			// As explicit as --region, so AWS_REGION does not override it
+			viper.Set("region", desired.Region)
+			viper.Set("location", desired.Region)
+		}
+
+		manager, err := newClusterManagerFromConfig(ctx, desired.Provider, conf)
+		if err != nil {
+			return err
+		}
+
+		current, err := manager.GetCluster(ctx, name)
+		if err != nil {
+			if !errors.Is(err, cloud.ErrNotFound) {
+				return fmt.Errorf("failed to get cluster: %w", err)
+			}
+			current = nil
+		}
+
+		plan := spec.Diff(clusterSpec, current)
+
+		// The plan is the result of a dry run; otherwise it is progress
+		var planOut io.Writer = os.Stdout
+		if format != "" {
+			if dryRun {
+				return writeOutput(format, plan)
+			}
+			planOut = os.Stderr
+		}
+		fmt.Fprintf(planOut, "Cluster '%s' (%s %s):\n%s", name, desired.Provider, desired.Type, plan)
+
+		if immutable := plan.Immutable(); len(immutable) > 0 {
+			return fmt.Errorf("%d change(s) cannot be made in place; tear the cluster down and apply again, or revert them in the spec", len(immutable))
+		}
+		if plan.Empty() || dryRun {
+			if format != "" && current != nil {
+				return writeOutput(format, current)
+			}
+			return nil
+		}
+		if !force && !confirm("Apply these changes?") {
+			fmt.Fprintln(planOut, "Nothing changed")
+			return nil
+		}
+
+		if plan.Create {
+			skipPreflight, _ := cmd.Flags().GetBool("skip-preflight")
+			cluster, err := createCluster(ctx, manager, name, desired.Type, conf, skipPreflight)
+			if err != nil {
+				return err
+			}
+			if format != "" {
+				return writeOutput(format, cluster)
+			}
+			return nil
+		}
+
+		pools, ok := manager.(cloud.NodePoolManager)
+		if !ok {
+			return fmt.Errorf("node pools of %s clusters cannot be changed", desired.Provider)
+		}
+		for _, change := range plan.Changes {
+			switch {
+			case change.AddPool != nil:
+				err = pools.CreateNodePool(ctx, name, *change.AddPool)
+			case change.ScalePool != "":
+				err = pools.ScaleNodePool(ctx, name, change.ScalePool, change.Count)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		slog.Info("✅ Cluster matches the spec", "cluster", name)
+
+		if format != "" {
+			cluster, err := manager.GetCluster(ctx, name)
+			if err != nil {
+				return fmt.Errorf("failed to get cluster: %w", err)
+			}
+			return writeOutput(format, cluster)
+		}
+		return nil
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringP("filename", "f", "", "ClusterSpec file to apply")
+	applyCmd.Flags().Bool("dry-run", false, "show the plan without changing anything")
+	applyCmd.Flags().Bool("force", false, "apply without asking for confirmation")
+	applyCmd.Flags().Bool("skip-preflight", false, "create without checking quotas and IAM permissions first")
+	applyCmd.Flags().Bool("print-schema", false, "print the ClusterSpec JSON schema and exit")
+	addOutputFlag(applyCmd)
+}
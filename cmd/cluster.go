@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/drduker/xstrapolate/pkg/config"
 	"github.com/drduker/xstrapolate/pkg/k8s"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,60 +29,119 @@ var createCmd = &cobra.Command{
 Supports:
 - EKS clusters on AWS (--cloud aws --type eks)
 - AKS clusters on Azure (--cloud azure --type aks)
-- Single node clusters (--type single-node) - fastest option, private subnet + SSM access`,
+- Single node clusters (--type single-node) - fastest option, private subnet + SSM access
+- Multi-node k3s clusters on AWS (--type k3s) - 1 or 3 servers on embedded etcd
+  plus agents across two availability zones, behind an internal load balancer
+- Local clusters in Docker (--cloud local) - kind, k3d or k3s, no cloud account
+  needed; Flux is installed as for managed clusters
+
+Before anything is created, the service quotas (VPCs, interface endpoints,
+elastic IPs, on-demand vCPUs) and the IAM permissions the cluster type needs
+are checked; a failed check aborts the create. --skip-preflight skips this.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
 		clusterName := args[0]
-		cloudProvider := viper.GetString("cloud")
+		cloudProvider := viper.GetString("provider")
 		clusterType := viper.GetString("type")
 
 		if cloudProvider == "" {
-			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure)")
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("Creating %s cluster '%s' on %s...\n", clusterType, clusterName, cloudProvider)
+		if clusterType == "single-node" && viper.GetInt32("node-count") > 1 {
+			slog.Warn("single-node clusters start with one node; add k3s agents afterwards with 'cluster scale'", "node-count", viper.GetInt32("node-count"))
+		}
+		if clusterType == "k3s" && viper.GetInt32("node-count") > 1 {
+			slog.Warn("k3s clusters are sized with --servers and --agents", "node-count", viper.GetInt32("node-count"))
+		}
 
-		var manager cloud.ClusterManager
-		var err error
+		slog.Info("Creating cluster", "name", clusterName, "type", clusterType, "cloud", cloudProvider)
 
-		switch cloudProvider {
-		case "aws":
-			manager, err = cloud.NewAWSManager()
-		case "azure":
-			manager, err = cloud.NewAzureManager()
-		default:
-			return fmt.Errorf("unsupported cloud provider: %s", cloudProvider)
+		conf, err := config.Load()
+		if err != nil {
+			return err
 		}
-
+		manager, err := newClusterManagerFromConfig(ctx, cloudProvider, conf)
 		if err != nil {
-			return fmt.Errorf("failed to initialize cloud manager: %w", err)
+			return err
 		}
 
-		cluster, err := manager.CreateCluster(clusterName, clusterType)
+		skipPreflight, _ := cmd.Flags().GetBool("skip-preflight")
+		cluster, err := createCluster(ctx, manager, clusterName, clusterType, conf, skipPreflight)
 		if err != nil {
-			return fmt.Errorf("failed to create cluster: %w", err)
+			return err
 		}
 
-		fmt.Printf("Cluster '%s' created successfully!\n", cluster.Name)
-		fmt.Printf("Kubeconfig: %s\n", cluster.KubeconfigPath)
+		if format != "" {
+			return writeOutput(format, cluster)
+		}
+		return nil
+	},
+}
 
-		// For single-node clusters, Flux is installed via user data
-		if clusterType == "single-node" {
-			fmt.Println("✅ Cluster provisioning started!")
-			fmt.Println("Flux will be installed automatically during startup.")
-			fmt.Println("Crossplane will be installed via Flux GitOps from the official repo.")
+// createCluster runs the preflight checks, creates the cluster and, for
+// managed and local clusters, installs Flux and points it at the GitOps
+// repository. Single-node and k3s clusters in a cloud do both in their
+// bootstrap script.
+func createCluster(ctx context.Context, manager cloud.ClusterManager, clusterName, clusterType string, conf *config.Config, skipPreflight bool) (*cloud.ClusterInfo, error) {
+	if preflighter, ok := manager.(cloud.Preflighter); ok && !skipPreflight {
+		if err := preflighter.Preflight(ctx, clusterType); err != nil {
+			slog.Info("💡 Nothing was created. Fix the above, or run 'xstrapolate doctor' for details")
+			return nil, err
+		}
+	}
+
+	if reporter, ok := manager.(cloud.CostReporter); ok {
+		estimate, err := reporter.EstimateCost(clusterType)
+		if err != nil {
+			slog.Warn("could not estimate cost", "err", err)
 		} else {
-			// For managed clusters (EKS/AKS), install manually
-			fmt.Println("Installing Flux...")
-			if err := k8s.InstallFlux(cluster.KubeconfigPath); err != nil {
-				return fmt.Errorf("failed to install Flux: %w", err)
-			}
+			fmt.Fprint(os.Stderr, estimate)
+		}
+	}
 
-			fmt.Println("✅ Cluster setup complete!")
-			fmt.Println("💡 Install Crossplane via Flux by applying your GitOps configuration.")
+	cluster, err := manager.CreateCluster(ctx, clusterName, clusterType)
+	if err != nil {
+		if ctx.Err() != nil {
+			offerRollback(manager, clusterName, ctx.Err())
 		}
-		return nil
-	},
+		return nil, fmt.Errorf("failed to create cluster: %w", err)
+	}
+
+	slog.Info("Cluster created successfully!", "name", cluster.Name)
+	slog.Info("Kubeconfig", "path", cluster.KubeconfigPath)
+
+	// For single-node and k3s clusters in a cloud, Flux is installed via user data
+	if cluster.Provider != "local" && (clusterType == "single-node" || clusterType == "k3s") {
+		slog.Info("✅ Cluster provisioning started!")
+		slog.Info("Flux will be installed automatically during startup.")
+		slog.Info("Crossplane will be installed via Flux GitOps from the official repo.")
+		return cluster, nil
+	}
+
+	// For managed (EKS/AKS) and local clusters, install manually
+	gitops := conf.GitOps
+	if err := k8s.InstallFlux(ctx, cluster.KubeconfigPath, conf.Components.Flux); err != nil {
+		return nil, fmt.Errorf("failed to install Flux: %w", err)
+	}
+	if gitops.URL != "" {
+		if err := k8s.ConfigureGitOps(ctx, cluster.KubeconfigPath, gitops); err != nil {
+			return nil, fmt.Errorf("failed to configure GitOps: %w", err)
+		}
+	}
+
+	slog.Info("✅ Cluster setup complete!")
+	if gitops.URL == "" {
+		slog.Info("💡 Install Crossplane via Flux by applying your GitOps configuration.")
+	}
+	return cluster, nil
 }
 
 var teardownCmd = &cobra.Command{
@@ -94,64 +159,157 @@ This will delete:
 - VPCs
 - IAM roles and instance profiles (created by xstrapolate)
 
+Network resources are deleted in dependency order, independent ones in
+parallel, retrying while AWS still reports dependencies. If anything cannot be
+deleted, the remaining resources are listed and the command exits non-zero;
+run it again or use 'xstrapolate gc' to finish the cleanup.
+
 WARNING: This action is irreversible and will delete all data in the cluster.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
 		clusterName := args[0]
-		cloudProvider := viper.GetString("cloud")
+		cloudProvider := viper.GetString("provider")
 		force := viper.GetBool("force")
 
 		if cloudProvider == "" {
-			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure)")
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
 		}
 
 		if !force {
-			fmt.Printf("⚠️  WARNING: This will permanently delete cluster '%s' and ALL associated resources!\n", clusterName)
-			fmt.Println("Use --force flag to confirm deletion")
+			slog.Warn("⚠️  This will permanently delete the cluster and ALL associated resources!", "cluster", clusterName)
+			slog.Info("Use --force flag to confirm deletion")
 			return fmt.Errorf("teardown cancelled - use --force to confirm")
 		}
 
-		fmt.Printf("🗑️  Tearing down %s cluster '%s'...\n", cloudProvider, clusterName)
-
-		var manager cloud.ClusterManager
-		var err error
-
-		switch cloudProvider {
-		case "aws":
-			manager, err = cloud.NewAWSManager()
-		case "azure":
-			manager, err = cloud.NewAzureManager()
-		default:
-			return fmt.Errorf("unsupported cloud provider: %s", cloudProvider)
-		}
+		slog.Info("🗑️  Tearing down cluster...", "name", clusterName, "cloud", cloudProvider)
 
+		manager, err := newClusterManager(ctx, cloudProvider)
 		if err != nil {
-			return fmt.Errorf("failed to initialize cloud manager: %w", err)
+			return err
 		}
 
-		err = manager.DeleteCluster(clusterName)
+		err = manager.DeleteCluster(ctx, clusterName)
 		if err != nil {
 			return fmt.Errorf("failed to teardown cluster: %w", err)
 		}
 
-		fmt.Printf("✅ Cluster '%s' and all resources successfully deleted!\n", clusterName)
+		slog.Info("✅ Cluster and all resources successfully deleted!", "cluster", clusterName)
 		return nil
 	},
 }
 
+// offerRollback asks whether to delete what an interrupted create left
+// behind. The rollback gets its own context so a second Ctrl-C aborts it.
+func offerRollback(manager cloud.ClusterManager, clusterName string, cause error) {
+	slog.Warn("⚠️  Create stopped; some resources may already exist", "cluster", clusterName, "cause", cause)
+	if !confirm("Roll back the resources created so far?") {
+		slog.Info("Kept them. Remove later with: xstrapolate cluster teardown " + clusterName + " --force")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("↩️  Rolling back cluster...", "cluster", clusterName)
+	if err := manager.DeleteCluster(ctx, clusterName); err != nil {
+		slog.Warn("rollback incomplete", "err", err)
+		slog.Info("Run 'xstrapolate gc' to find anything left behind")
+		return
+	}
+	slog.Info("✅ Rollback complete")
+}
+
+func newClusterManager(ctx context.Context, cloudProvider string) (cloud.ClusterManager, error) {
+	conf, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return newClusterManagerFromConfig(ctx, cloudProvider, conf)
+}
+
+// newClusterManagerFromConfig builds a manager from an already loaded, and
+// possibly amended, config.
+func newClusterManagerFromConfig(ctx context.Context, cloudProvider string, conf *config.Config) (cloud.ClusterManager, error) {
+	var manager cloud.ClusterManager
+	var err error
+
+	switch cloudProvider {
+	case "aws":
+		manager, err = cloud.NewAWSManager(ctx, conf)
+	case "azure":
+		manager, err = cloud.NewAzureManager(conf)
+	case "local":
+		manager, err = cloud.NewLocalManager(conf)
+	default:
+		return nil, fmt.Errorf("unsupported cloud provider: %s", cloudProvider)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cloud manager: %w", err)
+	}
+	return manager, nil
+}
+
 func init() {
 	rootCmd.AddCommand(clusterCmd)
 	clusterCmd.AddCommand(createCmd)
 	clusterCmd.AddCommand(teardownCmd)
 
-	createCmd.Flags().String("type", "single-node", "cluster type (eks, aks, single-node)")
+	createCmd.Flags().String("type", "single-node", "cluster type (eks, aks, single-node, k3s)")
 	createCmd.Flags().String("region", "", "cloud region")
-	createCmd.Flags().String("node-count", "1", "number of nodes")
+	createCmd.Flags().String("node-count", "1", "number of nodes in the default EKS node pool; change it later with 'cluster scale'")
+	createCmd.Flags().Int32("servers", 0, "k3s servers of a k3s cluster: 1, or 3 for an HA control plane (default 1)")
+	createCmd.Flags().Int32("agents", 0, "k3s agents of a k3s cluster; change it later with 'cluster scale'")
+	createCmd.Flags().String("runtime", "", "runtime of local clusters: kind, k3d or k3s (k3s in Docker); default the first installed")
+	createCmd.Flags().String("ttl", "", "delete the cluster after this long, e.g. 8h (enforced by 'cluster reap'; instances also power off, spot instances terminate, EKS clusters are only tagged)")
+	createCmd.Flags().String("auto-stop", "", "stop single-node and k3s instances daily at this time, e.g. \"19:00 America/New_York\" (not supported for EKS)")
+	createCmd.Flags().String("instance-type", "", "EC2 instance type for single-node and k3s clusters (default t3.medium); Graviton types use arm64")
+	createCmd.Flags().Int32("root-volume-size", 0, "root volume size in GiB (default: AMI default)")
+	createCmd.Flags().String("root-volume-type", "", "root volume type, e.g. gp3")
+	createCmd.Flags().Bool("encrypt-root-volume", false, "encrypt the root volume")
+	createCmd.Flags().String("root-volume-kms-key", "", "KMS key ID or ARN for root volume encryption (implies --encrypt-root-volume)")
+	createCmd.Flags().Bool("spot", false, "launch single-node instances on spot capacity, falling back to on-demand")
+	createCmd.Flags().String("spot-max-price", "", "maximum hourly spot price in USD (default: on-demand price)")
+	createCmd.Flags().String("spot-snapshot-bucket", "", "S3 bucket that receives a k3s state snapshot on spot interruption")
+	createCmd.Flags().String("k3s-version", "", "k3s release to install on single-node and k3s clusters, e.g. v1.29.4+k3s1 (default latest stable)")
+	createCmd.Flags().StringSlice("k3s-disable", nil, "k3s packaged components to disable (traefik, servicelb, local-storage, metrics-server, coredns)")
+	createCmd.Flags().String("cluster-cidr", "", "k3s pod network CIDR")
+	createCmd.Flags().String("service-cidr", "", "k3s service network CIDR")
+	createCmd.Flags().StringSlice("tls-san", nil, "extra hostnames or IPs for the k3s API server certificate")
+	createCmd.Flags().String("k3s-datastore", "", "k3s datastore: sqlite or etcd (embedded etcd)")
+	createCmd.Flags().StringSlice("registry-mirror", nil, "registry mirror as registry=endpoint, e.g. docker.io=https://mirror.example.com")
+	createCmd.Flags().StringArray("tag", nil, "tag added to every created resource as key=value (repeatable)")
+	createCmd.Flags().Bool("skip-preflight", false, "create without checking quotas and IAM permissions first")
+	addOutputFlag(createCmd)
 
 	teardownCmd.Flags().Bool("force", false, "force teardown without confirmation")
 
 	viper.BindPFlag("type", createCmd.Flags().Lookup("type"))
 	viper.BindPFlag("region", createCmd.Flags().Lookup("region"))
 	viper.BindPFlag("node-count", createCmd.Flags().Lookup("node-count"))
+	viper.BindPFlag("servers", createCmd.Flags().Lookup("servers"))
+	viper.BindPFlag("agents", createCmd.Flags().Lookup("agents"))
+	viper.BindPFlag("runtime", createCmd.Flags().Lookup("runtime"))
+	viper.BindPFlag("ttl", createCmd.Flags().Lookup("ttl"))
+	viper.BindPFlag("auto-stop", createCmd.Flags().Lookup("auto-stop"))
+	viper.BindPFlag("instance-type", createCmd.Flags().Lookup("instance-type"))
+	viper.BindPFlag("root-volume-size", createCmd.Flags().Lookup("root-volume-size"))
+	viper.BindPFlag("root-volume-type", createCmd.Flags().Lookup("root-volume-type"))
+	viper.BindPFlag("encrypt-root-volume", createCmd.Flags().Lookup("encrypt-root-volume"))
+	viper.BindPFlag("root-volume-kms-key", createCmd.Flags().Lookup("root-volume-kms-key"))
+	viper.BindPFlag("spot", createCmd.Flags().Lookup("spot"))
+	viper.BindPFlag("spot-max-price", createCmd.Flags().Lookup("spot-max-price"))
+	viper.BindPFlag("spot-snapshot-bucket", createCmd.Flags().Lookup("spot-snapshot-bucket"))
+	viper.BindPFlag("k3s-version", createCmd.Flags().Lookup("k3s-version"))
+	viper.BindPFlag("k3s-disable", createCmd.Flags().Lookup("k3s-disable"))
+	viper.BindPFlag("cluster-cidr", createCmd.Flags().Lookup("cluster-cidr"))
+	viper.BindPFlag("service-cidr", createCmd.Flags().Lookup("service-cidr"))
+	viper.BindPFlag("tls-san", createCmd.Flags().Lookup("tls-san"))
+	viper.BindPFlag("k3s-datastore", createCmd.Flags().Lookup("k3s-datastore"))
+	viper.BindPFlag("registry-mirror", createCmd.Flags().Lookup("registry-mirror"))
+	viper.BindPFlag("tag", createCmd.Flags().Lookup("tag"))
 	viper.BindPFlag("force", teardownCmd.Flags().Lookup("force"))
 }
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/drduker/xstrapolate/pkg/k8s"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var componentsCmd = &cobra.Command{
+	Use:   "components",
+	Short: "Manage Flux, Crossplane and provider versions in a cluster",
+}
+
+var componentsListCmd = &cobra.Command{
+	Use:   "list [cluster-name]",
+	Short: "Show installed and available component versions",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		components, _, err := planComponents(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if format != "" {
+			return writeOutput(format, components)
+		}
+		return printComponents(os.Stdout, components)
+	},
+}
+
+var componentsUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [cluster-name]",
+	Short: "Upgrade Flux, Crossplane and providers in a cluster",
+	Long: `Upgrade the components xstrapolate installs in a cluster, in a safe order:
+the Flux controllers first, then Crossplane, then each Crossplane provider.
+Each one must be healthy again before the next is started, and the upgrade
+stops at the first failure.
+
+Components move to the version pinned under 'components' in the config file,
+or else to the latest release. Crossplane moves one minor version at a time.
+Components that Flux manages from Git are listed but not changed; upgrade
+them in the repository instead. With --pin the versions installed are
+written back to the config file, so new clusters and later runs use them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		pin, _ := cmd.Flags().GetBool("pin")
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if pin && viper.ConfigFileUsed() == "" {
+			return fmt.Errorf("--pin needs a config file; create one with 'xstrapolate init'")
+		}
+
+		components, run, err := planComponents(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		// The plan is the result of a dry run; otherwise it is progress
+		var planOut io.Writer = os.Stdout
+		if format != "" {
+			if dryRun {
+				return writeOutput(format, components)
+			}
+			planOut = os.Stderr
+		}
+		if err := printComponents(planOut, components); err != nil {
+			return err
+		}
+
+		var upgrades []k8s.Component
+		for _, c := range components {
+			if c.Target != "" {
+				upgrades = append(upgrades, c)
+			}
+		}
+		if len(upgrades) == 0 {
+			fmt.Fprintln(planOut, "Nothing to upgrade")
+			if format != "" {
+				return writeOutput(format, components)
+			}
+			return nil
+		}
+		if dryRun {
+			return nil
+		}
+		if !force && !confirm(fmt.Sprintf("Upgrade %d component(s)?", len(upgrades))) {
+			fmt.Fprintln(planOut, "Nothing changed")
+			return nil
+		}
+
+		var pins config.ComponentsConfig
+		for _, c := range upgrades {
+			if err := k8s.UpgradeComponent(ctx, run, c); err != nil {
+				return err
+			}
+			pinComponent(&pins, c)
+		}
+
+		slog.Info("🔍 Verifying component health...")
+		components, err = k8s.InspectComponents(ctx, run)
+		if err != nil {
+			return err
+		}
+		unhealthy := 0
+		for _, c := range components {
+			if !c.Healthy {
+				slog.Warn("component is not healthy", "component", c.Name, "version", c.Installed)
+				unhealthy++
+			}
+		}
+
+		if pin {
+			if err := config.PinComponents(viper.ConfigFileUsed(), pins); err != nil {
+				return fmt.Errorf("failed to pin component versions: %w", err)
+			}
+			slog.Info("📌 Pinned component versions", "config", viper.ConfigFileUsed())
+		}
+
+		if format != "" {
+			if err := writeOutput(format, components); err != nil {
+				return err
+			}
+		}
+		if unhealthy > 0 {
+			return fmt.Errorf("%d component(s) are not healthy after the upgrade", unhealthy)
+		}
+		slog.Info("✅ Components upgraded", "cluster", args[0])
+		return nil
+	},
+}
+
+// planComponents finds how to reach the cluster, lists its components and
+// sets their target versions from the config pins.
+func planComponents(ctx context.Context, clusterName string) ([]k8s.Component, k8s.Runner, error) {
+	cloudProvider := viper.GetString("provider")
+	if cloudProvider == "" {
+		return nil, nil, fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+	}
+
+	conf, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	manager, err := newClusterManagerFromConfig(ctx, cloudProvider, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := manager.GetCluster(ctx, clusterName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	run, err := clusterRunner(manager, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	components, err := k8s.InspectComponents(ctx, run)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(components) == 0 {
+		return nil, nil, fmt.Errorf("neither Flux nor Crossplane is installed in cluster '%s'", clusterName)
+	}
+	k8s.PlanComponents(ctx, components, conf.Components)
+	return components, run, nil
+}
+
+// clusterRunner runs scripts on a node of clusters the manager reaches that
+// way, and on this machine against the kubeconfig of managed clusters.
+func clusterRunner(manager cloud.ClusterManager, info *cloud.ClusterInfo) (k8s.Runner, error) {
+	if shell, ok := manager.(cloud.ClusterShell); ok && info.Type != "eks" {
+		return func(ctx context.Context, script []string) (string, error) {
+			return shell.RunOnCluster(ctx, info.Name, script)
+		}, nil
+	}
+
+	if info.KubeconfigPath == "" {
+		return nil, fmt.Errorf("no kubeconfig found for cluster '%s'", info.Name)
+	}
+	if _, err := os.Stat(info.KubeconfigPath); err != nil {
+		if info.Type == "eks" {
+			return nil, fmt.Errorf("kubeconfig %s not found; create it with: aws eks update-kubeconfig --region %s --name %s --kubeconfig %s",
+				info.KubeconfigPath, info.Region, info.Name, info.KubeconfigPath)
+		}
+		return nil, fmt.Errorf("kubeconfig %s not found: %w", info.KubeconfigPath, err)
+	}
+	return k8s.LocalRunner(info.KubeconfigPath), nil
+}
+
+func pinComponent(pins *config.ComponentsConfig, c k8s.Component) {
+	switch c.Kind {
+	case k8s.KindFlux:
+		pins.Flux = c.Target
+	case k8s.KindCrossplane:
+		pins.Crossplane = c.Target
+	case k8s.KindProvider:
+		if pins.Providers == nil {
+			pins.Providers = map[string]string{}
+		}
+		pins.Providers[c.Name] = c.Target
+	}
+}
+
+func printComponents(out io.Writer, components []k8s.Component) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tINSTALLED\tAVAILABLE\tTARGET\tHEALTHY\tNOTE")
+	for _, c := range components {
+		target := c.Target
+		if target == "" {
+			target = "-"
+		} else if c.Pinned {
+			target += " (pinned)"
+		}
+		available := c.Available
+		if available == "" {
+			available = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", c.Name, c.Installed, available, target, c.Healthy, c.Note)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(componentsCmd)
+	componentsCmd.AddCommand(componentsListCmd)
+	componentsCmd.AddCommand(componentsUpgradeCmd)
+
+	addOutputFlag(componentsListCmd)
+	componentsUpgradeCmd.Flags().Bool("dry-run", false, "show the plan without changing anything")
+	componentsUpgradeCmd.Flags().Bool("force", false, "upgrade without asking for confirmation")
+	componentsUpgradeCmd.Flags().Bool("pin", false, "write the upgraded versions to the config file")
+	addOutputFlag(componentsUpgradeCmd)
+}
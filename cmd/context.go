@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage named contexts such as dev, staging or sandbox-eu",
+	Long: `A context is a named set of defaults kept under contexts in the config file:
+the cloud, region, AWS profile, cluster type, tags and network ranges.
+
+The context in current_context applies to every command, or --context picks
+one for a single command. Context settings win over the top-level config and
+flags win over both, so --cloud is optional when the context sets a cloud.`,
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use [context-name]",
+	Short: "Make a context the current one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		conf, err := loadConfigForContexts()
+		if err != nil {
+			return err
+		}
+		if _, ok := conf.Contexts[name]; !ok {
+			return fmt.Errorf("context '%s' not found in config (have: %v)", name, conf.ContextNames())
+		}
+
+		path := viper.ConfigFileUsed()
+		if path == "" {
+			return fmt.Errorf("no config file found; run 'xstrapolate init' first")
+		}
+		if err := config.SetCurrentContext(path, name); err != nil {
+			return err
+		}
+
+		fmt.Printf("Switched to context '%s'\n", name)
+		return nil
+	},
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured contexts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		conf, err := loadConfigForContexts()
+		if err != nil {
+			return err
+		}
+		if format != "" {
+			return writeOutput(format, conf.Contexts)
+		}
+
+		if len(conf.Contexts) == 0 {
+			fmt.Println("No contexts configured")
+			return nil
+		}
+		current := activeContextName(conf)
+		fmt.Printf("%-2s %-20s %-8s %-16s %-16s %s\n", "", "NAME", "CLOUD", "REGION", "PROFILE", "TYPE")
+		for _, name := range conf.ContextNames() {
+			ctx := conf.Contexts[name]
+			marker := ""
+			if name == current {
+				marker = "*"
+			}
+			fmt.Printf("%-2s %-20s %-8s %-16s %-16s %s\n", marker, name, ctx.Cloud, ctx.Region, ctx.Profile, ctx.Type)
+		}
+		return nil
+	},
+}
+
+var contextShowCmd = &cobra.Command{
+	Use:   "show [context-name]",
+	Short: "Show a context, by default the active one",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		conf, err := loadConfigForContexts()
+		if err != nil {
+			return err
+		}
+
+		name := activeContextName(conf)
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if name == "" {
+			return fmt.Errorf("no context is active; run 'xstrapolate context use <name>' or pass a name")
+		}
+		ctx, ok := conf.Contexts[name]
+		if !ok {
+			return fmt.Errorf("context '%s' not found in config (have: %v)", name, conf.ContextNames())
+		}
+
+		if format == "" {
+			fmt.Printf("# context %s\n", name)
+			format = "yaml"
+		}
+		return writeOutput(format, ctx)
+	},
+}
+
+// loadConfigForContexts reads the config without applying the active
+// context, so a missing or mistyped current_context can still be fixed.
+func loadConfigForContexts() (*config.Config, error) {
+	var conf config.Config
+	if err := viper.Unmarshal(&conf); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return &conf, nil
+}
+
+// activeContextName returns --context or else current_context.
+func activeContextName(conf *config.Config) string {
+	if name := viper.GetString("context"); name != "" {
+		return name
+	}
+	return conf.CurrentContext
+}
+
+// applyContext makes the active context's cloud and cluster type the
+// defaults of the command. The rest of the context is applied by
+// config.Load when the cloud manager is built.
+func applyContext(cmd *cobra.Command) error {
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+	name, active, err := conf.ActiveContext()
+	if err != nil || active == nil {
+		return err
+	}
+
+	if active.Cloud != "" && !cmd.Flags().Changed("cloud") {
+		viper.Set("provider", active.Cloud)
+	}
+	if active.Type != "" && cmd.Flags().Lookup("type") != nil && !cmd.Flags().Changed("type") {
+		viper.Set("type", active.Type)
+	}
+
+	slog.Debug("Using context", "context", name, "cloud", viper.GetString("provider"))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(contextCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextShowCmd)
+
+	addOutputFlag(contextListCmd)
+	addOutputFlag(contextShowCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var costCmd = &cobra.Command{
+	Use:   "cost [cluster-name]",
+	Short: "Show the actual spend of a cluster",
+	Long: `Show what a cluster has cost so far, broken down by service.
+
+Spend is read from AWS Cost Explorer for resources carrying the
+xstrapolate-cluster tag. The tag must be activated as a cost allocation tag in
+the Billing console, and Cost Explorer data lags by up to 24 hours.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		clusterName := args[0]
+		cloudProvider := viper.GetString("provider")
+		days, _ := cmd.Flags().GetInt("days")
+
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+		if days <= 0 {
+			return fmt.Errorf("--days must be positive")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		reporter, ok := manager.(cloud.CostReporter)
+		if !ok {
+			return fmt.Errorf("cost reporting is not supported for %s", cloudProvider)
+		}
+
+		report, err := reporter.ClusterCost(ctx, clusterName, days)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Cost of cluster '%s' from %s to %s:\n", clusterName,
+			report.Start.Format("2006-01-02"), report.End.Format("2006-01-02"))
+		for _, service := range report.Services() {
+			fmt.Printf("  %-50s $%10.2f\n", service, report.ByService[service])
+		}
+		fmt.Printf("  %-50s $%10.2f\n", "Total", report.Total)
+
+		if report.Total == 0 {
+			fmt.Println("💡 No tagged spend found. Make sure 'xstrapolate-cluster' is activated as a cost allocation tag.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(costCmd)
+
+	costCmd.Flags().Int("days", 30, "number of days to report")
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that clusters can be created from this machine",
+	Long: `Check the setup before creating a cluster:
+
+  config    the config file parses, has no unknown keys and valid settings
+  tools     flux, kubectl and helm are installed, plus the cloud CLIs, or
+            Docker with k3d or kind for local clusters
+  cloud     credentials work, the region has two availability zones, the
+            IAM permissions the cluster type needs are allowed (checked with
+            IAM policy simulation) and the VPC, elastic IP and vCPU service
+            quotas leave room for a new cluster
+  local     the Docker daemon answers and a local runtime is installed
+
+Every configured cloud is checked unless --cloud or the active context picks
+one. Checks that cannot run, e.g. because the caller may not read quotas, are
+reported as warnings. The command fails when any check fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		clusterType, _ := cmd.Flags().GetString("type")
+		if !cmd.Flags().Changed("type") && viper.GetString("type") != "" {
+			// Set by the active context
+			clusterType = viper.GetString("type")
+		}
+
+		var results []cloud.CheckResult
+		if path := viper.ConfigFileUsed(); path == "" {
+			results = append(results, cloud.CheckResult{Name: "config file", Status: cloud.CheckWarn, Detail: "none found; run 'xstrapolate init' to create one"})
+		} else if err := config.ValidateFile(path); err != nil {
+			results = append(results, cloud.CheckResult{Name: "config file", Status: cloud.CheckFail, Detail: err.Error()})
+		} else {
+			results = append(results, cloud.CheckResult{Name: "config file", Status: cloud.CheckPass, Detail: path})
+		}
+
+		conf, err := config.Load()
+		if err != nil {
+			results = append(results, cloud.CheckResult{Name: "config", Status: cloud.CheckFail, Detail: err.Error()})
+			return reportChecks(format, results)
+		}
+		results = append(results, cloud.CheckConfig(conf)...)
+
+		clouds := doctorClouds(conf)
+		results = append(results, checkTools(clouds)...)
+
+		if len(clouds) == 0 {
+			results = append(results, cloud.CheckResult{Name: "cloud", Status: cloud.CheckWarn, Detail: "no cloud configured; use --cloud or set provider"})
+		}
+		for _, provider := range clouds {
+			manager, err := newClusterManagerFromConfig(ctx, provider, conf)
+			if err != nil {
+				// The table has room for the error, not the advice after it
+				detail, _, _ := strings.Cut(err.Error(), "\n")
+				results = append(results, cloud.CheckResult{Name: provider + " credentials", Status: cloud.CheckFail, Detail: detail})
+				continue
+			}
+			if doctor, ok := manager.(cloud.Doctor); ok {
+				results = append(results, doctor.Diagnose(ctx, clusterType)...)
+			}
+		}
+
+		return reportChecks(format, results)
+	},
+}
+
+// doctorClouds returns the clouds to check: the one picked by --cloud, the
+// context or provider, or else every cloud the config or environment sets up.
+func doctorClouds(conf *config.Config) []string {
+	if provider := viper.GetString("provider"); provider != "" {
+		return []string{provider}
+	}
+
+	var clouds []string
+	aws := conf.Cloud.AWS
+	if aws.Region != "" || aws.Profile != "" || aws.AccessKeyID != "" ||
+		os.Getenv("AWS_PROFILE") != "" || os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		clouds = append(clouds, "aws")
+	}
+	if conf.Cloud.Azure.SubscriptionID != "" || os.Getenv("AZURE_SUBSCRIPTION_ID") != "" {
+		clouds = append(clouds, "azure")
+	}
+	if conf.Cloud.Local.Runtime != "" {
+		clouds = append(clouds, "local")
+	}
+	return clouds
+}
+
+// checkTools looks for the command line tools cluster creation runs.
+func checkTools(clouds []string) []cloud.CheckResult {
+	type tool struct {
+		name     string
+		required bool
+		purpose  string
+	}
+	tools := []tool{
+		{"flux", true, "installs Flux"},
+		{"kubectl", true, "installs Crossplane"},
+		{"helm", true, "installs Crossplane"},
+	}
+	for _, provider := range clouds {
+		switch provider {
+		case "aws":
+			tools = append(tools,
+				tool{"aws", false, "used by the printed SSM commands"},
+				tool{"session-manager-plugin", false, "needed to reach single-node clusters over SSM"})
+		case "azure":
+			tools = append(tools, tool{"az", false, "used to fetch AKS kubeconfigs"})
+		case "local":
+			tools = append(tools,
+				tool{"docker", true, "runs local clusters"},
+				tool{"k3d", false, "runs local clusters with --runtime k3d"},
+				tool{"kind", false, "runs local clusters with --runtime kind"})
+		}
+	}
+
+	var results []cloud.CheckResult
+	for _, t := range tools {
+		name := "tool " + t.name
+		path, err := exec.LookPath(t.name)
+		switch {
+		case err == nil:
+			results = append(results, cloud.CheckResult{Name: name, Status: cloud.CheckPass, Detail: path})
+		case t.required:
+			results = append(results, cloud.CheckResult{Name: name, Status: cloud.CheckFail, Detail: "not found in PATH; " + t.purpose})
+		default:
+			results = append(results, cloud.CheckResult{Name: name, Status: cloud.CheckWarn, Detail: "not found in PATH; " + t.purpose})
+		}
+	}
+	return results
+}
+
+// reportChecks prints the results as a table, or as json or yaml, and
+// returns an error when any check failed.
+func reportChecks(format string, results []cloud.CheckResult) error {
+	if format != "" {
+		if err := writeOutput(format, results); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tCHECK\tDETAIL")
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", result.Status, result.Name, result.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	failures := 0
+	for _, result := range results {
+		if result.Status == cloud.CheckFail {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().String("type", "single-node", "cluster type to check permissions and quotas for (eks, aks, single-node, k3s)")
+	addOutputFlag(doctorCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+)
+
+// Exit codes wrapper scripts can branch on; keep USAGE.md in sync.
+const (
+	ExitOK              = 0
+	ExitError           = 1
+	ExitCredentials     = 3
+	ExitQuotaExceeded   = 4
+	ExitNotFound        = 5
+	ExitAlreadyExists   = 6
+	ExitPartialTeardown = 7
+	ExitTimeout         = 124
+	ExitInterrupted     = 130
+)
+
+// ExitCode returns the process exit code for the error a command returned.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, cloud.ErrCredentials):
+		return ExitCredentials
+	case errors.Is(err, cloud.ErrQuotaExceeded):
+		return ExitQuotaExceeded
+	case errors.Is(err, cloud.ErrPartialTeardown):
+		return ExitPartialTeardown
+	case errors.Is(err, cloud.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, cloud.ErrAlreadyExists):
+		return ExitAlreadyExists
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	default:
+		return ExitError
+	}
+}
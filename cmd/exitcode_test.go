@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitError},
+		{"credentials", fmt.Errorf("failed to get caller identity: %w", cloud.ErrCredentials), ExitCredentials},
+		{"quota", fmt.Errorf("failed to create VPC: %w", cloud.ErrQuotaExceeded), ExitQuotaExceeded},
+		{"not found", fmt.Errorf("cluster dev: %w", cloud.ErrNotFound), ExitNotFound},
+		{"already exists", fmt.Errorf("cluster dev: %w", cloud.ErrAlreadyExists), ExitAlreadyExists},
+		{"partial teardown", &cloud.TeardownError{Remaining: []cloud.TeardownFailure{{ID: "vpc/vpc-123", Err: errors.New("boom")}}}, ExitPartialTeardown},
+		{"wrapped partial teardown", fmt.Errorf("failed to delete cluster: %w", &cloud.TeardownError{}), ExitPartialTeardown},
+		// A teardown that failed on a missing resource is still partial
+		{"partial teardown before not found", errors.Join(&cloud.TeardownError{}, cloud.ErrNotFound), ExitPartialTeardown},
+		{"timeout", fmt.Errorf("failed to wait for cluster: %w", context.DeadlineExceeded), ExitTimeout},
+		{"interrupted", fmt.Errorf("failed to create cluster: %w", context.Canceled), ExitInterrupted},
+		// The typed error names the cause better than the context
+		{"classified timeout", fmt.Errorf("failed: %w", errors.Join(context.DeadlineExceeded, cloud.ErrQuotaExceeded)), ExitQuotaExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Find and delete resources left behind by failed creates",
+	Long: `Scan for xstrapolate-managed resources that no live cluster uses and delete them.
+
+Teardown needs the name and region of a cluster and only deletes what is
+tagged with it. gc finds everything with the xstrapolate-managed tag instead:
+resources of clusters nobody tears down, elastic IPs and internet gateways a
+failed create allocated but never attached, since teardown only finds them
+through a VPC's NAT gateways and attachments, and the token keys and
+parameters an interrupted k3s teardown left. Node roles of deleted k3s
+clusters, and the shared IAM roles and instance profile once no cluster is left
+anywhere, are only included with --all-regions.
+
+Resources younger than --min-age are skipped because they may belong to a
+create that is still running.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cloudProvider := viper.GetString("provider")
+		regions, _ := cmd.Flags().GetStringSlice("region")
+		allRegions, _ := cmd.Flags().GetBool("all-regions")
+		minAge, _ := cmd.Flags().GetDuration("min-age")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+		if allRegions && len(regions) > 0 {
+			return fmt.Errorf("--region and --all-regions cannot be used together")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		collector, ok := manager.(cloud.GarbageCollector)
+		if !ok {
+			return fmt.Errorf("gc is not supported for %s", cloudProvider)
+		}
+
+		orphans, err := collector.FindOrphans(ctx, cloud.GCOptions{
+			Regions:    regions,
+			AllRegions: allRegions,
+			MinAge:     minAge,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan for orphaned resources: %w", err)
+		}
+
+		if len(orphans) == 0 {
+			fmt.Println("✅ No orphaned resources found")
+			return nil
+		}
+
+		printOrphans(orphans)
+
+		if !force && !confirm(fmt.Sprintf("Delete %d orphaned resource(s)?", len(orphans))) {
+			fmt.Println("Nothing deleted")
+			return nil
+		}
+
+		if err := collector.DeleteOrphans(ctx, orphans); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Deleted %d orphaned resource(s)\n", len(orphans))
+		return nil
+	},
+}
+
+// printOrphans lists orphaned resources grouped by region and kind.
+func printOrphans(orphans []cloud.OrphanResource) {
+	fmt.Printf("Found %d orphaned resource(s):\n", len(orphans))
+
+	lastRegion, lastKind := "-", ""
+	for _, orphan := range orphans {
+		if orphan.Region != lastRegion {
+			region := orphan.Region
+			if region == "" {
+				region = "global"
+			}
+			fmt.Printf("\n%s\n", region)
+			lastRegion, lastKind = orphan.Region, ""
+		}
+		if orphan.Kind != lastKind {
+			fmt.Printf("  %s\n", orphan.Kind)
+			lastKind = orphan.Kind
+		}
+
+		details := orphan.Name
+		if orphan.Cluster != "" {
+			details += fmt.Sprintf(" (cluster %s)", orphan.Cluster)
+		}
+		fmt.Printf("    %-28s %s\n", orphan.ID, strings.TrimSpace(details))
+	}
+	fmt.Println()
+}
+
+// confirm asks a yes/no question on the terminal; anything but y or yes is no.
+func confirm(question string) bool {
+	// The prompt goes to stderr with the other progress output
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().StringSlice("region", nil, "regions to scan (default: the configured region)")
+	gcCmd.Flags().Bool("all-regions", false, "scan every enabled region and include shared IAM roles")
+	gcCmd.Flags().Duration("min-age", time.Hour, "skip resources created more recently than this")
+	gcCmd.Flags().Bool("force", false, "delete without asking for confirmation")
+}
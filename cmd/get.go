@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get [cluster-name]",
+	Short: "Show a cluster and the resources behind it",
+	Long: `Show a cluster's type, status and endpoint, and the IDs of the cloud
+resources tagged with its name.
+
+Use -o json or -o yaml to print the result for scripts; progress messages go
+to stderr so stdout holds only the document.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		cloudProvider := viper.GetString("provider")
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		info, err := manager.GetCluster(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get cluster: %w", err)
+		}
+
+		if format != "" {
+			return writeOutput(format, info)
+		}
+		printClusterInfo(info)
+		return nil
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [cluster-name]",
+	Short: "Show whether a cluster is up and its bootstrap has finished",
+	Long: `Show a cluster's status. For running single-node clusters the bootstrap log
+is read through SSM to report which setup step the instance has reached.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		clusterName := args[0]
+		cloudProvider := viper.GetString("provider")
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		info, err := manager.GetCluster(ctx, clusterName)
+		if err != nil {
+			return fmt.Errorf("failed to get cluster: %w", err)
+		}
+
+		status := clusterStatus{ClusterInfo: *info}
+		if streamer, ok := manager.(cloud.LogStreamer); ok && (info.Type == "single-node" || info.Type == "k3s") && info.Status == "running" {
+			status.Bootstrap, err = readBootstrapStatus(ctx, streamer, clusterName)
+			if err != nil {
+				slog.Warn("could not read bootstrap progress", "err", err)
+			}
+		}
+
+		if format != "" {
+			return writeOutput(format, status)
+		}
+
+		fmt.Printf("%s (%s on %s): %s\n", info.Name, info.Type, info.Provider, info.Status)
+		if bootstrap := status.Bootstrap; bootstrap != nil {
+			switch bootstrap.State {
+			case "done":
+				fmt.Println("Bootstrap: complete")
+			case "failed":
+				fmt.Printf("Bootstrap: failed at step '%s'\n", bootstrap.Step)
+			default:
+				fmt.Printf("Bootstrap: step %d/%d %s\n", bootstrap.Current, bootstrap.Total, bootstrap.Step)
+			}
+		}
+		return nil
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the clusters created by xstrapolate",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		cloudProvider := viper.GetString("provider")
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		lister, ok := manager.(cloud.ClusterLister)
+		if !ok {
+			return fmt.Errorf("list is not supported for %s", cloudProvider)
+		}
+
+		clusters, err := lister.ListClusters(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list clusters: %w", err)
+		}
+
+		if format != "" {
+			return writeOutput(format, clusters)
+		}
+
+		if len(clusters) == 0 {
+			fmt.Println("No clusters found")
+			return nil
+		}
+		fmt.Printf("%-30s %-12s %-12s %-14s %s\n", "NAME", "TYPE", "STATUS", "REGION", "ENDPOINT")
+		for _, cluster := range clusters {
+			fmt.Printf("%-30s %-12s %-12s %-14s %s\n", cluster.Name, cluster.Type, cluster.Status, cluster.Region, cluster.Endpoint)
+		}
+		return nil
+	},
+}
+
+// clusterStatus is the status command's result: the cluster plus, for
+// single-node clusters, how far the bootstrap script has got.
+type clusterStatus struct {
+	cloud.ClusterInfo `yaml:",inline"`
+	Bootstrap         *bootstrapStatus `json:"bootstrap,omitempty" yaml:"bootstrap,omitempty"`
+}
+
+type bootstrapStatus struct {
+	// State is running, done or failed
+	State   string `json:"state" yaml:"state"`
+	Step    string `json:"step,omitempty" yaml:"step,omitempty"`
+	Current int    `json:"current,omitempty" yaml:"current,omitempty"`
+	Total   int    `json:"total,omitempty" yaml:"total,omitempty"`
+}
+
+// readBootstrapStatus reads the bootstrap log once and returns the state
+// given by its last progress marker.
+func readBootstrapStatus(ctx context.Context, streamer cloud.LogStreamer, clusterName string) (*bootstrapStatus, error) {
+	status := &bootstrapStatus{State: "running"}
+
+	err := streamer.StreamBootstrapLogs(ctx, clusterName, false, func(line string) {
+		event, isMarker := cloud.ParseBootstrapMarker(line)
+		if !isMarker {
+			return
+		}
+		switch event.Kind {
+		case cloud.BootstrapEventProgress:
+			status.Step, status.Current, status.Total = event.Name, event.Step, event.Total
+		case cloud.BootstrapEventFailed:
+			status.State, status.Step = "failed", event.Name
+		case cloud.BootstrapEventDone:
+			status.State = "done"
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func printClusterInfo(info *cloud.ClusterInfo) {
+	fmt.Printf("Name:       %s\n", info.Name)
+	fmt.Printf("Type:       %s\n", info.Type)
+	fmt.Printf("Provider:   %s\n", info.Provider)
+	if info.Region != "" {
+		fmt.Printf("Region:     %s\n", info.Region)
+	}
+	fmt.Printf("Status:     %s\n", info.Status)
+	if info.Endpoint != "" {
+		fmt.Printf("Endpoint:   %s\n", info.Endpoint)
+	}
+	if info.KubeconfigPath != "" {
+		fmt.Printf("Kubeconfig: %s\n", info.KubeconfigPath)
+	}
+
+	if len(info.Resources) == 0 {
+		return
+	}
+	kinds := make([]string, 0, len(info.Resources))
+	for kind := range info.Resources {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	fmt.Println("Resources:")
+	for _, kind := range kinds {
+		for _, id := range info.Resources[kind] {
+			fmt.Printf("  %-18s %s\n", kind, id)
+		}
+	}
+}
+
+func init() {
+	clusterCmd.AddCommand(getCmd)
+	clusterCmd.AddCommand(statusCmd)
+	clusterCmd.AddCommand(listCmd)
+
+	addOutputFlag(getCmd)
+	addOutputFlag(statusCmd)
+	addOutputFlag(listCmd)
+}
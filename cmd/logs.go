@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [cluster-name]",
+	Short: "Show bootstrap logs for a single-node cluster",
+	Long: `Show the cloud-init bootstrap log of a single-node cluster.
+
+The log is read from /var/log/cloud-init-output.log on the instance through
+SSM, so no SSH access or public IP is needed. With --follow the command keeps
+streaming until the bootstrap finishes and exits non-zero if a step fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		clusterName := args[0]
+		cloudProvider := viper.GetString("provider")
+		follow := viper.GetBool("follow")
+		progressOnly := viper.GetBool("progress")
+
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		streamer, ok := manager.(cloud.LogStreamer)
+		if !ok {
+			return fmt.Errorf("log streaming is not supported for %s clusters", cloudProvider)
+		}
+
+		var failure *cloud.BootstrapEvent
+		done := false
+
+		err = streamer.StreamBootstrapLogs(ctx, clusterName, follow, func(line string) {
+			event, isMarker := cloud.ParseBootstrapMarker(line)
+			if !isMarker {
+				if !progressOnly {
+					fmt.Println(line)
+				}
+				return
+			}
+
+			switch event.Kind {
+			case cloud.BootstrapEventProgress:
+				printProgress(event, progressOnly)
+			case cloud.BootstrapEventFailed:
+				failure = &event
+			case cloud.BootstrapEventDone:
+				done = true
+			}
+		})
+		if progressOnly {
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil {
+			return err
+		}
+
+		if failure != nil {
+			return fmt.Errorf("bootstrap failed at step '%s' (exit code %d, script line %d)", failure.Name, failure.ExitCode, failure.Line)
+		}
+		if done {
+			fmt.Fprintf(os.Stderr, "✅ Cluster '%s' bootstrap complete!\n", clusterName)
+		}
+		return nil
+	},
+}
+
+// printProgress renders a bootstrap progress marker as a progress bar. In
+// progress-only mode the bar is redrawn in place.
+func printProgress(event cloud.BootstrapEvent, inPlace bool) {
+	const width = 30
+
+	filled := 0
+	if event.Total > 0 {
+		filled = event.Step * width / event.Total
+	}
+	// Hooks run verbatim and may print markers of their own, so the step
+	// can be out of range
+	filled = max(0, min(filled, width))
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+
+	if inPlace {
+		fmt.Fprintf(os.Stderr, "\r\033[K[%s] %d/%d %s", bar, event.Step, event.Total, event.Name)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[%s] %d/%d %s\n", bar, event.Step, event.Total, event.Name)
+}
+
+func init() {
+	clusterCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().BoolP("follow", "f", false, "keep streaming until the bootstrap finishes")
+	logsCmd.Flags().Bool("progress", false, "show only a progress bar instead of the raw log")
+
+	viper.BindPFlag("follow", logsCmd.Flags().Lookup("follow"))
+	viper.BindPFlag("progress", logsCmd.Flags().Lookup("progress"))
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// addOutputFlag adds -o to a command whose result can be printed as json or
+// yaml for scripts.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", "", "print the result as json or yaml on stdout")
+}
+
+// outputFormat returns the validated -o value; empty means human output.
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("output")
+	switch format {
+	case "", "json", "yaml":
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q (use json or yaml)", format)
+	}
+}
+
+// writeOutput prints v to stdout in the given machine-readable format.
+func writeOutput(format string, v interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(v)
+	default:
+		return fmt.Errorf("invalid output format %q (use json or yaml)", format)
+	}
+}
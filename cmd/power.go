@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop [cluster-name]",
+	Short: "Stop a cluster's instances without deleting them",
+	Long: `Stop the compute behind a single-node cluster to save money while it is idle.
+
+The EC2 instance is stopped (or the Azure VM deallocated). Disks and all cluster
+state are kept, so 'cluster start' brings the cluster back as it was.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		clusterName := args[0]
+
+		manager, err := newPowerManager(ctx, viper.GetString("provider"))
+		if err != nil {
+			return err
+		}
+
+		if err := manager.StopCluster(ctx, clusterName); err != nil {
+			return fmt.Errorf("failed to stop cluster: %w", err)
+		}
+
+		fmt.Printf("✅ Cluster '%s' stopped. Run 'xstrapolate cluster start %s' to resume.\n", clusterName, clusterName)
+		return nil
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start [cluster-name]",
+	Short: "Start a stopped cluster and wait for k3s to be healthy",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		clusterName := args[0]
+
+		manager, err := newPowerManager(ctx, viper.GetString("provider"))
+		if err != nil {
+			return err
+		}
+
+		result, err := manager.StartCluster(ctx, clusterName)
+		if err != nil {
+			return fmt.Errorf("failed to start cluster: %w", err)
+		}
+
+		fmt.Printf("✅ Cluster '%s' is running again.\n", clusterName)
+		if result.StoppedFor > 0 {
+			fmt.Printf("💰 Stopped for %s, saving an estimated $%.2f in compute\n",
+				result.StoppedFor.Round(time.Minute), result.EstimatedSavings)
+		}
+		return nil
+	},
+}
+
+func newPowerManager(ctx context.Context, cloudProvider string) (cloud.PowerManager, error) {
+	if cloudProvider == "" {
+		return nil, fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+	}
+
+	manager, err := newClusterManager(ctx, cloudProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	powerManager, ok := manager.(cloud.PowerManager)
+	if !ok {
+		return nil, fmt.Errorf("stop/start is not supported for %s clusters", cloudProvider)
+	}
+	return powerManager, nil
+}
+
+func init() {
+	clusterCmd.AddCommand(stopCmd)
+	clusterCmd.AddCommand(startCmd)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var reapCmd = &cobra.Command{
+	Use:   "reap",
+	Short: "Delete clusters whose TTL has expired",
+	Long: `Find clusters created with --ttl whose expiry time has passed and tear them down.
+
+Expired clusters are found by the xstrapolate-expires-at tag, so the command can
+run from anywhere with credentials, for example a scheduled CI job. Without
+--force it only lists what would be deleted.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cloudProvider := viper.GetString("provider")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+
+		reaper, ok := manager.(cloud.Reaper)
+		if !ok {
+			return fmt.Errorf("reap is not supported for %s", cloudProvider)
+		}
+
+		expired, err := reaper.ListExpiredClusters(ctx, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to find expired clusters: %w", err)
+		}
+
+		if len(expired) == 0 {
+			fmt.Println("✅ No expired clusters found")
+			return nil
+		}
+
+		fmt.Printf("Found %d expired cluster(s):\n", len(expired))
+		for _, cluster := range expired {
+			fmt.Printf("  %-30s %-12s expired %s ago\n", cluster.Name, cluster.Type, time.Since(cluster.ExpiresAt).Round(time.Minute))
+		}
+
+		if !force {
+			fmt.Println("Use --force to delete them")
+			return nil
+		}
+
+		var failed []string
+		for _, cluster := range expired {
+			fmt.Printf("🗑️  Reaping cluster '%s'...\n", cluster.Name)
+			if err := manager.DeleteCluster(ctx, cluster.Name); err != nil {
+				fmt.Printf("Warning: failed to delete cluster '%s': %v\n", cluster.Name, err)
+				failed = append(failed, cluster.Name)
+			}
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to reap clusters %v: %w", failed, cloud.ErrPartialTeardown)
+		}
+
+		fmt.Printf("✅ Reaped %d cluster(s)\n", len(expired))
+		return nil
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(reapCmd)
+
+	reapCmd.Flags().Bool("force", false, "delete expired clusters instead of only listing them")
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/drduker/xstrapolate/pkg/logging"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -17,6 +22,21 @@ var rootCmd = &cobra.Command{
 EKS or AKS clusters with Crossplane and Flux pre-installed.
 
 It supports reading configuration from ~/.xstrapolate or using command-line flags.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Progress goes to stderr so stdout carries only command output,
+		// such as the -o json documents
+		logger, err := logging.New(os.Stderr, viper.GetString("log-format"), viper.GetString("log-level"))
+		if err != nil {
+			return err
+		}
+		slog.SetDefault(logger)
+
+		// 'context use' must still work when the current context is broken
+		if cmd.Parent() == contextCmd {
+			return nil
+		}
+		return applyContext(cmd)
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -33,7 +53,20 @@ func getVersion() string {
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	// Ctrl-C cancels the command's context so it can stop cleanly instead of
+	// exiting half way through an AWS call
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
+}
+
+// commandContext returns the command's context limited by --timeout.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+	return context.WithCancel(cmd.Context())
 }
 
 func init() {
@@ -42,9 +75,28 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.xstrapolate.yaml)")
-	rootCmd.PersistentFlags().String("cloud", "", "cloud provider (aws or azure)")
-
-	viper.BindPFlag("cloud", rootCmd.PersistentFlags().Lookup("cloud"))
+	rootCmd.PersistentFlags().String("cloud", "", "cloud provider (aws, azure or local); optional when the active context sets it")
+	rootCmd.PersistentFlags().String("context", "", "context to use instead of current_context")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "abort the command after this long, e.g. 45m (default: no limit)")
+
+	// Bound to "provider" rather than "cloud" so the flag does not hide the
+	// cloud section of the config file
+	viper.BindPFlag("provider", rootCmd.PersistentFlags().Lookup("cloud"))
+	rootCmd.PersistentFlags().String("profile", "", "AWS profile: an entry of cloud.aws.profiles or a profile in ~/.aws/config (SSO supported)")
+	rootCmd.PersistentFlags().String("role-arn", "", "AWS role to assume on top of the profile's credentials")
+	rootCmd.PersistentFlags().String("external-id", "", "external ID required by the role's trust policy")
+	rootCmd.PersistentFlags().String("mfa-serial", "", "ARN of the MFA device for the role; the token code is prompted for")
+	rootCmd.PersistentFlags().String("log-format", "text", "progress log format (text or json), written to stderr")
+	rootCmd.PersistentFlags().String("log-level", "info", "progress log level (debug, info, warn or error)")
+
+	viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindPFlag("role-arn", rootCmd.PersistentFlags().Lookup("role-arn"))
+	viper.BindPFlag("external-id", rootCmd.PersistentFlags().Lookup("external-id"))
+	viper.BindPFlag("mfa-serial", rootCmd.PersistentFlags().Lookup("mfa-serial"))
+	viper.BindPFlag("log-format", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level"))
 }
 
 func initConfig() {
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scaleCmd = &cobra.Command{
+	Use:   "scale [cluster-name] --nodes N",
+	Short: "Change the number of nodes in a cluster",
+	Long: `Set the node count of a node pool and wait until the nodes are ready.
+
+  eks          resizes a managed node group (--pool, needed when there are
+               several)
+  aks          resizes an agent pool
+  single-node  adds or removes k3s agent instances in the "agents" pool;
+  k3s          new agents join with the cluster token kept in SSM Parameter
+               Store, and removed agents are drained first, newest first
+
+The servers of single-node and k3s clusters are fixed when they are created;
+--nodes counts the agents next to them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		clusterName := args[0]
+		cloudProvider := viper.GetString("provider")
+		pool, _ := cmd.Flags().GetString("pool")
+		nodes, _ := cmd.Flags().GetInt32("nodes")
+
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+		if !cmd.Flags().Changed("nodes") {
+			return fmt.Errorf("the node count must be given with --nodes, e.g. --nodes 3")
+		}
+		if nodes < 0 {
+			return fmt.Errorf("--nodes must not be negative")
+		}
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+		pools, ok := manager.(cloud.NodePoolManager)
+		if !ok {
+			return fmt.Errorf("scaling clusters is not supported for %s", cloudProvider)
+		}
+
+		if err := pools.ScaleNodePool(ctx, clusterName, pool, nodes); err != nil {
+			return fmt.Errorf("failed to scale cluster: %w", err)
+		}
+		slog.Info("✅ Cluster scaled", "cluster", clusterName, "nodes", nodes)
+
+		if format != "" {
+			cluster, err := manager.GetCluster(ctx, clusterName)
+			if err != nil {
+				return fmt.Errorf("failed to get cluster: %w", err)
+			}
+			return writeOutput(format, cluster)
+		}
+		return nil
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(scaleCmd)
+
+	scaleCmd.Flags().Int32("nodes", 0, "number of nodes in the pool")
+	scaleCmd.Flags().String("pool", "", "node pool to scale (default: the only pool, or the agents of a single-node cluster)")
+	addOutputFlag(scaleCmd)
+}
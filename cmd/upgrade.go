@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [cluster-name] --to VERSION",
+	Short: "Upgrade a cluster to a newer Kubernetes version",
+	Long: `Move a cluster to a newer Kubernetes version.
+
+  eks          the control plane, then every managed node group (--to 1.30)
+  aks          the control plane, then every agent pool (--to 1.30)
+  single-node  the k3s binary of the server, then of each agent, verified
+               against the release checksums, then k3s is restarted (--to
+               1.30 picks the newest 1.30 release, or give a release such as
+               v1.30.2+k3s1)
+
+The Kubernetes version skew policy is checked first: no downgrades, the
+control plane moves one minor version at a time, and nodes may not fall more
+than three minor versions behind it. The plan is shown and confirmed before
+anything changes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		clusterName := args[0]
+		cloudProvider := viper.GetString("provider")
+		version, _ := cmd.Flags().GetString("to")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if cloudProvider == "" {
+			return fmt.Errorf("cloud provider must be specified (--cloud aws or --cloud azure, or a context that sets cloud)")
+		}
+		if version == "" {
+			return fmt.Errorf("the target version must be given with --to, e.g. --to 1.30")
+		}
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		manager, err := newClusterManager(ctx, cloudProvider)
+		if err != nil {
+			return err
+		}
+		upgrader, ok := manager.(cloud.Upgrader)
+		if !ok {
+			return fmt.Errorf("cluster upgrades are not supported for %s", cloudProvider)
+		}
+
+		plan, err := upgrader.PlanUpgrade(ctx, clusterName, version)
+		if err != nil {
+			return err
+		}
+
+		// The plan is the result of a dry run; otherwise it is progress
+		var planOut io.Writer = os.Stdout
+		if format != "" {
+			if dryRun || plan.Empty() {
+				return writeOutput(format, plan)
+			}
+			planOut = os.Stderr
+		}
+		fmt.Fprint(planOut, plan)
+
+		if plan.Empty() || dryRun {
+			return nil
+		}
+		if !force && !confirm("Upgrade the cluster?") {
+			fmt.Fprintln(planOut, "Nothing changed")
+			return nil
+		}
+
+		if err := upgrader.Upgrade(ctx, plan); err != nil {
+			return fmt.Errorf("failed to upgrade cluster: %w", err)
+		}
+
+		if format != "" {
+			return writeOutput(format, plan)
+		}
+		return nil
+	},
+}
+
+func init() {
+	clusterCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().String("to", "", "Kubernetes version to upgrade to, e.g. 1.30 (k3s also takes a release such as v1.30.2+k3s1)")
+	upgradeCmd.Flags().Bool("dry-run", false, "show the plan without changing anything")
+	upgradeCmd.Flags().Bool("force", false, "upgrade without asking for confirmation")
+	addOutputFlag(upgradeCmd)
+}
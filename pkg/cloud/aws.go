@@ -3,130 +3,208 @@ package cloud
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/eks"
 	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/spf13/viper"
+	"github.com/drduker/xstrapolate/pkg/config"
+)
+
+// IAM resources shared by every xstrapolate cluster in the account
+const (
+	ssmRoleName        = "xstrapolate-ssm-role"
+	ssmProfileName     = "xstrapolate-ssm-profile"
+	eksServiceRoleName = "xstrapolate-eks-service-role"
+	eksNodeRoleName    = "xstrapolate-eks-node-role"
 )
 
 type AWSManager struct {
-	cfg       aws.Config
-	eksClient *eks.Client
-	ec2Client *ec2.Client
-	iamClient *iam.Client
-	stsClient *sts.Client
-	region    string
+	cfg          aws.Config
+	eksClient    *eks.Client
+	ec2Client    *ec2.Client
+	elbClient    *elasticloadbalancingv2.Client
+	iamClient    *iam.Client
+	kmsClient    *kms.Client
+	ssmClient    *ssm.Client
+	stsClient    *sts.Client
+	quotasClient *servicequotas.Client
+	region       string
+	bootstrap    config.BootstrapConfig
+	k3s          config.K3sConfig
+	network      vpcNetwork
+	instance     config.AWSInstanceConfig
+	eks          config.AWSEKSConfig
+	gitops       config.GitOpsConfig
+	components   config.ComponentsConfig
+	lifetime     LifetimeOptions
+	callerArn    string
+	userTags     map[string]string
 }
 
-func NewAWSManager() (*AWSManager, error) {
-	// Check for region in order of preference:
-	// 1. Command line flag
-	// 2. AWS_REGION environment variable
-	// 3. Config file
-	// 4. Default to us-west-2
-	var region, regionSource string
-
-	if flagRegion := viper.GetString("region"); flagRegion != "" {
-		region = flagRegion
-		regionSource = "command line flag"
-	} else if envRegion := os.Getenv("AWS_REGION"); envRegion != "" {
-		region = envRegion
-		regionSource = "AWS_REGION environment variable"
-	} else if configRegion := viper.GetString("cloud.aws.region"); configRegion != "" {
-		region = configRegion
-		regionSource = "config file"
-	} else {
-		region = "us-west-2"
-		regionSource = "default"
+// NewAWSManager builds a manager from the loaded config. Command line flags
+// bound in viper still override the matching config file settings.
+func NewAWSManager(ctx context.Context, conf *config.Config) (*AWSManager, error) {
+	cfg, err := loadAWSConfig(ctx, conf.Cloud.AWS)
+	if err != nil {
+		return nil, err
 	}
+	region := cfg.Region
+	classifyAWSErrors(&cfg)
 
-	fmt.Printf("Using AWS region: %s (from %s)\n", region, regionSource)
+	manager := &AWSManager{
+		cfg:          cfg,
+		eksClient:    eks.NewFromConfig(cfg),
+		ec2Client:    ec2.NewFromConfig(cfg),
+		elbClient:    elasticloadbalancingv2.NewFromConfig(cfg),
+		iamClient:    iam.NewFromConfig(cfg),
+		kmsClient:    kms.NewFromConfig(cfg),
+		ssmClient:    ssm.NewFromConfig(cfg),
+		stsClient:    sts.NewFromConfig(cfg),
+		quotasClient: servicequotas.NewFromConfig(cfg),
+		region:       region,
+	}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-	)
+	manager.bootstrap = conf.Bootstrap
+	manager.bootstrap.RegistryMirrors, err = loadRegistryMirrors(manager.bootstrap.RegistryMirrors)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w\n\nPlease ensure you have AWS credentials configured:\n- Run 'aws configure' to set up credentials\n- Or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables\n- Or use IAM roles if running on EC2", err)
+		return nil, err
 	}
-
-	manager := &AWSManager{
-		cfg:       cfg,
-		eksClient: eks.NewFromConfig(cfg),
-		ec2Client: ec2.NewFromConfig(cfg),
-		iamClient: iam.NewFromConfig(cfg),
-		stsClient: sts.NewFromConfig(cfg),
-		region:    region,
+	manager.k3s = loadK3sConfig(conf.K3s)
+	manager.network, err = loadVPCNetwork(conf.Network.VPCCIDR)
+	if err != nil {
+		return nil, err
+	}
+	manager.instance = loadInstanceConfig(conf.Cloud.AWS.Instance)
+	manager.gitops = conf.GitOps
+	manager.components = conf.Components
+	manager.eks, err = loadEKSConfig(conf.Cloud.AWS.EKS)
+	if err != nil {
+		return nil, err
+	}
+	manager.lifetime, err = loadLifetimeOptions()
+	if err != nil {
+		return nil, err
+	}
+	manager.userTags, err = loadUserTags(conf.Tags)
+	if err != nil {
+		return nil, err
 	}
 
 	// Test credentials by getting caller identity
-	_, err = manager.stsClient.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	identity, err := manager.stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to validate AWS credentials: %w\n\nPlease ensure you have AWS credentials configured:\n- Run 'aws configure' to set up credentials\n- Or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables\n- Or use IAM roles if running on EC2", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, withClass(fmt.Errorf("failed to validate AWS credentials: %w\n\nPlease ensure you have AWS credentials configured:\n- Run 'aws configure' to set up credentials\n- Or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables\n- Or use IAM roles if running on EC2\n- For SSO profiles, run 'aws sso login --profile <name>' first", err), ErrCredentials)
 	}
+	manager.callerArn = aws.ToString(identity.Arn)
+	slog.Info("🔑 Using AWS account", "account", aws.ToString(identity.Account), "identity", manager.callerArn)
 
 	return manager, nil
 }
 
-func (m *AWSManager) CreateCluster(name, clusterType string) (*ClusterInfo, error) {
+func (m *AWSManager) CreateCluster(ctx context.Context, name, clusterType string) (*ClusterInfo, error) {
+	var info *ClusterInfo
+	var err error
+
 	switch clusterType {
 	case "eks":
-		return m.createEKSCluster(name)
+		info, err = m.createEKSCluster(ctx, name)
 	case "single-node":
-		return m.createSingleNodeCluster(name)
+		info, err = m.createSingleNodeCluster(ctx, name)
+	case "k3s":
+		info, err = m.createK3sCluster(ctx, name)
 	default:
 		return nil, fmt.Errorf("unsupported cluster type for AWS: %s", clusterType)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	info.Region = m.region
+	info.Resources, err = m.clusterResources(ctx, name)
+	if err != nil {
+		slog.Warn("failed to list cluster resources", "cluster", name, "err", err)
+	}
+	return info, nil
 }
 
-func (m *AWSManager) createEKSCluster(name string) (*ClusterInfo, error) {
-	fmt.Println("Creating EKS cluster (this will take 10-15 minutes)...")
+func (m *AWSManager) createEKSCluster(ctx context.Context, name string) (*ClusterInfo, error) {
+	// Nothing on an EKS cluster acts on the auto-stop tag, so refuse it
+	// rather than tag a schedule that never runs
+	if m.lifetime.AutoStop != "" {
+		return nil, fmt.Errorf("--auto-stop is not supported for EKS clusters")
+	}
+
+	slog.Info("Creating EKS cluster (this will take 10-15 minutes)...")
 
-	roleArn, err := m.ensureEKSServiceRole()
+	createdAt := time.Now()
+	tags := m.tagsFor(name, createdAt)
+
+	roleArn, err := m.ensureEKSServiceRole(ctx, tags.Shared())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EKS service role: %w", err)
 	}
 
-	subnetIds, err := m.getOrCreateSubnets()
+	subnetIds, err := m.getOrCreateSubnets(ctx, tags)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get or create subnets: %w", err)
 	}
 
 	input := &eks.CreateClusterInput{
 		Name:    aws.String(name),
-		Version: aws.String("1.28"),
+		Version: aws.String(m.eks.Version),
 		RoleArn: aws.String(roleArn),
 		ResourcesVpcConfig: &ekstypes.VpcConfigRequest{
 			SubnetIds: subnetIds,
 		},
+		Tags: tags.With(m.lifetime.Tags(createdAt)).For("eks-cluster", nil),
 	}
 
-	result, err := m.eksClient.CreateCluster(context.TODO(), input)
+	result, err := m.eksClient.CreateCluster(ctx, input)
 	if err != nil {
+		// EKS reports a duplicate name as the cluster being in use
+		if hasAWSErrorCode(err, "ResourceInUseException") {
+			err = withClass(err, ErrAlreadyExists)
+		}
 		return nil, fmt.Errorf("failed to create EKS cluster: %w", err)
 	}
 
-	fmt.Printf("EKS cluster '%s' creation initiated. Waiting for completion...\n", name)
+	slog.Info("EKS cluster creation initiated. Waiting for completion...", "cluster", name)
 
 	waiter := eks.NewClusterActiveWaiter(m.eksClient)
-	err = waiter.Wait(context.TODO(), &eks.DescribeClusterInput{
+	err = waiter.Wait(ctx, &eks.DescribeClusterInput{
 		Name: aws.String(name),
-	}, 20*time.Minute)
+	}, waitTimeout(ctx, 20*time.Minute))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to wait for cluster to be active: %w", err)
 	}
 
+	if err := m.createNodePools(ctx, name, subnetIds, tags); err != nil {
+		return nil, err
+	}
+	nodePools, err := m.describeNodePools(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
 	kubeconfigPath, err := m.generateKubeconfig(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate kubeconfig: %w", err)
@@ -139,53 +217,61 @@ func (m *AWSManager) createEKSCluster(name string) (*ClusterInfo, error) {
 		KubeconfigPath: kubeconfigPath,
 		Endpoint:       aws.ToString(result.Cluster.Endpoint),
 		Status:         "active",
+		Version:        m.eks.Version,
+		NodePools:      nodePools,
 	}, nil
 }
 
-func (m *AWSManager) createSingleNodeCluster(name string) (*ClusterInfo, error) {
-	fmt.Println("Creating single-node cluster using k3s with SSM access...")
+func (m *AWSManager) createSingleNodeCluster(ctx context.Context, name string) (*ClusterInfo, error) {
+	slog.Info("Creating single-node cluster using k3s with SSM access...")
+
+	createdAt := time.Now()
+	tags := m.tagsFor(name, createdAt)
 
 	// Ensure SSM instance profile exists
-	err := m.ensureSSMInstanceProfile()
+	err := m.ensureSSMInstanceProfile(ctx, name, tags.Shared())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SSM instance profile: %w", err)
 	}
 
 	// Wait for instance profile to be ready
-	_, err = m.waitForInstanceProfile("xstrapolate-ssm-profile")
+	_, err = m.waitForInstanceProfile(ctx, ssmProfileName)
 	if err != nil {
 		return nil, fmt.Errorf("instance profile not ready: %w", err)
 	}
 
 	// Additional wait for EC2 service to recognize the instance profile
-	fmt.Println("⏳ Waiting for EC2 service to recognize instance profile...")
-	time.Sleep(5 * time.Second)
+	slog.Info("⏳ Waiting for EC2 service to recognize instance profile...")
+	if err := sleepContext(ctx, 5*time.Second); err != nil {
+		return nil, err
+	}
 
-	instanceId, err := m.createEC2Instance(name)
+	instanceId, err := m.createEC2Instance(ctx, name, createdAt, tags)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create EC2 instance: %w", err)
 	}
 
-	fmt.Printf("EC2 instance '%s' created in private subnet (SSM access only).\n", instanceId)
-	fmt.Println("Installing k3s, Crossplane, and Flux...")
-	fmt.Println("Setup is running in the background. This may take 5-10 minutes.")
-	fmt.Printf("Connect via SSM: aws ssm start-session --target %s\n", instanceId)
-	fmt.Println("Check progress: sudo journalctl -u cloud-final -f")
-	fmt.Println("Get kubeconfig: sudo cat /etc/rancher/k3s/k3s.yaml")
-	fmt.Println("Note: Instance has no public IP - access only via SSM Session Manager")
+	slog.Info("EC2 instance created in private subnet (SSM access only)", "instance", instanceId)
+	slog.Info("Installing k3s, Crossplane, and Flux...")
+	slog.Info("Setup is running in the background. This may take 5-10 minutes.")
+	slog.Info("Connect via SSM: aws ssm start-session --target " + instanceId)
+	slog.Info("Check progress: sudo journalctl -u cloud-final -f")
+	slog.Info("Or follow from here: xstrapolate cluster logs " + name + " --cloud aws --follow")
+	slog.Info("Get kubeconfig: sudo cat /etc/rancher/k3s/k3s.yaml")
+	slog.Info("Note: Instance has no public IP - access only via SSM Session Manager")
 
 	return &ClusterInfo{
 		Name:           name,
 		Type:           "single-node",
 		Provider:       "aws",
-		KubeconfigPath: "/etc/rancher/k3s/k3s.yaml",
+		KubeconfigPath: k3sKubeconfigPath,
 		Endpoint:       instanceId, // Use instance ID since no public IP
 		Status:         "provisioning",
 	}, nil
 }
 
-func (m *AWSManager) ensureEKSServiceRole() (string, error) {
-	roleName := "xstrapolate-eks-service-role"
+func (m *AWSManager) ensureEKSServiceRole(ctx context.Context, tags TagBuilder) (string, error) {
+	roleName := eksServiceRoleName
 
 	assumeRolePolicyDocument := `{
 		"Version": "2012-10-17",
@@ -200,14 +286,15 @@ func (m *AWSManager) ensureEKSServiceRole() (string, error) {
 		]
 	}`
 
-	_, err := m.iamClient.CreateRole(context.TODO(), &iam.CreateRoleInput{
+	_, err := m.iamClient.CreateRole(ctx, &iam.CreateRoleInput{
 		RoleName:                 aws.String(roleName),
 		AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocument),
+		Tags:                     iamTags(tags.For("iam-role", nil)),
 	})
 
 	if err != nil {
 		// Role might already exist
-		fmt.Println("Role might already exist, continuing...")
+		slog.Debug("Role might already exist, continuing...", "role", roleName)
 	}
 
 	policyArns := []string{
@@ -215,30 +302,30 @@ func (m *AWSManager) ensureEKSServiceRole() (string, error) {
 	}
 
 	for _, policyArn := range policyArns {
-		_, err = m.iamClient.AttachRolePolicy(context.TODO(), &iam.AttachRolePolicyInput{
+		_, err = m.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
 			RoleName:  aws.String(roleName),
 			PolicyArn: aws.String(policyArn),
 		})
 		if err != nil {
-			fmt.Printf("Warning: failed to attach policy %s: %v\n", policyArn, err)
+			slog.Warn("failed to attach policy", "policy", policyArn, "err", err)
 		}
 	}
 
-	accountID, err := m.getAccountID()
+	accountID, err := m.getAccountID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get account ID: %w", err)
 	}
 	return fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName), nil
 }
 
-func (m *AWSManager) getOrCreateSubnets() ([]string, error) {
+func (m *AWSManager) getOrCreateSubnets(ctx context.Context, tags TagBuilder) ([]string, error) {
 	// Always create new VPC and subnets
-	fmt.Println("Creating new VPC and subnets for xstrapolate...")
-	return m.createVPCAndSubnets()
+	slog.Info("Creating new VPC and subnets for xstrapolate...")
+	return m.createVPCAndSubnets(ctx, tags)
 }
 
-func (m *AWSManager) findExistingXstrapolateSubnets() ([]string, error) {
-	result, err := m.ec2Client.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{
+func (m *AWSManager) findExistingXstrapolateSubnets(ctx context.Context) ([]string, error) {
+	result, err := m.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("tag:xstrapolate-vpc"),
@@ -267,52 +354,33 @@ func (m *AWSManager) findExistingXstrapolateSubnets() ([]string, error) {
 	return subnetIds, nil
 }
 
-func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
+func (m *AWSManager) createVPCAndSubnets(ctx context.Context, tags TagBuilder) ([]string, error) {
 	// Create VPC
-	vpcResult, err := m.ec2Client.CreateVpc(context.TODO(), &ec2.CreateVpcInput{
-		CidrBlock: aws.String("10.0.0.0/16"),
-		TagSpecifications: []types.TagSpecification{
-			{
-				ResourceType: types.ResourceTypeVpc,
-				Tags: []types.Tag{
-					{
-						Key:   aws.String("Name"),
-						Value: aws.String("xstrapolate-vpc"),
-					},
-					{
-						Key:   aws.String("xstrapolate-managed"),
-						Value: aws.String("true"),
-					},
-					{
-						Key:   aws.String("xstrapolate-resource-type"),
-						Value: aws.String("vpc"),
-					},
-					{
-						Key:   aws.String("xstrapolate-vpc"),
-						Value: aws.String("true"),
-					},
-				},
-			},
-		},
+	vpcResult, err := m.ec2Client.CreateVpc(ctx, &ec2.CreateVpcInput{
+		CidrBlock: aws.String(m.network.cidr),
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeVpc, tags.For("vpc", map[string]string{
+			"Name":            "xstrapolate-vpc",
+			"xstrapolate-vpc": "true",
+		})),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create VPC: %w", err)
 	}
 
 	vpcId := aws.ToString(vpcResult.Vpc.VpcId)
-	fmt.Printf("Created VPC: %s\n", vpcId)
+	slog.Info("Created VPC", "vpc", vpcId)
 
 	// Enable DNS hostnames
-	_, err = m.ec2Client.ModifyVpcAttribute(context.TODO(), &ec2.ModifyVpcAttributeInput{
+	_, err = m.ec2Client.ModifyVpcAttribute(ctx, &ec2.ModifyVpcAttributeInput{
 		VpcId:              aws.String(vpcId),
 		EnableDnsHostnames: &types.AttributeBooleanValue{Value: aws.Bool(true)},
 	})
 	if err != nil {
-		fmt.Printf("Warning: failed to enable DNS hostnames: %v\n", err)
+		slog.Warn("failed to enable DNS hostnames", "vpc", vpcId, "err", err)
 	}
 
 	// Get availability zones
-	azResult, err := m.ec2Client.DescribeAvailabilityZones(context.TODO(), &ec2.DescribeAvailabilityZonesInput{
+	azResult, err := m.ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("state"),
@@ -329,26 +397,8 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 	}
 
 	// Create Internet Gateway
-	igwResult, err := m.ec2Client.CreateInternetGateway(context.TODO(), &ec2.CreateInternetGatewayInput{
-		TagSpecifications: []types.TagSpecification{
-			{
-				ResourceType: types.ResourceTypeInternetGateway,
-				Tags: []types.Tag{
-					{
-						Key:   aws.String("Name"),
-						Value: aws.String("xstrapolate-igw"),
-					},
-					{
-						Key:   aws.String("xstrapolate-managed"),
-						Value: aws.String("true"),
-					},
-					{
-						Key:   aws.String("xstrapolate-resource-type"),
-						Value: aws.String("internet-gateway"),
-					},
-				},
-			},
-		},
+	igwResult, err := m.ec2Client.CreateInternetGateway(ctx, &ec2.CreateInternetGatewayInput{
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeInternetGateway, tags.For("internet-gateway", map[string]string{"Name": "xstrapolate-igw"})),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create internet gateway: %w", err)
@@ -357,7 +407,7 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 	igwId := aws.ToString(igwResult.InternetGateway.InternetGatewayId)
 
 	// Attach Internet Gateway to VPC
-	_, err = m.ec2Client.AttachInternetGateway(context.TODO(), &ec2.AttachInternetGatewayInput{
+	_, err = m.ec2Client.AttachInternetGateway(ctx, &ec2.AttachInternetGatewayInput{
 		InternetGatewayId: aws.String(igwId),
 		VpcId:             aws.String(vpcId),
 	})
@@ -368,43 +418,21 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 	// Create subnets in different AZs
 	var publicSubnetIds []string
 	var privateSubnetIds []string
-	
+
 	for i := 0; i < 2; i++ {
 		az := aws.ToString(azResult.AvailabilityZones[i].ZoneName)
-		
+
 		// Create public subnet
-		publicCidr := fmt.Sprintf("10.0.%d.0/24", i*10+1)
-		publicSubnetResult, err := m.ec2Client.CreateSubnet(context.TODO(), &ec2.CreateSubnetInput{
+		publicCidr := m.network.subnet(i*10 + 1)
+		publicSubnetResult, err := m.ec2Client.CreateSubnet(ctx, &ec2.CreateSubnetInput{
 			VpcId:            aws.String(vpcId),
 			CidrBlock:        aws.String(publicCidr),
 			AvailabilityZone: aws.String(az),
-			TagSpecifications: []types.TagSpecification{
-				{
-					ResourceType: types.ResourceTypeSubnet,
-					Tags: []types.Tag{
-						{
-							Key:   aws.String("Name"),
-							Value: aws.String(fmt.Sprintf("xstrapolate-public-%d", i+1)),
-						},
-						{
-							Key:   aws.String("xstrapolate-managed"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("xstrapolate-resource-type"),
-							Value: aws.String("subnet"),
-						},
-						{
-							Key:   aws.String("xstrapolate-vpc"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("Type"),
-							Value: aws.String("public"),
-						},
-					},
-				},
-			},
+			TagSpecifications: ec2TagSpecs(types.ResourceTypeSubnet, tags.For("subnet", map[string]string{
+				"Name":            fmt.Sprintf("xstrapolate-public-%d", i+1),
+				"xstrapolate-vpc": "true",
+				"Type":            "public",
+			})),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create public subnet %d: %w", i+1, err)
@@ -412,38 +440,16 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 		publicSubnetIds = append(publicSubnetIds, aws.ToString(publicSubnetResult.Subnet.SubnetId))
 
 		// Create private subnet
-		privateCidr := fmt.Sprintf("10.0.%d.0/24", i*10+2)
-		privateSubnetResult, err := m.ec2Client.CreateSubnet(context.TODO(), &ec2.CreateSubnetInput{
+		privateCidr := m.network.subnet(i*10 + 2)
+		privateSubnetResult, err := m.ec2Client.CreateSubnet(ctx, &ec2.CreateSubnetInput{
 			VpcId:            aws.String(vpcId),
 			CidrBlock:        aws.String(privateCidr),
 			AvailabilityZone: aws.String(az),
-			TagSpecifications: []types.TagSpecification{
-				{
-					ResourceType: types.ResourceTypeSubnet,
-					Tags: []types.Tag{
-						{
-							Key:   aws.String("Name"),
-							Value: aws.String(fmt.Sprintf("xstrapolate-private-%d", i+1)),
-						},
-						{
-							Key:   aws.String("xstrapolate-managed"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("xstrapolate-resource-type"),
-							Value: aws.String("subnet"),
-						},
-						{
-							Key:   aws.String("xstrapolate-vpc"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("Type"),
-							Value: aws.String("private"),
-						},
-					},
-				},
-			},
+			TagSpecifications: ec2TagSpecs(types.ResourceTypeSubnet, tags.For("subnet", map[string]string{
+				"Name":            fmt.Sprintf("xstrapolate-private-%d", i+1),
+				"xstrapolate-vpc": "true",
+				"Type":            "private",
+			})),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create private subnet %d: %w", i+1, err)
@@ -452,27 +458,9 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 	}
 
 	// Create route table for public subnets
-	rtResult, err := m.ec2Client.CreateRouteTable(context.TODO(), &ec2.CreateRouteTableInput{
-		VpcId: aws.String(vpcId),
-		TagSpecifications: []types.TagSpecification{
-			{
-				ResourceType: types.ResourceTypeRouteTable,
-				Tags: []types.Tag{
-					{
-						Key:   aws.String("Name"),
-						Value: aws.String("xstrapolate-public-rt"),
-					},
-					{
-						Key:   aws.String("xstrapolate-managed"),
-						Value: aws.String("true"),
-					},
-					{
-						Key:   aws.String("xstrapolate-resource-type"),
-						Value: aws.String("route-table"),
-					},
-				},
-			},
-		},
+	rtResult, err := m.ec2Client.CreateRouteTable(ctx, &ec2.CreateRouteTableInput{
+		VpcId:             aws.String(vpcId),
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeRouteTable, tags.For("route-table", map[string]string{"Name": "xstrapolate-public-rt"})),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create route table: %w", err)
@@ -481,7 +469,7 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 	rtId := aws.ToString(rtResult.RouteTable.RouteTableId)
 
 	// Add route to Internet Gateway
-	_, err = m.ec2Client.CreateRoute(context.TODO(), &ec2.CreateRouteInput{
+	_, err = m.ec2Client.CreateRoute(ctx, &ec2.CreateRouteInput{
 		RouteTableId:         aws.String(rtId),
 		DestinationCidrBlock: aws.String("0.0.0.0/0"),
 		GatewayId:            aws.String(igwId),
@@ -492,29 +480,29 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 
 	// Associate public subnets with route table
 	for _, subnetId := range publicSubnetIds {
-		_, err = m.ec2Client.AssociateRouteTable(context.TODO(), &ec2.AssociateRouteTableInput{
+		_, err = m.ec2Client.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{
 			RouteTableId: aws.String(rtId),
 			SubnetId:     aws.String(subnetId),
 		})
 		if err != nil {
-			fmt.Printf("Warning: failed to associate route table with subnet %s: %v\n", subnetId, err)
+			slog.Warn("failed to associate route table with subnet", "subnet", subnetId, "err", err)
 		}
 
 		// Enable auto-assign public IP
-		_, err = m.ec2Client.ModifySubnetAttribute(context.TODO(), &ec2.ModifySubnetAttributeInput{
-			SubnetId:                        aws.String(subnetId),
-			MapPublicIpOnLaunch:             &types.AttributeBooleanValue{Value: aws.Bool(true)},
+		_, err = m.ec2Client.ModifySubnetAttribute(ctx, &ec2.ModifySubnetAttributeInput{
+			SubnetId:            aws.String(subnetId),
+			MapPublicIpOnLaunch: &types.AttributeBooleanValue{Value: aws.Bool(true)},
 		})
 		if err != nil {
-			fmt.Printf("Warning: failed to enable auto-assign public IP for subnet %s: %v\n", subnetId, err)
+			slog.Warn("failed to enable auto-assign public IP for subnet", "subnet", subnetId, "err", err)
 		}
 	}
 
-	fmt.Printf("Created VPC with %d public and %d private subnets\n", len(publicSubnetIds), len(privateSubnetIds))
-	
+	slog.Info("Created VPC subnets", "public", len(publicSubnetIds), "private", len(privateSubnetIds))
+
 	// Store VPC ID for later cleanup
 	m.storeVPCInfo(vpcId, publicSubnetIds, privateSubnetIds)
-	
+
 	// Return public subnets for EKS
 	return publicSubnetIds, nil
 }
@@ -522,50 +510,28 @@ func (m *AWSManager) createVPCAndSubnets() ([]string, error) {
 func (m *AWSManager) storeVPCInfo(vpcId string, publicSubnets, privateSubnets []string) {
 	// This is a helper to store VPC info for cleanup later
 	// You could store this in a config file or database
-	fmt.Printf("VPC Info stored:\n")
-	fmt.Printf("  VPC ID: %s\n", vpcId)
-	fmt.Printf("  Public Subnets: %v\n", publicSubnets)
-	fmt.Printf("  Private Subnets: %v\n", privateSubnets)
+	slog.Info("VPC info stored", "vpc", vpcId, "publicSubnets", publicSubnets, "privateSubnets", privateSubnets)
 }
 
-func (m *AWSManager) createVPCAndSubnetsForSSM() ([]string, []string, error) {
+func (m *AWSManager) createVPCAndSubnetsForSSM(ctx context.Context, tags TagBuilder, withS3Endpoint bool) ([]string, []string, error) {
 	// Create VPC
-	vpcResult, err := m.ec2Client.CreateVpc(context.TODO(), &ec2.CreateVpcInput{
-		CidrBlock: aws.String("10.0.0.0/16"),
-		TagSpecifications: []types.TagSpecification{
-			{
-				ResourceType: types.ResourceTypeVpc,
-				Tags: []types.Tag{
-					{
-						Key:   aws.String("Name"),
-						Value: aws.String("xstrapolate-ssm-vpc"),
-					},
-					{
-						Key:   aws.String("xstrapolate-managed"),
-						Value: aws.String("true"),
-					},
-					{
-						Key:   aws.String("xstrapolate-resource-type"),
-						Value: aws.String("vpc"),
-					},
-					{
-						Key:   aws.String("xstrapolate-vpc"),
-						Value: aws.String("true"),
-					},
-				},
-			},
-		},
+	vpcResult, err := m.ec2Client.CreateVpc(ctx, &ec2.CreateVpcInput{
+		CidrBlock: aws.String(m.network.cidr),
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeVpc, tags.For("vpc", map[string]string{
+			"Name":            "xstrapolate-ssm-vpc",
+			"xstrapolate-vpc": "true",
+		})),
 	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create VPC: %w", err)
 	}
 
 	vpcId := aws.ToString(vpcResult.Vpc.VpcId)
-	fmt.Printf("Created VPC for SSM-only access: %s\n", vpcId)
+	slog.Info("Created VPC for SSM-only access", "vpc", vpcId)
 
 	// Enable DNS support first (required for DNS hostnames)
-	fmt.Println("Enabling DNS support...")
-	_, err = m.ec2Client.ModifyVpcAttribute(context.TODO(), &ec2.ModifyVpcAttributeInput{
+	slog.Debug("Enabling DNS support...", "vpc", vpcId)
+	_, err = m.ec2Client.ModifyVpcAttribute(ctx, &ec2.ModifyVpcAttributeInput{
 		VpcId:            aws.String(vpcId),
 		EnableDnsSupport: &types.AttributeBooleanValue{Value: aws.Bool(true)},
 	})
@@ -574,8 +540,8 @@ func (m *AWSManager) createVPCAndSubnetsForSSM() ([]string, []string, error) {
 	}
 
 	// Enable DNS hostnames (required for VPC endpoints)
-	fmt.Println("Enabling DNS hostnames...")
-	_, err = m.ec2Client.ModifyVpcAttribute(context.TODO(), &ec2.ModifyVpcAttributeInput{
+	slog.Debug("Enabling DNS hostnames...", "vpc", vpcId)
+	_, err = m.ec2Client.ModifyVpcAttribute(ctx, &ec2.ModifyVpcAttributeInput{
 		VpcId:              aws.String(vpcId),
 		EnableDnsHostnames: &types.AttributeBooleanValue{Value: aws.Bool(true)},
 	})
@@ -583,10 +549,10 @@ func (m *AWSManager) createVPCAndSubnetsForSSM() ([]string, []string, error) {
 		return nil, nil, fmt.Errorf("failed to enable DNS hostnames (required for SSM): %w", err)
 	}
 
-	fmt.Println("DNS settings configured successfully")
+	slog.Debug("DNS settings configured successfully", "vpc", vpcId)
 
 	// Get availability zones
-	azResult, err := m.ec2Client.DescribeAvailabilityZones(context.TODO(), &ec2.DescribeAvailabilityZonesInput{
+	azResult, err := m.ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("state"),
@@ -609,38 +575,16 @@ func (m *AWSManager) createVPCAndSubnetsForSSM() ([]string, []string, error) {
 		az := aws.ToString(azResult.AvailabilityZones[i].ZoneName)
 
 		// Create private subnet
-		privateCidr := fmt.Sprintf("10.0.%d.0/24", i+10)
-		privateSubnetResult, err := m.ec2Client.CreateSubnet(context.TODO(), &ec2.CreateSubnetInput{
+		privateCidr := m.network.subnet(i + 10)
+		privateSubnetResult, err := m.ec2Client.CreateSubnet(ctx, &ec2.CreateSubnetInput{
 			VpcId:            aws.String(vpcId),
 			CidrBlock:        aws.String(privateCidr),
 			AvailabilityZone: aws.String(az),
-			TagSpecifications: []types.TagSpecification{
-				{
-					ResourceType: types.ResourceTypeSubnet,
-					Tags: []types.Tag{
-						{
-							Key:   aws.String("Name"),
-							Value: aws.String(fmt.Sprintf("xstrapolate-ssm-private-%d", i+1)),
-						},
-						{
-							Key:   aws.String("xstrapolate-managed"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("xstrapolate-resource-type"),
-							Value: aws.String("subnet"),
-						},
-						{
-							Key:   aws.String("xstrapolate-vpc"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("Type"),
-							Value: aws.String("private"),
-						},
-					},
-				},
-			},
+			TagSpecifications: ec2TagSpecs(types.ResourceTypeSubnet, tags.For("subnet", map[string]string{
+				"Name":            fmt.Sprintf("xstrapolate-ssm-private-%d", i+1),
+				"xstrapolate-vpc": "true",
+				"Type":            "private",
+			})),
 		})
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create private subnet %d: %w", i+1, err)
@@ -649,43 +593,33 @@ func (m *AWSManager) createVPCAndSubnetsForSSM() ([]string, []string, error) {
 	}
 
 	// Create VPC endpoints for SSM
-	err = m.createSSMVPCEndpoints(vpcId, privateSubnetIds)
+	err = m.createSSMVPCEndpoints(ctx, vpcId, privateSubnetIds, tags)
 	if err != nil {
-		fmt.Printf("Warning: failed to create VPC endpoints: %v\n", err)
+		slog.Warn("failed to create VPC endpoints", "vpc", vpcId, "err", err)
+	}
+
+	// Spot interruption snapshots are uploaded to S3 from the private subnets
+	if withS3Endpoint {
+		err = m.createS3GatewayEndpoint(ctx, vpcId, tags)
+		if err != nil {
+			slog.Warn("failed to create S3 gateway endpoint", "vpc", vpcId, "err", err)
+		}
 	}
 
-	fmt.Printf("Created VPC with %d private subnets and SSM VPC endpoints\n", len(privateSubnetIds))
+	slog.Info("Created VPC subnets and SSM VPC endpoints", "private", len(privateSubnetIds))
 
 	return []string{}, privateSubnetIds, nil
 }
 
-func (m *AWSManager) createSSMVPCEndpoints(vpcId string, subnetIds []string) error {
-	fmt.Println("Creating VPC endpoints for SSM access...")
+func (m *AWSManager) createSSMVPCEndpoints(ctx context.Context, vpcId string, subnetIds []string, tags TagBuilder) error {
+	slog.Info("Creating VPC endpoints for SSM access...")
 
 	// Create security group for VPC endpoints
-	sgResult, err := m.ec2Client.CreateSecurityGroup(context.TODO(), &ec2.CreateSecurityGroupInput{
-		GroupName:   aws.String("xstrapolate-ssm-endpoints"),
-		Description: aws.String("Security group for SSM VPC endpoints"),
-		VpcId:       aws.String(vpcId),
-		TagSpecifications: []types.TagSpecification{
-			{
-				ResourceType: types.ResourceTypeSecurityGroup,
-				Tags: []types.Tag{
-					{
-						Key:   aws.String("Name"),
-						Value: aws.String("xstrapolate-ssm-endpoints"),
-					},
-					{
-						Key:   aws.String("xstrapolate-managed"),
-						Value: aws.String("true"),
-					},
-					{
-						Key:   aws.String("xstrapolate-resource-type"),
-						Value: aws.String("security-group"),
-					},
-				},
-			},
-		},
+	sgResult, err := m.ec2Client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:         aws.String("xstrapolate-ssm-endpoints"),
+		Description:       aws.String("Security group for SSM VPC endpoints"),
+		VpcId:             aws.String(vpcId),
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeSecurityGroup, tags.For("security-group", map[string]string{"Name": "xstrapolate-ssm-endpoints"})),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create security group: %w", err)
@@ -694,7 +628,7 @@ func (m *AWSManager) createSSMVPCEndpoints(vpcId string, subnetIds []string) err
 	sgId := aws.ToString(sgResult.GroupId)
 
 	// Allow HTTPS traffic from VPC CIDR
-	_, err = m.ec2Client.AuthorizeSecurityGroupIngress(context.TODO(), &ec2.AuthorizeSecurityGroupIngressInput{
+	_, err = m.ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId: aws.String(sgId),
 		IpPermissions: []types.IpPermission{
 			{
@@ -703,14 +637,14 @@ func (m *AWSManager) createSSMVPCEndpoints(vpcId string, subnetIds []string) err
 				ToPort:     aws.Int32(443),
 				IpRanges: []types.IpRange{
 					{
-						CidrIp: aws.String("10.0.0.0/16"),
+						CidrIp: aws.String(m.network.cidr),
 					},
 				},
 			},
 		},
 	})
 	if err != nil {
-		fmt.Printf("Warning: failed to add security group rule: %v\n", err)
+		slog.Warn("failed to add security group rule", "err", err)
 	}
 
 	// SSM requires these three VPC endpoints
@@ -721,46 +655,85 @@ func (m *AWSManager) createSSMVPCEndpoints(vpcId string, subnetIds []string) err
 	}
 
 	for _, endpoint := range endpoints {
-		fmt.Printf("Creating VPC endpoint: %s\n", endpoint)
-		_, err = m.ec2Client.CreateVpcEndpoint(context.TODO(), &ec2.CreateVpcEndpointInput{
-			VpcId:           aws.String(vpcId),
-			ServiceName:     aws.String(endpoint),
-			VpcEndpointType: types.VpcEndpointTypeInterface,
-			SubnetIds:       subnetIds,
-			SecurityGroupIds: []string{sgId},
+		slog.Debug("Creating VPC endpoint", "service", endpoint)
+		_, err = m.ec2Client.CreateVpcEndpoint(ctx, &ec2.CreateVpcEndpointInput{
+			VpcId:             aws.String(vpcId),
+			ServiceName:       aws.String(endpoint),
+			VpcEndpointType:   types.VpcEndpointTypeInterface,
+			SubnetIds:         subnetIds,
+			SecurityGroupIds:  []string{sgId},
 			PrivateDnsEnabled: aws.Bool(true),
-			TagSpecifications: []types.TagSpecification{
-				{
-					ResourceType: types.ResourceTypeVpcEndpoint,
-					Tags: []types.Tag{
-						{
-							Key:   aws.String("Name"),
-							Value: aws.String("xstrapolate-" + strings.Split(endpoint, ".")[3]),
-						},
-						{
-							Key:   aws.String("xstrapolate-managed"),
-							Value: aws.String("true"),
-						},
-						{
-							Key:   aws.String("xstrapolate-resource-type"),
-							Value: aws.String("vpc-endpoint"),
-						},
-					},
-				},
-			},
+			TagSpecifications: ec2TagSpecs(types.ResourceTypeVpcEndpoint, tags.For("vpc-endpoint", map[string]string{"Name": "xstrapolate-" + strings.Split(endpoint, ".")[3]})),
 		})
 		if err != nil {
-			fmt.Printf("Warning: failed to create VPC endpoint %s: %v\n", endpoint, err)
+			slog.Warn("failed to create VPC endpoint", "service", endpoint, "err", err)
 		}
 	}
 
-	fmt.Println("VPC endpoints created successfully")
+	slog.Info("VPC endpoints created successfully")
 	return nil
 }
 
-func (m *AWSManager) createEC2Instance(name string) (string, error) {
+func (m *AWSManager) createS3GatewayEndpoint(ctx context.Context, vpcId string, tags TagBuilder) error {
+	slog.Info("Creating S3 gateway endpoint...")
+
+	// Private subnets use the VPC's main route table
+	rtResult, err := m.ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcId},
+			},
+			{
+				Name:   aws.String("association.main"),
+				Values: []string{"true"},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find main route table: %w", err)
+	}
+	if len(rtResult.RouteTables) == 0 {
+		return fmt.Errorf("no main route table found for VPC %s", vpcId)
+	}
+
+	_, err = m.ec2Client.CreateVpcEndpoint(ctx, &ec2.CreateVpcEndpointInput{
+		VpcId:             aws.String(vpcId),
+		ServiceName:       aws.String("com.amazonaws." + m.region + ".s3"),
+		VpcEndpointType:   types.VpcEndpointTypeGateway,
+		RouteTableIds:     []string{aws.ToString(rtResult.RouteTables[0].RouteTableId)},
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeVpcEndpoint, tags.For("vpc-endpoint", map[string]string{"Name": "xstrapolate-s3"})),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create S3 gateway endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (m *AWSManager) createEC2Instance(ctx context.Context, name string, createdAt time.Time, tags TagBuilder) (string, error) {
+	arch, err := m.resolveInstanceArchitecture(ctx, m.instance.Type)
+	if err != nil {
+		return "", err
+	}
+	slog.Info("Using instance type", "type", m.instance.Type, "arch", arch)
+
+	marketOptions, err := m.spotMarketOptions()
+	if err != nil {
+		return "", err
+	}
+	if marketOptions != nil && m.lifetime.AutoStop != "" {
+		return "", fmt.Errorf("--auto-stop cannot be used with spot instances, which terminate on shutdown")
+	}
+
+	// Render user data first so invalid input fails before anything is created
+	userData, err := m.generateUserData(name, arch, createdAt)
+	if err != nil {
+		return "", err
+	}
+
 	// Create VPC and subnets for the EC2 instance
-	_, privateSubnetIds, err := m.createVPCAndSubnetsForSSM()
+	_, privateSubnetIds, err := m.createVPCAndSubnetsForSSM(ctx, tags, m.instance.Spot.SnapshotBucket != "")
 	if err != nil {
 		return "", fmt.Errorf("failed to create VPC and subnets: %w", err)
 	}
@@ -768,59 +741,68 @@ func (m *AWSManager) createEC2Instance(name string) (string, error) {
 	// Use the first private subnet for the EC2 instance (SSM access only)
 	subnetId := privateSubnetIds[0]
 
-	// Get the latest Amazon Linux 2023 AMI for current region
-	amiId, err := m.getLatestAmazonLinuxAMI()
+	// Get the latest Amazon Linux 2023 AMI for current region and architecture
+	image, err := m.getLatestAmazonLinuxAMI(ctx, arch)
 	if err != nil {
 		return "", fmt.Errorf("failed to get latest AMI: %w", err)
 	}
 
-	userData := m.generateUserData(name)
+	instanceTags := tags.With(m.lifetime.Tags(createdAt))
 
 	// Encode user data as base64
 	encodedUserData := base64.StdEncoding.EncodeToString([]byte(userData))
 
-	fmt.Printf("User data script length: %d bytes\n", len(userData))
+	slog.Debug("Rendered user data", "bytes", len(userData))
+
+	input := &ec2.RunInstancesInput{
+		ImageId:               image.ImageId,
+		InstanceType:          types.InstanceType(m.instance.Type),
+		MinCount:              aws.Int32(1),
+		MaxCount:              aws.Int32(1),
+		SubnetId:              aws.String(subnetId),
+		UserData:              aws.String(encodedUserData),
+		BlockDeviceMappings:   m.rootBlockDeviceMappings(image),
+		InstanceMarketOptions: marketOptions,
+		// Scheduled stops power the instance off from inside. One-time spot
+		// instances cannot be stopped, so the TTL poweroff terminates them.
+		InstanceInitiatedShutdownBehavior: instanceShutdownBehavior(marketOptions),
+		MetadataOptions:                   instanceMetadataOptions(),
+		IamInstanceProfile: &types.IamInstanceProfileSpecification{
+			Name: aws.String(ssmProfileName),
+		},
+		// The root volume carries the same tags so its cost is attributed
+		TagSpecifications: append(
+			ec2TagSpecs(types.ResourceTypeInstance, instanceTags.For("instance", map[string]string{"Name": name})),
+			ec2TagSpecs(types.ResourceTypeVolume, instanceTags.For("volume", map[string]string{"Name": name}))...,
+		),
+	}
 
 	// Retry EC2 instance creation to handle IAM propagation delays
 	var result *ec2.RunInstancesOutput
 	maxRetries := 6
 
 	for retry := 0; retry < maxRetries; retry++ {
-		result, err = m.ec2Client.RunInstances(context.TODO(), &ec2.RunInstancesInput{
-			ImageId:      aws.String(amiId),
-			InstanceType: types.InstanceTypeT3Medium,
-			MinCount:     aws.Int32(1),
-			MaxCount:     aws.Int32(1),
-			SubnetId:     aws.String(subnetId),
-			UserData:     aws.String(encodedUserData),
-			IamInstanceProfile: &types.IamInstanceProfileSpecification{
-				Name: aws.String("xstrapolate-ssm-profile"),
-			},
-			TagSpecifications: []types.TagSpecification{
-				{
-					ResourceType: types.ResourceTypeInstance,
-					Tags: []types.Tag{
-						{
-							Key:   aws.String("Name"),
-							Value: aws.String(name),
-						},
-						{
-							Key:   aws.String("xstrapolate-cluster"),
-							Value: aws.String(name),
-						},
-					},
-				},
-			},
-		})
+		result, err = m.ec2Client.RunInstances(ctx, input)
 
 		if err == nil {
 			break // Success!
 		}
 
+		// Spot capacity is not guaranteed, fall back to on-demand
+		if input.InstanceMarketOptions != nil && isSpotCapacityError(err) {
+			slog.Warn("⚠️  Spot capacity unavailable, falling back to on-demand", "err", err)
+			input.InstanceMarketOptions = nil
+			input.InstanceInitiatedShutdownBehavior = instanceShutdownBehavior(nil)
+			retry--
+			continue
+		}
+
 		// Check if it's an IAM instance profile error
-		if strings.Contains(err.Error(), "Invalid IAM Instance Profile") && retry < maxRetries-1 {
-			fmt.Printf("⏳ Retry %d/%d: IAM instance profile not yet propagated to EC2, waiting...\n", retry+1, maxRetries)
-			time.Sleep(5 * time.Second)
+		if hasAWSErrorCode(err, "InvalidParameterValue") && strings.Contains(err.Error(), "IAM Instance Profile") && retry < maxRetries-1 {
+			slog.Info("⏳ IAM instance profile not yet propagated to EC2, waiting...", "retry", retry+1, "of", maxRetries)
+			if err := sleepContext(ctx, 5*time.Second); err != nil {
+				return "", err
+			}
 			continue
 		}
 
@@ -828,12 +810,16 @@ func (m *AWSManager) createEC2Instance(name string) (string, error) {
 		return "", err
 	}
 
+	if input.InstanceMarketOptions != nil {
+		slog.Info("💸 Launched as a spot instance")
+	}
+
 	return aws.ToString(result.Instances[0].InstanceId), nil
 }
 
-func (m *AWSManager) ensureSSMInstanceProfile() error {
-	roleName := "xstrapolate-ssm-role"
-	profileName := "xstrapolate-ssm-profile"
+func (m *AWSManager) ensureSSMInstanceProfile(ctx context.Context, clusterName string, tags TagBuilder) error {
+	roleName := ssmRoleName
+	profileName := ssmProfileName
 
 	// Create IAM role for SSM
 	assumeRolePolicyDocument := `{
@@ -849,67 +835,91 @@ func (m *AWSManager) ensureSSMInstanceProfile() error {
 		]
 	}`
 
-	_, err := m.iamClient.CreateRole(context.TODO(), &iam.CreateRoleInput{
+	_, err := m.iamClient.CreateRole(ctx, &iam.CreateRoleInput{
 		RoleName:                 aws.String(roleName),
 		AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocument),
+		Tags:                     iamTags(tags.For("iam-role", nil)),
 	})
 	if err != nil {
-		fmt.Println("SSM role might already exist, continuing...")
+		slog.Debug("SSM role might already exist, continuing...")
 	}
 
 	// Attach SSM policy
-	_, err = m.iamClient.AttachRolePolicy(context.TODO(), &iam.AttachRolePolicyInput{
+	_, err = m.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
 		RoleName:  aws.String(roleName),
 		PolicyArn: aws.String("arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"),
 	})
 	if err != nil {
-		fmt.Printf("Warning: failed to attach SSM policy: %v\n", err)
+		slog.Warn("failed to attach SSM policy", "err", err)
+	}
+
+	// Allow spot interruption snapshots of this cluster to be uploaded
+	if bucket := m.instance.Spot.SnapshotBucket; bucket != "" {
+		snapshotPolicy := fmt.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [
+				{
+					"Effect": "Allow",
+					"Action": "s3:PutObject",
+					"Resource": "arn:aws:s3:::%s/xstrapolate/%s/*"
+				}
+			]
+		}`, bucket, clusterName)
+
+		_, err = m.iamClient.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+			RoleName:       aws.String(roleName),
+			PolicyName:     aws.String(spotSnapshotPolicyName(clusterName)),
+			PolicyDocument: aws.String(snapshotPolicy),
+		})
+		if err != nil {
+			slog.Warn("failed to grant snapshot bucket access", "err", err)
+		}
 	}
 
 	// Create instance profile
-	_, err = m.iamClient.CreateInstanceProfile(context.TODO(), &iam.CreateInstanceProfileInput{
+	_, err = m.iamClient.CreateInstanceProfile(ctx, &iam.CreateInstanceProfileInput{
 		InstanceProfileName: aws.String(profileName),
+		Tags:                iamTags(tags.For("instance-profile", nil)),
 	})
 	if err != nil {
-		fmt.Println("Instance profile might already exist, continuing...")
+		slog.Debug("Instance profile might already exist, continuing...")
 	}
 
 	// Add role to instance profile (only if not already attached)
-	_, err = m.iamClient.AddRoleToInstanceProfile(context.TODO(), &iam.AddRoleToInstanceProfileInput{
+	_, err = m.iamClient.AddRoleToInstanceProfile(ctx, &iam.AddRoleToInstanceProfileInput{
 		InstanceProfileName: aws.String(profileName),
 		RoleName:            aws.String(roleName),
 	})
 	if err != nil {
 		// Check if it's just because the role is already attached
-		if !strings.Contains(err.Error(), "LimitExceeded") && !strings.Contains(err.Error(), "EntityAlreadyExists") {
+		if !hasAWSErrorCode(err, "LimitExceeded", "EntityAlreadyExists") {
 			return fmt.Errorf("failed to add role to instance profile: %w", err)
 		}
-		fmt.Println("Role already attached to instance profile")
+		slog.Debug("Role already attached to instance profile")
 	} else {
-		fmt.Println("✅ Role attached to instance profile")
+		slog.Info("✅ Role attached to instance profile")
 	}
 
 	return nil
 }
 
-func (m *AWSManager) waitForInstanceProfile(profileName string) (string, error) {
-	fmt.Printf("⏳ Waiting for instance profile '%s' to be ready...\n", profileName)
+func (m *AWSManager) waitForInstanceProfile(ctx context.Context, profileName string) (string, error) {
+	slog.Info("⏳ Waiting for instance profile to be ready...", "profile", profileName)
 
 	maxAttempts := 12 // 2 minutes maximum wait
 	for i := 0; i < maxAttempts; i++ {
 		// Check if instance profile exists and is ready
-		result, err := m.iamClient.GetInstanceProfile(context.TODO(), &iam.GetInstanceProfileInput{
+		result, err := m.iamClient.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
 			InstanceProfileName: aws.String(profileName),
 		})
 
 		if err == nil {
 			profileArn := aws.ToString(result.InstanceProfile.Arn)
-			fmt.Printf("✅ Instance profile is ready: %s\n", profileArn)
-			fmt.Printf("   Instance profile name: %s\n", aws.ToString(result.InstanceProfile.InstanceProfileName))
+			slog.Info("✅ Instance profile is ready", "arn", profileArn)
 
 			// Check if role is attached
 			if len(result.InstanceProfile.Roles) > 0 {
-				fmt.Printf("   Role attached: %s\n", aws.ToString(result.InstanceProfile.Roles[0].RoleName))
+				slog.Debug("Role attached to instance profile", "role", aws.ToString(result.InstanceProfile.Roles[0].RoleName))
 			} else {
 				return "", fmt.Errorf("instance profile exists but no role is attached")
 			}
@@ -917,9 +927,11 @@ func (m *AWSManager) waitForInstanceProfile(profileName string) (string, error)
 		}
 
 		// Check if it's a "not found" error vs other error
-		if strings.Contains(err.Error(), "NoSuchEntity") || strings.Contains(err.Error(), "does not exist") {
-			fmt.Printf("  Attempt %d/%d: Instance profile not yet available...\n", i+1, maxAttempts)
-			time.Sleep(10 * time.Second)
+		if errors.Is(err, ErrNotFound) {
+			slog.Debug("Instance profile not yet available...", "attempt", i+1, "of", maxAttempts)
+			if err := sleepContext(ctx, 10*time.Second); err != nil {
+				return "", err
+			}
 			continue
 		}
 
@@ -930,14 +942,14 @@ func (m *AWSManager) waitForInstanceProfile(profileName string) (string, error)
 	return "", fmt.Errorf("timeout waiting for instance profile to be ready")
 }
 
-func (m *AWSManager) getLatestAmazonLinuxAMI() (string, error) {
+func (m *AWSManager) getLatestAmazonLinuxAMI(ctx context.Context, arch types.ArchitectureValues) (*types.Image, error) {
 	// Search for the latest Amazon Linux 2023 AMI
-	result, err := m.ec2Client.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{
+	result, err := m.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
 		Owners: []string{"amazon"},
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("name"),
-				Values: []string{"al2023-ami-*-x86_64"},
+				Values: []string{"al2023-ami-*-" + string(arch)},
 			},
 			{
 				Name:   aws.String("state"),
@@ -945,17 +957,17 @@ func (m *AWSManager) getLatestAmazonLinuxAMI() (string, error) {
 			},
 			{
 				Name:   aws.String("architecture"),
-				Values: []string{"x86_64"},
+				Values: []string{string(arch)},
 			},
 		},
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to describe AMIs: %w", err)
+		return nil, fmt.Errorf("failed to describe AMIs: %w", err)
 	}
 
 	if len(result.Images) == 0 {
-		return "", fmt.Errorf("no Amazon Linux 2023 AMIs found in region %s", m.region)
+		return nil, fmt.Errorf("no Amazon Linux 2023 AMIs found in region %s", m.region)
 	}
 
 	// Find the most recent non-minimal AMI by creation date, fallback to any AMI
@@ -985,25 +997,25 @@ func (m *AWSManager) getLatestAmazonLinuxAMI() (string, error) {
 	if latestAMI == nil {
 		latestAMI = latestMinimalAMI
 		if latestAMI != nil {
-			fmt.Println("Note: Using minimal AMI - SSM agent will be installed via user data")
+			slog.Info("Note: Using minimal AMI - SSM agent will be installed via user data")
 		}
 	}
 
 	if latestAMI == nil || latestAMI.ImageId == nil {
-		return "", fmt.Errorf("could not determine latest Amazon Linux 2023 AMI")
+		return nil, fmt.Errorf("could not determine latest Amazon Linux 2023 AMI")
 	}
 
-	fmt.Printf("Using Amazon Linux 2023 AMI: %s (%s) in %s\n",
-		aws.ToString(latestAMI.ImageId),
-		aws.ToString(latestAMI.Name),
-		m.region)
+	slog.Info("Using Amazon Linux 2023 AMI",
+		"ami", aws.ToString(latestAMI.ImageId),
+		"name", aws.ToString(latestAMI.Name),
+		"region", m.region)
 
-	return aws.ToString(latestAMI.ImageId), nil
+	return latestAMI, nil
 }
 
-func (m *AWSManager) getPrivateSubnetFromXstrapolateVPC() (string, error) {
+func (m *AWSManager) getPrivateSubnetFromXstrapolateVPC(ctx context.Context) (string, error) {
 	// Look for private subnets in our xstrapolate VPC
-	result, err := m.ec2Client.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{
+	result, err := m.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("tag:xstrapolate-vpc"),
@@ -1015,21 +1027,21 @@ func (m *AWSManager) getPrivateSubnetFromXstrapolateVPC() (string, error) {
 			},
 		},
 	})
-	
+
 	if err != nil {
 		return "", err
 	}
-	
+
 	if len(result.Subnets) > 0 {
 		return aws.ToString(result.Subnets[0].SubnetId), nil
 	}
-	
+
 	return "", fmt.Errorf("no private subnets found in xstrapolate VPC")
 }
 
-func (m *AWSManager) getAnyAvailableSubnet() (string, error) {
+func (m *AWSManager) getAnyAvailableSubnet(ctx context.Context) (string, error) {
 	// Fallback: get any available subnet
-	result, err := m.ec2Client.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{
+	result, err := m.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("state"),
@@ -1037,692 +1049,444 @@ func (m *AWSManager) getAnyAvailableSubnet() (string, error) {
 			},
 		},
 	})
-	
+
 	if err != nil {
 		return "", err
 	}
-	
+
 	if len(result.Subnets) > 0 {
 		return aws.ToString(result.Subnets[0].SubnetId), nil
 	}
-	
+
 	return "", fmt.Errorf("no available subnets found")
 }
 
-func (m *AWSManager) generateUserData(clusterName string) string {
-	userDataScript := `#!/bin/bash
-set -e
-
-# Update system
-yum update -y
-
-# Install required tools
-yum install -y curl wget git
-
-# Ensure SSM agent is installed and running
-if ! systemctl is-active --quiet amazon-ssm-agent; then
-    echo "Installing SSM agent..."
-    yum install -y amazon-ssm-agent
-    systemctl enable amazon-ssm-agent
-    systemctl start amazon-ssm-agent
-fi
-
-# Install kubectl
-curl -LO https://dl.k8s.io/release/v1.28.0/bin/linux/amd64/kubectl
-install -o root -g root -m 0755 kubectl /usr/local/bin/kubectl
-
-# Install helm
-curl -fsSL -o get_helm.sh https://raw.githubusercontent.com/helm/helm/main/scripts/get-helm-3
-chmod 700 get_helm.sh
-./get_helm.sh
-
-# Install flux CLI
-curl -s https://fluxcd.io/install.sh | bash
-mv /root/.local/bin/flux /usr/local/bin/ 2>/dev/null || true
-
-# Install k3s
-curl -sfL https://get.k3s.io | sh -s - --write-kubeconfig-mode 644
-export KUBECONFIG=/etc/rancher/k3s/k3s.yaml
-
-# Wait for k3s to be ready
-echo "Waiting for k3s to be ready..."
-sleep 30
-kubectl wait --for=condition=Ready nodes --all --timeout=300s
-
-# Install Flux
-echo "Installing Flux..."
-flux install --wait
-
-# Create basic cluster info
-echo "Creating cluster info..."
-cat > /tmp/cluster-info.yaml << EOF
-apiVersion: v1
-kind: ConfigMap
-metadata:
-  name: cluster-info
-  namespace: flux-system
-data:
-  cluster-name: "` + clusterName + `"
-  created-by: "xstrapolate"
-  flux-version: "latest"
-EOF
-
-kubectl apply -f /tmp/cluster-info.yaml
-
-echo "Setup complete! Cluster ` + clusterName + ` is ready."
-echo "Access via: aws ssm start-session --target $(curl -s http://169.254.169.254/latest/meta-data/instance-id)"
-echo "Kubeconfig: /etc/rancher/k3s/k3s.yaml"
-`
-	return userDataScript
+func (m *AWSManager) generateUserData(clusterName string, arch types.ArchitectureValues, createdAt time.Time) (string, error) {
+	params, err := m.userDataParams(clusterName, arch, createdAt)
+	if err != nil {
+		return "", err
+	}
+	params.Spot = m.instance.Spot
+	return renderUserData(params)
+}
+
+// userDataParams fills in the bootstrap settings shared by every instance of
+// a cluster created at createdAt.
+func (m *AWSManager) userDataParams(clusterName string, arch types.ArchitectureValues, createdAt time.Time) (userDataParams, error) {
+	var autoStopCalendar, expiresAtCalendar string
+	if m.lifetime.AutoStop != "" {
+		calendar, err := systemdCalendar(m.lifetime.AutoStop)
+		if err != nil {
+			return userDataParams{}, err
+		}
+		autoStopCalendar = calendar
+	}
+	if m.lifetime.TTL > 0 {
+		expiresAtCalendar = createdAt.Add(m.lifetime.TTL).UTC().Format("2006-01-02 15:04:05") + " UTC"
+	}
+
+	return userDataParams{
+		ClusterName: clusterName,
+		Arch:        kubernetesArch(arch),
+		Bootstrap:   m.bootstrap,
+		K3s:         m.k3s,
+		GitOps:      m.gitops,
+		FluxVersion: m.components.Flux,
+
+		AutoStopCalendar:  autoStopCalendar,
+		ExpiresAtCalendar: expiresAtCalendar,
+	}, nil
 }
 
 func (m *AWSManager) generateKubeconfig(clusterName string) (string, error) {
-	home, err := os.UserHomeDir()
+	kubeconfigPath, err := clusterKubeconfigPath(clusterName)
 	if err != nil {
 		return "", err
 	}
 
-	kubeconfigPath := filepath.Join(home, ".kube", fmt.Sprintf("config-%s", clusterName))
-
 	// This would normally generate the kubeconfig using AWS CLI equivalent
 	// For now, return the path where it should be
-	fmt.Printf("Generate kubeconfig with: aws eks update-kubeconfig --region %s --name %s --kubeconfig %s\n",
-		m.region, clusterName, kubeconfigPath)
+	slog.Info(fmt.Sprintf("Generate kubeconfig with: aws eks update-kubeconfig --region %s --name %s --kubeconfig %s",
+		m.region, clusterName, kubeconfigPath))
 
 	return kubeconfigPath, nil
 }
 
-func (m *AWSManager) getAccountID() (string, error) {
-	result, err := m.stsClient.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+// clusterKubeconfigPath is where the kubeconfig of an EKS or local cluster
+// is kept on this machine.
+func clusterKubeconfigPath(clusterName string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube", fmt.Sprintf("config-%s", clusterName)), nil
+}
+
+func (m *AWSManager) getAccountID(ctx context.Context) (string, error) {
+	result, err := m.stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
 		return "", fmt.Errorf("failed to get caller identity: %w", err)
 	}
 	return aws.ToString(result.Account), nil
 }
 
-func (m *AWSManager) DeleteCluster(name string) error {
-	fmt.Printf("🔍 Finding resources for cluster '%s'...\n", name)
+func (m *AWSManager) DeleteCluster(ctx context.Context, name string) error {
+	slog.Info("🔍 Finding resources for cluster...", "cluster", name)
 
 	// Find EC2 instances with the cluster tag
-	instances, err := m.findClusterInstances(name)
+	instances, err := m.findClusterInstances(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to find cluster instances: %w", err)
 	}
 
 	if len(instances) == 0 {
-		fmt.Println("⚠️  No instances found for this cluster")
+		slog.Warn("⚠️  No instances found for this cluster", "cluster", name)
 	}
 
+	// Resources that could not be deleted, reported together at the end
+	var failures []TeardownFailure
+
 	// Collect VPCs from instances to clean up later
 	vpcIds := make(map[string]bool)
 
+	// VPCs are tagged with the cluster name, which also finds the VPC of a
+	// create that was interrupted before its instance was launched
+	taggedVPCs, err := m.findClusterVPCs(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to find cluster VPCs: %w", err)
+	}
+	for _, vpcId := range taggedVPCs {
+		vpcIds[vpcId] = true
+	}
+
+	// Delete the EKS control plane, if this is an EKS cluster
+	eksVpcId, err := m.deleteEKSCluster(ctx, name)
+	if err != nil {
+		failures = append(failures, TeardownFailure{ID: "eks-cluster/" + name, Err: err})
+	}
+	if eksVpcId != "" {
+		vpcIds[eksVpcId] = true
+	}
+
 	// Terminate instances
+	var terminating []string
 	for _, instanceId := range instances {
-		fmt.Printf("🛑 Terminating instance: %s\n", instanceId)
-		_, err = m.ec2Client.TerminateInstances(context.TODO(), &ec2.TerminateInstancesInput{
-			InstanceIds: []string{instanceId},
-		})
-		if err != nil {
-			fmt.Printf("Warning: failed to terminate instance %s: %v\n", instanceId, err)
-		}
-
 		// Get VPC ID for this instance
-		vpcId, err := m.getInstanceVPC(instanceId)
+		vpcId, err := m.getInstanceVPC(ctx, instanceId)
 		if err == nil && vpcId != "" {
 			vpcIds[vpcId] = true
 		}
+
+		slog.Info("🛑 Terminating instance", "instance", instanceId)
+		_, err = m.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+			InstanceIds: []string{instanceId},
+		})
+		if err != nil {
+			failures = append(failures, TeardownFailure{ID: "instance/" + instanceId, Err: err})
+			continue
+		}
+		terminating = append(terminating, instanceId)
 	}
 
 	// Wait for instances to terminate
-	if len(instances) > 0 {
-		fmt.Println("⏳ Waiting for instances to terminate...")
-		for _, instanceId := range instances {
-			waiter := ec2.NewInstanceTerminatedWaiter(m.ec2Client)
-			err = waiter.Wait(context.TODO(), &ec2.DescribeInstancesInput{
-				InstanceIds: []string{instanceId},
-			}, 5*time.Minute)
-			if err != nil {
-				fmt.Printf("Warning: timeout waiting for instance %s to terminate: %v\n", instanceId, err)
+	if len(terminating) > 0 {
+		slog.Info("⏳ Waiting for instances to terminate...")
+		waiter := ec2.NewInstanceTerminatedWaiter(m.ec2Client)
+		err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+			InstanceIds: terminating,
+		}, waitTimeout(ctx, 5*time.Minute))
+		if err != nil {
+			for _, instanceId := range terminating {
+				failures = append(failures, TeardownFailure{ID: "instance/" + instanceId, Err: err})
 			}
+		} else {
+			slog.Info("✅ All instances terminated")
 		}
-		fmt.Println("✅ All instances terminated")
 	}
 
 	// Clean up VPCs and associated resources (only xstrapolate-managed VPCs)
+	var managedVPCs []string
 	for vpcId := range vpcIds {
 		// Verify this is an xstrapolate-managed VPC before deletion
-		isManaged, err := m.isXstrapolateManagedVPC(vpcId)
+		isManaged, err := m.isXstrapolateManagedVPC(ctx, vpcId)
 		if err != nil {
-			fmt.Printf("Warning: failed to check VPC %s management status: %v\n", vpcId, err)
+			failures = append(failures, TeardownFailure{ID: "vpc/" + vpcId, Err: err})
 			continue
 		}
 		if !isManaged {
-			fmt.Printf("⏭️  Skipping VPC %s (not managed by xstrapolate)\n", vpcId)
+			slog.Info("⏭️  Skipping VPC not managed by xstrapolate", "vpc", vpcId)
 			continue
 		}
+		managedVPCs = append(managedVPCs, vpcId)
+	}
 
-		err = m.deleteVPCResources(vpcId)
-		if err != nil {
-			fmt.Printf("Warning: failed to clean up VPC %s: %v\n", vpcId, err)
+	// A VPC cannot be emptied while instances are still shutting down in it
+	if len(managedVPCs) > 0 && len(failures) == 0 {
+		if err := m.teardownVPCs(ctx, managedVPCs); err != nil {
+			failures = append(failures, teardownFailures("vpc", err)...)
+		}
+	} else {
+		for _, vpcId := range managedVPCs {
+			failures = append(failures, TeardownFailure{ID: "vpc/" + vpcId, Err: fmt.Errorf("skipped, cluster resources could not be deleted")})
 		}
 	}
 
-	// Clean up IAM resources
-	err = m.deleteIAMResources()
-	if err != nil {
-		fmt.Printf("Warning: failed to clean up IAM resources: %v\n", err)
+	if err := m.deleteK3sToken(ctx, name); err != nil {
+		failures = append(failures, TeardownFailure{ID: "ssm-parameter/" + strings.TrimPrefix(k3sTokenParameter(name), "/"), Err: err})
 	}
 
-	fmt.Println("🧹 Cleanup complete!")
-	return nil
-}
-
-func (m *AWSManager) findClusterInstances(clusterName string) ([]string, error) {
-	result, err := m.ec2Client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("tag:xstrapolate-cluster"),
-				Values: []string{clusterName},
-			},
-			{
-				Name:   aws.String("instance-state-name"),
-				Values: []string{"running", "stopped", "stopping", "pending"},
-			},
-		},
-	})
-	if err != nil {
-		return nil, err
+	if err := m.deleteK3sNodeAccess(ctx, name); err != nil {
+		failures = append(failures, TeardownFailure{ID: "iam-role/" + k3sNodeRoleName(name), Err: err})
 	}
 
-	var instanceIds []string
-	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
-		}
+	if err := m.deleteSpotSnapshotPolicy(ctx, name); err != nil {
+		failures = append(failures, TeardownFailure{ID: "iam-policy/" + spotSnapshotPolicyName(name), Err: err})
 	}
 
-	return instanceIds, nil
-}
+	// The SSM role and the EKS roles are shared by every cluster in the
+	// account; 'gc --all-regions' removes them once no cluster is left
 
-func (m *AWSManager) getInstanceVPC(instanceId string) (string, error) {
-	result, err := m.ec2Client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceId},
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if len(result.Reservations) > 0 && len(result.Reservations[0].Instances) > 0 {
-		return aws.ToString(result.Reservations[0].Instances[0].VpcId), nil
+	if len(failures) > 0 {
+		return &TeardownError{Remaining: failures}
 	}
 
-	return "", fmt.Errorf("instance not found")
+	slog.Info("🧹 Cleanup complete!")
+	return nil
 }
 
-func (m *AWSManager) isXstrapolateManagedVPC(vpcId string) (bool, error) {
-	result, err := m.ec2Client.DescribeVpcs(context.TODO(), &ec2.DescribeVpcsInput{
-		VpcIds: []string{vpcId},
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("tag:xstrapolate-managed"),
-				Values: []string{"true"},
-			},
-		},
+// deleteEKSCluster deletes an xstrapolate-managed EKS cluster and returns its
+// VPC so the caller can clean it up. Unknown clusters are skipped.
+func (m *AWSManager) deleteEKSCluster(ctx context.Context, name string) (string, error) {
+	result, err := m.eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{
+		Name: aws.String(name),
 	})
 	if err != nil {
-		return false, err
-	}
-
-	return len(result.Vpcs) > 0, nil
-}
-
-func (m *AWSManager) deleteVPCResources(vpcId string) error {
-	fmt.Printf("🗑️  Cleaning up VPC: %s\n", vpcId)
-
-	// Delete VPC endpoints first and wait for deletion
-	endpointIds, err := m.deleteVPCEndpoints(vpcId)
-	if err != nil {
-		fmt.Printf("Warning: failed to delete VPC endpoints: %v\n", err)
-	}
-
-	// Wait for VPC endpoints to be fully deleted
-	if len(endpointIds) > 0 {
-		fmt.Println("⏳ Waiting for VPC endpoints to be deleted...")
-		err = m.waitForVPCEndpointsDeletion(endpointIds)
-		if err != nil {
-			fmt.Printf("Warning: timeout waiting for VPC endpoints deletion: %v\n", err)
-		} else {
-			fmt.Println("✅ VPC endpoints deleted")
+		if errors.Is(err, ErrNotFound) {
+			return "", nil
 		}
-
-		// Wait a bit more for ENIs to be cleaned up
-		fmt.Println("⏳ Waiting for network interfaces to be cleaned up...")
-		time.Sleep(30 * time.Second)
-	}
-
-	// Delete NAT gateways (if any)
-	err = m.deleteNATGateways(vpcId)
-	if err != nil {
-		fmt.Printf("Warning: failed to delete NAT gateways: %v\n", err)
-	}
-
-	// Detach and delete internet gateways
-	err = m.deleteInternetGateways(vpcId)
-	if err != nil {
-		fmt.Printf("Warning: failed to delete internet gateways: %v\n", err)
+		return "", err
 	}
 
-	// Delete route tables (except main)
-	err = m.deleteRouteTables(vpcId)
-	if err != nil {
-		fmt.Printf("Warning: failed to delete route tables: %v\n", err)
+	if result.Cluster.Tags[managedTag] != "true" {
+		slog.Info("⏭️  Skipping EKS cluster not managed by xstrapolate", "cluster", name)
+		return "", nil
 	}
 
-	// Delete security groups (except default)
-	err = m.deleteSecurityGroups(vpcId)
-	if err != nil {
-		fmt.Printf("Warning: failed to delete security groups: %v\n", err)
+	var vpcId string
+	if result.Cluster.ResourcesVpcConfig != nil {
+		vpcId = aws.ToString(result.Cluster.ResourcesVpcConfig.VpcId)
 	}
 
-	// Delete subnets (now safe since VPC endpoints are gone)
-	err = m.deleteSubnets(vpcId)
-	if err != nil {
-		fmt.Printf("Warning: failed to delete subnets: %v\n", err)
+	if err := m.deleteNodePools(ctx, name); err != nil {
+		return vpcId, err
 	}
 
-	// Finally, delete the VPC
-	_, err = m.ec2Client.DeleteVpc(context.TODO(), &ec2.DeleteVpcInput{
-		VpcId: aws.String(vpcId),
+	slog.Info("🛑 Deleting EKS cluster", "cluster", name)
+	_, err = m.eksClient.DeleteCluster(ctx, &eks.DeleteClusterInput{
+		Name: aws.String(name),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete VPC: %w", err)
+		return vpcId, err
 	}
 
-	fmt.Printf("✅ VPC %s deleted\n", vpcId)
-	return nil
-}
-
-func (m *AWSManager) deleteVPCEndpoints(vpcId string) ([]string, error) {
-	result, err := m.ec2Client.DescribeVpcEndpoints(context.TODO(), &ec2.DescribeVpcEndpointsInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []string{vpcId},
-			},
-			{
-				Name:   aws.String("tag:xstrapolate-managed"),
-				Values: []string{"true"},
-			},
-		},
-	})
+	slog.Info("⏳ Waiting for EKS cluster to be deleted...")
+	waiter := eks.NewClusterDeletedWaiter(m.eksClient)
+	err = waiter.Wait(ctx, &eks.DescribeClusterInput{
+		Name: aws.String(name),
+	}, waitTimeout(ctx, 20*time.Minute))
 	if err != nil {
-		return nil, err
-	}
-
-	var endpointIds []string
-	for _, endpoint := range result.VpcEndpoints {
-		endpointId := aws.ToString(endpoint.VpcEndpointId)
-		endpointIds = append(endpointIds, endpointId)
-		fmt.Printf("  Deleting VPC endpoint: %s\n", endpointId)
-		_, err = m.ec2Client.DeleteVpcEndpoints(context.TODO(), &ec2.DeleteVpcEndpointsInput{
-			VpcEndpointIds: []string{endpointId},
-		})
-		if err != nil {
-			fmt.Printf("    Warning: failed to delete VPC endpoint %s: %v\n", endpointId, err)
-		}
+		return vpcId, fmt.Errorf("failed waiting for EKS cluster deletion: %w", err)
 	}
 
-	return endpointIds, nil
+	slog.Info("✅ EKS cluster deleted", "cluster", name)
+	return vpcId, nil
 }
 
-func (m *AWSManager) waitForVPCEndpointsDeletion(endpointIds []string) error {
-	// Wait up to 5 minutes for all VPC endpoints to be deleted
-	maxWait := 5 * time.Minute
-	checkInterval := 10 * time.Second
-	timeout := time.After(maxWait)
-	ticker := time.NewTicker(checkInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-timeout:
-			return fmt.Errorf("timeout waiting for VPC endpoints to be deleted")
-		case <-ticker.C:
-			// Check if any endpoints still exist
-			result, err := m.ec2Client.DescribeVpcEndpoints(context.TODO(), &ec2.DescribeVpcEndpointsInput{
-				VpcEndpointIds: endpointIds,
-			})
-			if err != nil {
-				// If we get an error describing endpoints, they might be deleted
-				// Check if it's a "not found" type error
-				if strings.Contains(err.Error(), "InvalidVpcEndpointId.NotFound") ||
-				   strings.Contains(err.Error(), "does not exist") {
-					return nil // All endpoints deleted
-				}
-				return err
-			}
-
-			// Count how many endpoints are still in "deleting" or other states
-			stillExists := 0
-			for _, endpoint := range result.VpcEndpoints {
-				state := string(endpoint.State)
-				// VPC endpoints in "deleted" state won't be returned, so any returned endpoint still exists
-				if state != "deleted" {
-					stillExists++
-				}
-			}
-
-			if stillExists == 0 {
-				return nil // All endpoints deleted
-			}
-
-			fmt.Printf("  Still waiting for %d VPC endpoints to finish deleting...\n", stillExists)
-		}
-	}
-}
-
-func (m *AWSManager) deleteNATGateways(vpcId string) error {
-	result, err := m.ec2Client.DescribeNatGateways(context.TODO(), &ec2.DescribeNatGatewaysInput{
-		Filter: []types.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []string{vpcId},
-			},
-			{
-				Name:   aws.String("state"),
-				Values: []string{"available"},
-			},
-		},
-	})
+func (m *AWSManager) findClusterInstances(ctx context.Context, clusterName string) ([]string, error) {
+	instances, err := m.describeClusterInstances(ctx, clusterName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, natGw := range result.NatGateways {
-		natGwId := aws.ToString(natGw.NatGatewayId)
-		fmt.Printf("  Deleting NAT gateway: %s\n", natGwId)
-		_, err = m.ec2Client.DeleteNatGateway(context.TODO(), &ec2.DeleteNatGatewayInput{
-			NatGatewayId: aws.String(natGwId),
-		})
-		if err != nil {
-			fmt.Printf("    Warning: failed to delete NAT gateway %s: %v\n", natGwId, err)
-		}
+	var instanceIds []string
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
 	}
 
-	return nil
+	return instanceIds, nil
 }
 
-func (m *AWSManager) deleteInternetGateways(vpcId string) error {
-	result, err := m.ec2Client.DescribeInternetGateways(context.TODO(), &ec2.DescribeInternetGatewaysInput{
+func (m *AWSManager) describeClusterInstances(ctx context.Context, clusterName string) ([]types.Instance, error) {
+	result, err := m.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		Filters: []types.Filter{
 			{
-				Name:   aws.String("attachment.vpc-id"),
-				Values: []string{vpcId},
+				Name:   aws.String("tag:" + clusterTag),
+				Values: []string{clusterName},
 			},
 			{
-				Name:   aws.String("tag:xstrapolate-managed"),
-				Values: []string{"true"},
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"running", "stopped", "stopping", "pending"},
 			},
 		},
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, igw := range result.InternetGateways {
-		igwId := aws.ToString(igw.InternetGatewayId)
-		fmt.Printf("  Detaching and deleting internet gateway: %s\n", igwId)
-
-		// Detach first
-		_, err = m.ec2Client.DetachInternetGateway(context.TODO(), &ec2.DetachInternetGatewayInput{
-			InternetGatewayId: aws.String(igwId),
-			VpcId:             aws.String(vpcId),
-		})
-		if err != nil {
-			fmt.Printf("    Warning: failed to detach internet gateway %s: %v\n", igwId, err)
-		}
-
-		// Then delete
-		_, err = m.ec2Client.DeleteInternetGateway(context.TODO(), &ec2.DeleteInternetGatewayInput{
-			InternetGatewayId: aws.String(igwId),
-		})
-		if err != nil {
-			fmt.Printf("    Warning: failed to delete internet gateway %s: %v\n", igwId, err)
-		}
+	var instances []types.Instance
+	for _, reservation := range result.Reservations {
+		instances = append(instances, reservation.Instances...)
 	}
 
-	return nil
+	return instances, nil
 }
 
-func (m *AWSManager) deleteRouteTables(vpcId string) error {
-	result, err := m.ec2Client.DescribeRouteTables(context.TODO(), &ec2.DescribeRouteTablesInput{
+func (m *AWSManager) findClusterVPCs(ctx context.Context, clusterName string) ([]string, error) {
+	result, err := m.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
 		Filters: []types.Filter{
 			{
-				Name:   aws.String("vpc-id"),
-				Values: []string{vpcId},
-			},
-			{
-				Name:   aws.String("association.main"),
-				Values: []string{"false"}, // Don't delete main route table
+				Name:   aws.String("tag:" + clusterTag),
+				Values: []string{clusterName},
 			},
 			{
-				Name:   aws.String("tag:xstrapolate-managed"),
+				Name:   aws.String("tag:" + managedTag),
 				Values: []string{"true"},
 			},
 		},
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, rt := range result.RouteTables {
-		rtId := aws.ToString(rt.RouteTableId)
-		fmt.Printf("  Deleting route table: %s\n", rtId)
-		_, err = m.ec2Client.DeleteRouteTable(context.TODO(), &ec2.DeleteRouteTableInput{
-			RouteTableId: aws.String(rtId),
-		})
-		if err != nil {
-			fmt.Printf("    Warning: failed to delete route table %s: %v\n", rtId, err)
-		}
+	var vpcIds []string
+	for _, vpc := range result.Vpcs {
+		vpcIds = append(vpcIds, aws.ToString(vpc.VpcId))
 	}
 
-	return nil
+	return vpcIds, nil
 }
 
-func (m *AWSManager) deleteSecurityGroups(vpcId string) error {
-	result, err := m.ec2Client.DescribeSecurityGroups(context.TODO(), &ec2.DescribeSecurityGroupsInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []string{vpcId},
-			},
-			{
-				Name:   aws.String("tag:xstrapolate-managed"),
-				Values: []string{"true"},
-			},
-		},
+func (m *AWSManager) getInstanceVPC(ctx context.Context, instanceId string) (string, error) {
+	result, err := m.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceId},
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	for _, sg := range result.SecurityGroups {
-		// Skip default security group
-		if aws.ToString(sg.GroupName) == "default" {
-			continue
-		}
-
-		sgId := aws.ToString(sg.GroupId)
-		fmt.Printf("  Deleting security group: %s\n", sgId)
-
-		// Retry security group deletion with backoff
-		maxRetries := 3
-		for retry := 0; retry < maxRetries; retry++ {
-			_, err = m.ec2Client.DeleteSecurityGroup(context.TODO(), &ec2.DeleteSecurityGroupInput{
-				GroupId: aws.String(sgId),
-			})
-			if err == nil {
-				break
-			}
-
-			if retry < maxRetries-1 && strings.Contains(err.Error(), "DependencyViolation") {
-				fmt.Printf("    Retry %d/%d: dependency violation, waiting...\n", retry+1, maxRetries)
-				time.Sleep(10 * time.Second)
-				continue
-			}
-
-			fmt.Printf("    Warning: failed to delete security group %s: %v\n", sgId, err)
-			break
-		}
+	if len(result.Reservations) > 0 && len(result.Reservations[0].Instances) > 0 {
+		return aws.ToString(result.Reservations[0].Instances[0].VpcId), nil
 	}
 
-	return nil
+	return "", fmt.Errorf("instance not found")
 }
 
-func (m *AWSManager) deleteSubnets(vpcId string) error {
-	result, err := m.ec2Client.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{
+func (m *AWSManager) isXstrapolateManagedVPC(ctx context.Context, vpcId string) (bool, error) {
+	result, err := m.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+		VpcIds: []string{vpcId},
 		Filters: []types.Filter{
 			{
-				Name:   aws.String("vpc-id"),
-				Values: []string{vpcId},
-			},
-			{
-				Name:   aws.String("tag:xstrapolate-managed"),
+				Name:   aws.String("tag:" + managedTag),
 				Values: []string{"true"},
 			},
 		},
 	})
 	if err != nil {
-		return err
-	}
-
-	for _, subnet := range result.Subnets {
-		subnetId := aws.ToString(subnet.SubnetId)
-		fmt.Printf("  Deleting subnet: %s\n", subnetId)
-
-		// Retry subnet deletion with backoff
-		maxRetries := 3
-		for retry := 0; retry < maxRetries; retry++ {
-			_, err = m.ec2Client.DeleteSubnet(context.TODO(), &ec2.DeleteSubnetInput{
-				SubnetId: aws.String(subnetId),
-			})
-			if err == nil {
-				break
-			}
-
-			if retry < maxRetries-1 && strings.Contains(err.Error(), "DependencyViolation") {
-				fmt.Printf("    Retry %d/%d: dependency violation, waiting...\n", retry+1, maxRetries)
-				time.Sleep(10 * time.Second)
-				continue
-			}
-
-			fmt.Printf("    Warning: failed to delete subnet %s: %v\n", subnetId, err)
-			break
-		}
+		return false, err
 	}
 
-	return nil
+	return len(result.Vpcs) > 0, nil
 }
 
-func (m *AWSManager) deleteIAMResources() error {
-	fmt.Println("🗑️  Cleaning up IAM resources...")
-
-	// Delete SSM instance profile and role
-	err := m.deleteSSMRole()
-	if err != nil {
-		fmt.Printf("Warning: failed to delete SSM role: %v\n", err)
-	}
-
-	// Delete EKS service role (if exists)
-	err = m.deleteEKSRole()
-	if err != nil {
-		fmt.Printf("Warning: failed to delete EKS role: %v\n", err)
+// deleteSpotSnapshotPolicy revokes the snapshot upload grant of a cluster
+// from the shared SSM role.
+func (m *AWSManager) deleteSpotSnapshotPolicy(ctx context.Context, clusterName string) error {
+	_, err := m.iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(ssmRoleName),
+		PolicyName: aws.String(spotSnapshotPolicyName(clusterName)),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete snapshot policy: %w", err)
 	}
-
 	return nil
 }
 
-func (m *AWSManager) deleteSSMRole() error {
-	roleName := "xstrapolate-ssm-role"
-	profileName := "xstrapolate-ssm-profile"
+func (m *AWSManager) deleteSSMRole(ctx context.Context) error {
+	roleName := ssmRoleName
+	profileName := ssmProfileName
 
 	// Remove role from instance profile
-	_, err := m.iamClient.RemoveRoleFromInstanceProfile(context.TODO(), &iam.RemoveRoleFromInstanceProfileInput{
+	_, err := m.iamClient.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
 		InstanceProfileName: aws.String(profileName),
 		RoleName:            aws.String(roleName),
 	})
 	if err != nil {
-		fmt.Printf("  Warning: failed to remove role from instance profile: %v\n", err)
+		slog.Warn("failed to remove role from instance profile", "err", err)
 	}
 
 	// Delete instance profile
-	_, err = m.iamClient.DeleteInstanceProfile(context.TODO(), &iam.DeleteInstanceProfileInput{
+	_, err = m.iamClient.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{
 		InstanceProfileName: aws.String(profileName),
 	})
 	if err != nil {
-		fmt.Printf("  Warning: failed to delete instance profile: %v\n", err)
+		slog.Warn("failed to delete instance profile", "err", err)
 	}
 
 	// Detach policy from role
-	_, err = m.iamClient.DetachRolePolicy(context.TODO(), &iam.DetachRolePolicyInput{
+	_, err = m.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
 		RoleName:  aws.String(roleName),
 		PolicyArn: aws.String("arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"),
 	})
 	if err != nil {
-		fmt.Printf("  Warning: failed to detach policy from role: %v\n", err)
+		slog.Warn("failed to detach policy from role", "err", err)
 	}
 
 	// Delete role
-	_, err = m.iamClient.DeleteRole(context.TODO(), &iam.DeleteRoleInput{
+	_, err = m.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
 		RoleName: aws.String(roleName),
 	})
 	if err != nil {
-		fmt.Printf("  Warning: failed to delete SSM role: %v\n", err)
+		slog.Warn("failed to delete SSM role", "err", err)
 	} else {
-		fmt.Printf("  ✅ Deleted SSM role and instance profile\n")
+		slog.Info("✅ Deleted SSM role and instance profile")
 	}
 
 	return nil
 }
 
-func (m *AWSManager) deleteEKSRole() error {
-	roleName := "xstrapolate-eks-service-role"
+func (m *AWSManager) deleteEKSRole(ctx context.Context) error {
+	roleName := eksServiceRoleName
 
 	// Check if role exists first
-	_, err := m.iamClient.GetRole(context.TODO(), &iam.GetRoleInput{
+	_, err := m.iamClient.GetRole(ctx, &iam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	})
 	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchEntity") {
-			fmt.Printf("  EKS service role '%s' does not exist, skipping\n", roleName)
+		if errors.Is(err, ErrNotFound) {
+			slog.Debug("EKS service role does not exist, skipping", "role", roleName)
 			return nil
 		}
 		return fmt.Errorf("failed to check EKS role: %w", err)
 	}
 
 	// Detach policy from role
-	_, err = m.iamClient.DetachRolePolicy(context.TODO(), &iam.DetachRolePolicyInput{
+	_, err = m.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
 		RoleName:  aws.String(roleName),
 		PolicyArn: aws.String("arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"),
 	})
 	if err != nil {
-		fmt.Printf("  Warning: failed to detach policy from EKS role: %v\n", err)
+		slog.Warn("failed to detach policy from EKS role", "err", err)
 	}
 
 	// Delete role
-	_, err = m.iamClient.DeleteRole(context.TODO(), &iam.DeleteRoleInput{
+	_, err = m.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
 		RoleName: aws.String(roleName),
 	})
 	if err != nil {
-		fmt.Printf("  Warning: failed to delete EKS role: %v\n", err)
+		slog.Warn("failed to delete EKS role", "err", err)
 	} else {
-		fmt.Printf("  ✅ Deleted EKS service role\n")
+		slog.Info("✅ Deleted EKS service role")
 	}
 
 	return nil
 }
-
-func (m *AWSManager) GetCluster(name string) (*ClusterInfo, error) {
-	// Implementation for getting cluster info
-	return nil, fmt.Errorf("get cluster not implemented yet")
-}
\ No newline at end of file
@@ -0,0 +1,332 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/drduker/xstrapolate/pkg/config"
+)
+
+// Single-node and k3s clusters grow with k3s agent instances. Agents carry
+// their own resource type tag so they can be told apart from the servers.
+const (
+	k3sAgentResourceType = "k3s-agent"
+	k3sAgentPoolName     = "agents"
+
+	// Join token of the k3s server, read over SSM when a single-node cluster
+	// gets its first agents
+	k3sNodeTokenPath = "/var/lib/rancher/k3s/server/node-token"
+)
+
+// k3sNodes are the instances of a single-node or k3s cluster by role.
+type k3sNodes struct {
+	// server initialised the cluster and runs its bootstrap script
+	server *types.Instance
+	// servers joined the HA control plane of a k3s cluster
+	servers []types.Instance
+	agents  []types.Instance
+}
+
+func (n k3sNodes) clusterType() string {
+	if ec2TagMap(n.server.Tags)[clusterTypeTag] == k3sClusterType {
+		return k3sClusterType
+	}
+	return "single-node"
+}
+
+// serverPool is the node pool name of the servers.
+func (n k3sNodes) serverPool() string {
+	if n.clusterType() == k3sClusterType {
+		return k3sServerPoolName
+	}
+	return defaultNodePoolName
+}
+
+func (n k3sNodes) all() []types.Instance {
+	all := append([]types.Instance{*n.server}, n.servers...)
+	return append(all, n.agents...)
+}
+
+// splitClusterInstances sorts the instances of a cluster by role. The server
+// is nil when only joined servers or agents are left.
+func splitClusterInstances(instances []types.Instance) k3sNodes {
+	var nodes k3sNodes
+	for i := range instances {
+		switch ec2TagMap(instances[i].Tags)[resourceTypeTag] {
+		case k3sAgentResourceType:
+			nodes.agents = append(nodes.agents, instances[i])
+		case k3sServerResourceType:
+			nodes.servers = append(nodes.servers, instances[i])
+		default:
+			if nodes.server == nil {
+				nodes.server = &instances[i]
+			}
+		}
+	}
+	return nodes
+}
+
+// describeK3sNodes returns the instances of a single-node or k3s cluster.
+func (m *AWSManager) describeK3sNodes(ctx context.Context, name string) (k3sNodes, error) {
+	instances, err := m.describeClusterInstances(ctx, name)
+	if err != nil {
+		return k3sNodes{}, fmt.Errorf("failed to find cluster instances: %w", err)
+	}
+	nodes := splitClusterInstances(instances)
+	if nodes.server == nil {
+		return k3sNodes{}, withClass(fmt.Errorf("cluster '%s' not found in %s", name, m.region), ErrNotFound)
+	}
+	return nodes, nil
+}
+
+// checkRunning fails unless an instance of a cluster is running, as SSM
+// commands need it to be.
+func checkRunning(name string, instance types.Instance) error {
+	if instance.State == nil || instance.State.Name != types.InstanceStateNameRunning {
+		return fmt.Errorf("instance %s of cluster '%s' is not running; start it with 'cluster start %s' first", aws.ToString(instance.InstanceId), name, name)
+	}
+	return nil
+}
+
+// scaleK3sAgents sets the number of k3s agents of a single-node or k3s
+// cluster. New agents join the servers; removed agents, newest first, are
+// drained and terminated.
+func (m *AWSManager) scaleK3sAgents(ctx context.Context, cluster, pool string, count int32) error {
+	nodes, err := m.describeK3sNodes(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	switch pool {
+	case "", k3sAgentPoolName:
+	case nodes.serverPool():
+		return fmt.Errorf("the %s pool of cluster '%s' holds its k3s servers, which are fixed when it is created; scale the %s pool instead", pool, cluster, k3sAgentPoolName)
+	default:
+		return withClass(fmt.Errorf("node pool %s not found; %s clusters have the %s and %s pools", pool, nodes.clusterType(), nodes.serverPool(), k3sAgentPoolName), ErrNotFound)
+	}
+	if err := checkRunning(cluster, *nodes.server); err != nil {
+		return err
+	}
+
+	agents := nodes.agents
+	current := int32(len(agents))
+	switch {
+	case count == current:
+		slog.Info("Node pool already has that many nodes", "cluster", cluster, "pool", k3sAgentPoolName, "nodes", count)
+		return nil
+	case count > current:
+		return m.addK3sAgents(ctx, cluster, *nodes.server, count-current)
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		return aws.ToTime(agents[i].LaunchTime).After(aws.ToTime(agents[j].LaunchTime))
+	})
+	return m.removeK3sAgents(ctx, cluster, aws.ToString(nodes.server.InstanceId), agents[:current-count])
+}
+
+// addK3sAgents launches agents at the k3s version the server runs. They
+// join from their bootstrap script with the token in Parameter Store, through
+// the load balancer of a k3s cluster or straight to a single-node server.
+func (m *AWSManager) addK3sAgents(ctx context.Context, cluster string, server types.Instance, count int32) error {
+	serverId := aws.ToString(server.InstanceId)
+	slog.Info("📏 Adding k3s agents", "cluster", cluster, "agents", count)
+
+	version, err := m.k3sVersion(ctx, serverId)
+	if err != nil {
+		return err
+	}
+	profile, err := m.ensureK3sNodeAccess(ctx, cluster, m.tagsFor(cluster, time.Now()))
+	if err != nil {
+		return err
+	}
+	if err := m.ensureK3sToken(ctx, cluster, serverId); err != nil {
+		return err
+	}
+
+	serverURL := fmt.Sprintf("https://%s:%d", aws.ToString(server.PrivateIpAddress), k3sAPIPort)
+	if endpoint := ec2TagMap(server.Tags)[k3sEndpointTag]; endpoint != "" {
+		serverURL = fmt.Sprintf("https://%s:%d", endpoint, k3sAPIPort)
+	}
+	agentIds, err := m.launchK3sAgents(ctx, cluster, server, profile, serverURL, version, count)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("⏳ Waiting for agents to join...", "instances", agentIds)
+	if err := m.waitForK3sNodes(ctx, serverId, agentIds, waitTimeout(ctx, 15*time.Minute)); err != nil {
+		return fmt.Errorf("%w\n💡 The agent instances stay tagged with the cluster; check them with 'cluster logs', scale again or tear the cluster down", err)
+	}
+
+	slog.Info("✅ Agents joined", "cluster", cluster, "instances", agentIds)
+	return nil
+}
+
+// ensureK3sToken makes sure the cluster's join token is in Parameter Store.
+// Single-node clusters keep it on the server only until they get agents.
+func (m *AWSManager) ensureK3sToken(ctx context.Context, cluster, serverId string) error {
+	_, err := m.ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(k3sTokenParameter(cluster)),
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to look up the k3s token: %w", err)
+	}
+
+	token, err := m.runSSMCommand(ctx, serverId, []string{"cat " + k3sNodeTokenPath}, time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to read the k3s join token: %w", err)
+	}
+	return m.putK3sTokenValue(ctx, cluster, strings.TrimSpace(token), m.tagsFor(cluster, time.Now()))
+}
+
+// launchK3sAgents starts agent instances of the server's type and security
+// groups, spread over the cluster's private subnets. They inherit the
+// cluster's auto-stop and TTL so they power off with the server.
+func (m *AWSManager) launchK3sAgents(ctx context.Context, cluster string, server types.Instance, profile, serverURL, version string, count int32) ([]string, error) {
+	serverTags := ec2TagMap(server.Tags)
+	params := userDataParams{
+		ClusterName:    cluster,
+		Bootstrap:      m.bootstrap,
+		K3s:            config.K3sConfig{Version: version},
+		Region:         m.region,
+		TokenParameter: k3sTokenParameter(cluster),
+		JoinRole:       "agent",
+		ServerURL:      serverURL,
+	}
+	lifetimeTags := map[string]string{}
+	if spec := serverTags[autoStopTag]; spec != "" {
+		calendar, err := systemdCalendar(spec)
+		if err != nil {
+			return nil, err
+		}
+		params.AutoStopCalendar = calendar
+		lifetimeTags[autoStopTag] = spec
+	}
+	if expiresAt, ok := parseExpiresAt(serverTags[expiresAtTag]); ok {
+		params.ExpiresAtCalendar = expiresAt.UTC().Format("2006-01-02 15:04:05") + " UTC"
+		lifetimeTags[expiresAtTag] = serverTags[expiresAtTag]
+	}
+	for _, key := range []string{clusterTypeTag, k3sEndpointTag} {
+		if value := serverTags[key]; value != "" {
+			lifetimeTags[key] = value
+		}
+	}
+	userData, err := renderJoinUserData(params)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := m.getLatestAmazonLinuxAMI(ctx, server.Architecture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest AMI: %w", err)
+	}
+	var securityGroupIds []string
+	for _, group := range server.SecurityGroups {
+		securityGroupIds = append(securityGroupIds, aws.ToString(group.GroupId))
+	}
+	subnetIds, err := m.clusterPrivateSubnets(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if len(subnetIds) == 0 {
+		subnetIds = []string{aws.ToString(server.SubnetId)}
+	}
+
+	launch := k3sInstances{
+		resourceType:     k3sAgentResourceType,
+		name:             cluster + "-agent",
+		instanceType:     server.InstanceType,
+		image:            image,
+		profile:          profile,
+		securityGroupIds: securityGroupIds,
+		userData:         userData,
+		tags:             m.tagsFor(cluster, time.Now()).With(lifetimeTags),
+	}
+	var agentIds []string
+	for i, subnetCount := range spreadCount(count, len(subnetIds)) {
+		if subnetCount == 0 {
+			continue
+		}
+		launch.subnetId, launch.count = subnetIds[i], subnetCount
+		agents, err := m.runK3sInstances(ctx, launch)
+		if err != nil {
+			return agentIds, fmt.Errorf("failed to launch agent instances: %w", err)
+		}
+		for _, agent := range agents {
+			agentIds = append(agentIds, aws.ToString(agent.InstanceId))
+		}
+	}
+	return agentIds, nil
+}
+
+// waitForK3sNodes waits until the nodes of the instances, which are named
+// after their instance IDs, have registered with the server and are Ready.
+// Nodes only register once their bootstrap script has installed k3s.
+func (m *AWSManager) waitForK3sNodes(ctx context.Context, serverId string, instanceIds []string, timeout time.Duration) error {
+	var nodes []string
+	for _, instanceId := range instanceIds {
+		nodes = append(nodes, shellQuote("node/"+instanceId))
+	}
+	check := []string{"k3s kubectl wait --for=condition=Ready --timeout=60s " + strings.Join(nodes, " ")}
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		_, lastErr = m.runSSMCommand(ctx, serverId, check, 2*time.Minute)
+		if lastErr == nil {
+			return nil
+		}
+		if err := sleepContext(ctx, 15*time.Second); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("nodes did not become ready within %s: %w", timeout, lastErr)
+}
+
+// removeK3sAgents drains the agents' nodes from the server, deletes them and
+// terminates the instances. Agents that never joined are terminated only.
+func (m *AWSManager) removeK3sAgents(ctx context.Context, cluster, serverId string, agents []types.Instance) error {
+	var agentIds []string
+	for _, agent := range agents {
+		agentId := aws.ToString(agent.InstanceId)
+		node := shellQuote(agentId)
+		slog.Info("🧹 Draining agent", "cluster", cluster, "instance", agentId)
+		script := []string{
+			"set -eu",
+			fmt.Sprintf("if k3s kubectl get node %s >/dev/null 2>&1; then k3s kubectl drain %s --ignore-daemonsets --delete-emptydir-data --timeout=5m && k3s kubectl delete node %s; fi", node, node, node),
+		}
+		if _, err := m.runSSMCommand(ctx, serverId, script, 10*time.Minute); err != nil {
+			return fmt.Errorf("failed to drain agent %s: %w", agentId, err)
+		}
+		agentIds = append(agentIds, agentId)
+	}
+
+	slog.Info("Terminating agent instances", "instances", agentIds)
+	_, err := m.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: agentIds,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to terminate agent instances: %w", err)
+	}
+
+	waiter := ec2.NewInstanceTerminatedWaiter(m.ec2Client)
+	err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: agentIds,
+	}, waitTimeout(ctx, 10*time.Minute))
+	if err != nil {
+		return fmt.Errorf("failed waiting for agent instances to terminate: %w", err)
+	}
+
+	slog.Info("✅ Agents removed", "cluster", cluster, "instances", agentIds)
+	return nil
+}
@@ -0,0 +1,475 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+)
+
+// Interface endpoints created in the VPC of each single-node or k3s cluster
+const ssmEndpointCount = 3
+
+// IAM actions every cluster type needs, then the ones specific to a type.
+// They are checked with IAM policy simulation.
+var (
+	awsCommonActions = []string{
+		"ec2:CreateVpc",
+		"ec2:CreateSubnet",
+		"ec2:CreateTags",
+		"ec2:DescribeAvailabilityZones",
+		"ec2:DeleteVpc",
+		"ec2:DeleteSubnet",
+		"iam:CreateRole",
+		"iam:AttachRolePolicy",
+		"iam:GetRole",
+		"iam:PassRole",
+		"iam:DeleteRole",
+	}
+
+	awsClusterActions = map[string][]string{
+		"single-node": {
+			"ec2:RunInstances",
+			"ec2:TerminateInstances",
+			"ec2:DescribeImages",
+			"ec2:CreateSecurityGroup",
+			"ec2:AuthorizeSecurityGroupIngress",
+			"ec2:CreateVpcEndpoint",
+			"ec2:DeleteVpcEndpoints",
+			"iam:CreateInstanceProfile",
+			"iam:AddRoleToInstanceProfile",
+			"iam:PutRolePolicy",
+			"iam:DeleteRolePolicy",
+			"ssm:SendCommand",
+			"ssm:GetCommandInvocation",
+		},
+		"k3s": {
+			"ec2:RunInstances",
+			"ec2:TerminateInstances",
+			"ec2:DescribeImages",
+			"ec2:CreateSecurityGroup",
+			"ec2:AuthorizeSecurityGroupIngress",
+			"ec2:CreateVpcEndpoint",
+			"ec2:DeleteVpcEndpoints",
+			"elasticloadbalancing:CreateLoadBalancer",
+			"elasticloadbalancing:CreateTargetGroup",
+			"elasticloadbalancing:ModifyTargetGroupAttributes",
+			"elasticloadbalancing:CreateListener",
+			"elasticloadbalancing:RegisterTargets",
+			"elasticloadbalancing:DeleteLoadBalancer",
+			"elasticloadbalancing:DeleteTargetGroup",
+			"iam:CreateInstanceProfile",
+			"iam:AddRoleToInstanceProfile",
+			"iam:PutRolePolicy",
+			"iam:DeleteRolePolicy",
+			"kms:CreateKey",
+			"kms:CreateAlias",
+			"kms:ScheduleKeyDeletion",
+			"ssm:PutParameter",
+			"ssm:DeleteParameter",
+			"ssm:SendCommand",
+			"ssm:GetCommandInvocation",
+		},
+		"eks": {
+			"ec2:CreateInternetGateway",
+			"ec2:AttachInternetGateway",
+			"ec2:CreateRouteTable",
+			"ec2:CreateRoute",
+			"ec2:AssociateRouteTable",
+			"ec2:ModifySubnetAttribute",
+			"eks:CreateCluster",
+			"eks:DescribeCluster",
+			"eks:CreateNodegroup",
+			"eks:DescribeNodegroup",
+			"eks:DeleteNodegroup",
+			"eks:DeleteCluster",
+		},
+	}
+)
+
+// On-demand vCPU quotas of the EC2 instance families, by family prefix.
+// Families not listed count against the standard quota.
+var vcpuQuotaCodes = map[string]string{
+	"g":   "L-DB2E81BA", // G and VT instances
+	"vt":  "L-DB2E81BA",
+	"p":   "L-417A185B",
+	"f":   "L-74FC7D96",
+	"x":   "L-7295265B",
+	"inf": "L-1945791B",
+}
+
+const standardVCPUQuotaCode = "L-1216C47A"
+
+// Diagnose checks that a cluster of the given type can be created in the
+// account and region: credentials, availability zones, IAM permissions and
+// service quotas.
+func (m *AWSManager) Diagnose(ctx context.Context, clusterType string) []CheckResult {
+	results := []CheckResult{passed("aws credentials", "%s", m.callerArn)}
+	results = append(results, m.checkAvailabilityZones(ctx))
+	results = append(results, m.checkIAMPermissions(ctx, clusterType))
+	results = append(results, m.checkQuotas(ctx, clusterType)...)
+	return results
+}
+
+// Preflight runs the availability zone, permission and quota checks of
+// Diagnose before create, so a cluster does not fail at the VPC limit after
+// its IAM roles already exist. Quota failures are marked ErrQuotaExceeded.
+func (m *AWSManager) Preflight(ctx context.Context, clusterType string) error {
+	slog.Info("🔍 Running preflight checks...", "type", clusterType)
+
+	err := errors.Join(
+		preflightError([]CheckResult{m.checkAvailabilityZones(ctx), m.checkIAMPermissions(ctx, clusterType)}, nil),
+		preflightError(m.checkQuotas(ctx, clusterType), ErrQuotaExceeded),
+	)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("✓ Preflight checks passed")
+	return nil
+}
+
+func (m *AWSManager) checkAvailabilityZones(ctx context.Context) CheckResult {
+	const name = "aws region"
+
+	result, err := m.ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return failed(name, "%s: %v", m.region, err)
+	}
+
+	var available []string
+	for _, zone := range result.AvailabilityZones {
+		if zone.State == types.AvailabilityZoneStateAvailable {
+			available = append(available, aws.ToString(zone.ZoneName))
+		}
+	}
+	if len(available) < 2 {
+		return failed(name, "%s has %d available zone(s); clusters need 2", m.region, len(available))
+	}
+	return passed(name, "%s, zones %s", m.region, strings.Join(available, ", "))
+}
+
+// checkIAMPermissions simulates the caller's policies against the actions
+// the cluster type needs.
+func (m *AWSManager) checkIAMPermissions(ctx context.Context, clusterType string) CheckResult {
+	const name = "aws permissions"
+
+	specific, ok := awsClusterActions[clusterType]
+	if !ok {
+		return warned(name, "no permission list for cluster type %s", clusterType)
+	}
+	actions := append(append([]string(nil), awsCommonActions...), specific...)
+
+	principal, err := m.simulationPrincipal(ctx)
+	if err != nil {
+		return warned(name, "not simulated: %v", err)
+	}
+
+	var denied []string
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(m.iamClient, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     actions,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			if hasAWSErrorCode(err, "AccessDenied", "AccessDeniedException") {
+				return warned(name, "not simulated: iam:SimulatePrincipalPolicy is not allowed")
+			}
+			return warned(name, "not simulated: %v", err)
+		}
+		for _, result := range page.EvaluationResults {
+			if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+				denied = append(denied, aws.ToString(result.EvalActionName))
+			}
+		}
+	}
+
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return failed(name, "%d of %d %s action(s) denied: %s", len(denied), len(actions), clusterType, strings.Join(denied, ", "))
+	}
+	return passed(name, "%d %s action(s) allowed", len(actions), clusterType)
+}
+
+// simulationPrincipal returns the IAM user or role to simulate for the
+// caller. Assumed-role sessions are mapped back to their role, looked up to
+// get its path (SSO roles live under aws-reserved/).
+func (m *AWSManager) simulationPrincipal(ctx context.Context) (string, error) {
+	arn := m.callerArn
+
+	switch {
+	case strings.HasSuffix(arn, ":root"):
+		return "", fmt.Errorf("the root user cannot be simulated, and should not be used")
+	case strings.Contains(arn, ":user/"):
+		return arn, nil
+	case strings.Contains(arn, ":assumed-role/"):
+		roleName, _, _ := strings.Cut(arn[strings.Index(arn, ":assumed-role/")+len(":assumed-role/"):], "/")
+		result, err := m.iamClient.GetRole(ctx, &iam.GetRoleInput{
+			RoleName: aws.String(roleName),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get role %s: %w", roleName, err)
+		}
+		return aws.ToString(result.Role.Arn), nil
+	default:
+		return "", fmt.Errorf("unsupported principal %s", arn)
+	}
+}
+
+// quotaCheck compares a service quota with current usage plus what a new
+// cluster needs.
+type quotaCheck struct {
+	name    string
+	service string
+	code    string
+	usage   float64
+	need    float64
+}
+
+// checkQuotas checks the VPC, interface endpoint, elastic IP and on-demand
+// vCPU quotas a new cluster of the given type counts against.
+func (m *AWSManager) checkQuotas(ctx context.Context, clusterType string) []CheckResult {
+	var results []CheckResult
+
+	vpcs, err := m.countVPCs(ctx)
+	if err != nil {
+		results = append(results, warned("aws quota vpcs", "failed to count VPCs: %v", err))
+	} else {
+		results = append(results, m.checkQuota(ctx, quotaCheck{name: "aws quota vpcs", service: "vpc", code: "L-F678F1CE", usage: vpcs, need: 1}))
+	}
+
+	if clusterType == "single-node" || clusterType == k3sClusterType {
+		results = append(results, m.checkQuota(ctx, quotaCheck{name: "aws quota interface endpoints", service: "vpc", code: "L-29B6F2EB", need: ssmEndpointCount}))
+	}
+
+	addresses, err := m.ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		results = append(results, warned("aws quota elastic ips", "failed to count elastic IPs: %v", err))
+	} else {
+		results = append(results, m.checkQuota(ctx, quotaCheck{name: "aws quota elastic ips", service: "ec2", code: "L-0263D0A3", usage: float64(len(addresses.Addresses))}))
+	}
+
+	vcpuChecks, err := m.vcpuQuotaChecks(ctx, clusterType)
+	if err != nil {
+		results = append(results, warned("aws quota vcpus", "%v", err))
+	}
+	for _, check := range vcpuChecks {
+		results = append(results, m.checkQuota(ctx, check))
+	}
+
+	return results
+}
+
+func (m *AWSManager) checkQuota(ctx context.Context, check quotaCheck) CheckResult {
+	limit, err := m.quotaValue(ctx, check.service, check.code)
+	if err != nil {
+		return warned(check.name, "failed to read quota %s: %v", check.code, err)
+	}
+
+	total := check.usage + check.need
+	detail := fmt.Sprintf("%g of %g used", check.usage, limit)
+	if check.need > 0 {
+		detail += fmt.Sprintf(", create needs %g", check.need)
+	}
+
+	switch {
+	case total > limit:
+		return failed(check.name, "%s; request an increase in Service Quotas (%s %s)", detail, check.service, check.code)
+	case check.need == 0 && check.usage >= limit:
+		return warned(check.name, "%s; at the limit", detail)
+	case total >= limit*0.8:
+		return warned(check.name, "%s; close to the limit", detail)
+	default:
+		return passed(check.name, "%s", detail)
+	}
+}
+
+// quotaValue reads the applied quota, falling back to the AWS default for
+// quotas the account never changed.
+func (m *AWSManager) quotaValue(ctx context.Context, service, code string) (float64, error) {
+	result, err := m.quotasClient.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(service),
+		QuotaCode:   aws.String(code),
+	})
+	if err == nil {
+		return aws.ToFloat64(result.Quota.Value), nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+
+	defaults, err := m.quotasClient.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+		ServiceCode: aws.String(service),
+		QuotaCode:   aws.String(code),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToFloat64(defaults.Quota.Value), nil
+}
+
+func (m *AWSManager) countVPCs(ctx context.Context) (float64, error) {
+	count := 0
+	paginator := ec2.NewDescribeVpcsPaginator(m.ec2Client, &ec2.DescribeVpcsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		count += len(page.Vpcs)
+	}
+	return float64(count), nil
+}
+
+// plannedInstances returns the on-demand instances a new cluster of the
+// given type launches, by instance type. Spot capacity has its own quotas.
+func (m *AWSManager) plannedInstances(clusterType string) map[string]int32 {
+	planned := map[string]int32{}
+	switch clusterType {
+	case "single-node":
+		if !m.instance.Spot.Enabled {
+			planned[m.instance.Type]++
+		}
+	case k3sClusterType:
+		planned[m.instance.Type] += m.k3s.Servers + m.k3s.Agents
+	case "eks":
+		for _, pool := range m.eks.NodePools {
+			if !pool.Spot && pool.Count > 0 {
+				planned[pool.InstanceType] += pool.Count
+			}
+		}
+	}
+	return planned
+}
+
+// vcpuQuotaChecks returns one check per on-demand vCPU quota the new
+// cluster's instances count against, with the vCPUs already running in it.
+func (m *AWSManager) vcpuQuotaChecks(ctx context.Context, clusterType string) ([]quotaCheck, error) {
+	planned := m.plannedInstances(clusterType)
+	if len(planned) == 0 {
+		return nil, nil
+	}
+
+	running := map[string]int32{}
+	paginator := ec2.NewDescribeInstancesPaginator(m.ec2Client, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: []string{"pending", "running"},
+		}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count running instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.InstanceLifecycle != types.InstanceLifecycleTypeSpot {
+					running[string(instance.InstanceType)]++
+				}
+			}
+		}
+	}
+
+	var instanceTypes []string
+	for instanceType := range planned {
+		instanceTypes = append(instanceTypes, instanceType)
+	}
+	for instanceType := range running {
+		if _, ok := planned[instanceType]; !ok {
+			instanceTypes = append(instanceTypes, instanceType)
+		}
+	}
+	vcpus, err := m.instanceVCPUs(ctx, instanceTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := map[string]*quotaCheck{}
+	for instanceType, count := range planned {
+		code := vcpuQuotaCode(instanceType)
+		if checks[code] == nil {
+			checks[code] = &quotaCheck{name: "aws quota vcpus " + vcpuQuotaFamily(code), service: "ec2", code: code}
+		}
+		checks[code].need += float64(count * vcpus[instanceType])
+	}
+	for instanceType, count := range running {
+		if check := checks[vcpuQuotaCode(instanceType)]; check != nil {
+			check.usage += float64(count * vcpus[instanceType])
+		}
+	}
+
+	var result []quotaCheck
+	for _, check := range checks {
+		result = append(result, *check)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result, nil
+}
+
+// instanceVCPUs looks up the default vCPU count of instance types.
+func (m *AWSManager) instanceVCPUs(ctx context.Context, instanceTypes []string) (map[string]int32, error) {
+	vcpus := map[string]int32{}
+	for start := 0; start < len(instanceTypes); start += 100 {
+		end := start + 100
+		if end > len(instanceTypes) {
+			end = len(instanceTypes)
+		}
+
+		var batch []types.InstanceType
+		for _, instanceType := range instanceTypes[start:end] {
+			batch = append(batch, types.InstanceType(instanceType))
+		}
+		result, err := m.ec2Client.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+			InstanceTypes: batch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instance types: %w", err)
+		}
+		for _, info := range result.InstanceTypes {
+			if info.VCpuInfo != nil {
+				vcpus[string(info.InstanceType)] = aws.ToInt32(info.VCpuInfo.DefaultVCpus)
+			}
+		}
+	}
+	return vcpus, nil
+}
+
+// vcpuQuotaCode returns the on-demand vCPU quota of an instance type's
+// family, e.g. g5.xlarge counts against the G and VT quota.
+func vcpuQuotaCode(instanceType string) string {
+	family, _, _ := strings.Cut(instanceType, ".")
+	for _, prefix := range []string{"inf", "vt", "g", "p", "f", "x"} {
+		if strings.HasPrefix(family, prefix) {
+			return vcpuQuotaCodes[prefix]
+		}
+	}
+	return standardVCPUQuotaCode
+}
+
+func vcpuQuotaFamily(code string) string {
+	switch code {
+	case "L-DB2E81BA":
+		return "(G and VT)"
+	case "L-417A185B":
+		return "(P)"
+	case "L-74FC7D96":
+		return "(F)"
+	case "L-7295265B":
+		return "(X)"
+	case "L-1945791B":
+		return "(Inf)"
+	default:
+		return "(standard)"
+	}
+}
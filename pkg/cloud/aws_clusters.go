@@ -0,0 +1,226 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// Remote path of the kubeconfig on k3s servers
+const k3sKubeconfigPath = "/etc/rancher/k3s/k3s.yaml"
+
+// GetCluster describes an xstrapolate cluster in the region, including the
+// IDs of the resources tagged with its name.
+func (m *AWSManager) GetCluster(ctx context.Context, name string) (*ClusterInfo, error) {
+	info, err := m.describeEKSCluster(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		nodes, err := m.describeK3sNodes(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		info = k3sClusterInfo(name, nodes)
+	} else {
+		info.NodePools, err = m.describeNodePools(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if path, err := clusterKubeconfigPath(name); err == nil {
+			info.KubeconfigPath = path
+		}
+	}
+
+	info.Region = m.region
+	info.Resources, err = m.clusterResources(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster resources: %w", err)
+	}
+	return info, nil
+}
+
+// ListClusters returns the single-node, k3s and EKS clusters xstrapolate
+// created in the region, sorted by name. Resources are not filled in.
+func (m *AWSManager) ListClusters(ctx context.Context) ([]ClusterInfo, error) {
+	clusters := map[string]ClusterInfo{}
+
+	result, err := m.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []string{clusterTag},
+			},
+			{
+				Name:   aws.String("tag:" + managedTag),
+				Values: []string{"true"},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"running", "stopped", "stopping", "pending"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instances: %w", err)
+	}
+
+	instances := map[string][]types.Instance{}
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if name := ec2TagMap(instance.Tags)[clusterTag]; name != "" {
+				instances[name] = append(instances[name], instance)
+			}
+		}
+	}
+	for name, clusterInstances := range instances {
+		nodes := splitClusterInstances(clusterInstances)
+		if nodes.server == nil {
+			continue
+		}
+		info := k3sClusterInfo(name, nodes)
+		info.Region = m.region
+		clusters[name] = *info
+	}
+
+	paginator := eks.NewListClustersPaginator(m.eksClient, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list EKS clusters: %w", err)
+		}
+
+		for _, name := range page.Clusters {
+			info, err := m.describeEKSCluster(ctx, name)
+			if err != nil {
+				slog.Warn("failed to describe EKS cluster", "cluster", name, "err", err)
+				continue
+			}
+			if info == nil {
+				continue
+			}
+			info.Region = m.region
+			clusters[name] = *info
+		}
+	}
+
+	list := make([]ClusterInfo, 0, len(clusters))
+	for _, cluster := range clusters {
+		list = append(list, cluster)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// describeEKSCluster returns nil without an error when there is no EKS
+// cluster of that name or it was not created by xstrapolate.
+func (m *AWSManager) describeEKSCluster(ctx context.Context, name string) (*ClusterInfo, error) {
+	result, err := m.eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to describe EKS cluster: %w", err)
+	}
+	if result.Cluster.Tags[managedTag] != "true" {
+		return nil, nil
+	}
+
+	return eksClusterInfo(result.Cluster), nil
+}
+
+func eksClusterInfo(cluster *ekstypes.Cluster) *ClusterInfo {
+	return &ClusterInfo{
+		Name:     aws.ToString(cluster.Name),
+		Type:     "eks",
+		Provider: "aws",
+		Endpoint: aws.ToString(cluster.Endpoint),
+		Status:   strings.ToLower(string(cluster.Status)),
+		Version:  aws.ToString(cluster.Version),
+	}
+}
+
+// k3sClusterInfo describes a single-node or k3s cluster from its instances.
+// The status is the first server's; single-node clusters are reached by its
+// instance ID, k3s clusters through their load balancer.
+func k3sClusterInfo(name string, nodes k3sNodes) *ClusterInfo {
+	server := *nodes.server
+	status := "unknown"
+	if server.State != nil {
+		status = string(server.State.Name)
+	}
+
+	info := &ClusterInfo{
+		Name:           name,
+		Type:           nodes.clusterType(),
+		Provider:       "aws",
+		KubeconfigPath: k3sKubeconfigPath,
+		Endpoint:       aws.ToString(server.InstanceId), // No public IP, reached over SSM
+		Status:         status,
+		NodePools: []NodePool{{
+			Name:         nodes.serverPool(),
+			InstanceType: string(server.InstanceType),
+			Count:        int32(1 + len(nodes.servers)),
+			Spot:         server.InstanceLifecycle == types.InstanceLifecycleTypeSpot,
+		}},
+	}
+	if endpoint := ec2TagMap(server.Tags)[k3sEndpointTag]; endpoint != "" {
+		info.Endpoint = fmt.Sprintf("https://%s:%d", endpoint, k3sAPIPort)
+	}
+	if len(nodes.agents) > 0 {
+		info.NodePools = append(info.NodePools, NodePool{
+			Name:         k3sAgentPoolName,
+			InstanceType: string(nodes.agents[0].InstanceType),
+			Count:        int32(len(nodes.agents)),
+		})
+	}
+	return info
+}
+
+// clusterResources lists the EC2 resources tagged with the cluster name,
+// keyed by EC2 resource type. Shared IAM roles are not included.
+func (m *AWSManager) clusterResources(ctx context.Context, name string) (map[string][]string, error) {
+	resources := map[string][]string{}
+
+	paginator := ec2.NewDescribeTagsPaginator(m.ec2Client, &ec2.DescribeTagsInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("key"),
+				Values: []string{clusterTag},
+			},
+			{
+				Name:   aws.String("value"),
+				Values: []string{name},
+			},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range page.Tags {
+			kind := string(tag.ResourceType)
+			resources[kind] = append(resources[kind], aws.ToString(tag.ResourceId))
+		}
+	}
+
+	for _, ids := range resources {
+		sort.Strings(ids)
+	}
+	return resources, nil
+}
@@ -0,0 +1,226 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	cetypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+const (
+	// SSM needs the ssm, ssmmessages and ec2messages interface endpoints
+	ssmInterfaceEndpoints = 3
+	// createVPCAndSubnetsForSSM spreads private subnets over two AZs
+	ssmSubnetAZs = 2
+
+	// Root volume of the Amazon Linux 2023 AMI when not overridden
+	defaultRootVolumeSize = 8
+	defaultRootVolumeType = "gp3"
+
+	// Disk of EKS managed nodes created without a launch template
+	eksNodeVolumeSize = 20
+	eksNodeVolumeType = "gp2"
+)
+
+// provisionPlan counts the billable resources a create call will make.
+type provisionPlan struct {
+	InstanceType       string
+	Instances          int
+	Spot               bool
+	VolumeSizeGB       int32
+	VolumeType         string
+	InterfaceEndpoints int
+	EndpointAZs        int
+	NATGateways        int
+	LoadBalancers      int
+	KMSKeys            int
+	EKSControlPlanes   int
+	NodeGroups         []plannedNodeGroup
+}
+
+// plannedNodeGroup is one EKS managed node group of a plan.
+type plannedNodeGroup struct {
+	Name         string
+	InstanceType string
+	Nodes        int
+	Spot         bool
+}
+
+func (m *AWSManager) planFor(clusterType string) (provisionPlan, error) {
+	switch clusterType {
+	case "eks":
+		plan := provisionPlan{EKSControlPlanes: 1}
+		for _, pool := range m.eks.NodePools {
+			if pool.Count > 0 {
+				plan.NodeGroups = append(plan.NodeGroups, plannedNodeGroup{
+					Name:         pool.Name,
+					InstanceType: pool.InstanceType,
+					Nodes:        int(pool.Count),
+					Spot:         pool.Spot,
+				})
+			}
+		}
+		return plan, nil
+	case "single-node", k3sClusterType:
+		plan := provisionPlan{
+			InstanceType:       m.instance.Type,
+			Instances:          1,
+			Spot:               m.instance.Spot.Enabled,
+			VolumeSizeGB:       m.instance.RootVolumeSize,
+			VolumeType:         m.instance.RootVolumeType,
+			InterfaceEndpoints: ssmInterfaceEndpoints,
+			EndpointAZs:        ssmSubnetAZs,
+		}
+		if plan.VolumeSizeGB == 0 {
+			plan.VolumeSizeGB = defaultRootVolumeSize
+		}
+		if plan.VolumeType == "" {
+			plan.VolumeType = defaultRootVolumeType
+		}
+		if clusterType == k3sClusterType {
+			plan.Instances = int(m.k3s.Servers + m.k3s.Agents)
+			plan.Spot = false
+			plan.LoadBalancers = 1
+			plan.KMSKeys = 1
+		}
+		return plan, nil
+	default:
+		return provisionPlan{}, fmt.Errorf("unsupported cluster type for AWS: %s", clusterType)
+	}
+}
+
+// EstimateCost prices what CreateCluster would provision using the bundled
+// price table.
+func (m *AWSManager) EstimateCost(clusterType string) (*CostEstimate, error) {
+	plan, err := m.planFor(clusterType)
+	if err != nil {
+		return nil, err
+	}
+	return estimatePlanCost(m.region, plan), nil
+}
+
+func estimatePlanCost(region string, plan provisionPlan) *CostEstimate {
+	multiplier := awsPrices.regionMultiplier(region)
+	estimate := &CostEstimate{Region: region}
+
+	add := func(description string, quantity float64, unitHourly float64) {
+		if quantity > 0 {
+			estimate.Items = append(estimate.Items, CostItem{
+				Description: description,
+				Quantity:    quantity,
+				UnitHourly:  unitHourly,
+			})
+		}
+	}
+
+	add("EKS control plane", float64(plan.EKSControlPlanes), awsPrices.Hourly.EKSControlPlane*multiplier)
+
+	if plan.Instances > 0 {
+		price, known := instanceHourlyPrice(region, plan.InstanceType)
+		if !known {
+			estimate.Notes = append(estimate.Notes, fmt.Sprintf("no bundled price for %s, instance cost not included", plan.InstanceType))
+		}
+		add(fmt.Sprintf("EC2 instance (%s)", plan.InstanceType), float64(plan.Instances), price)
+
+		if gbMonth, ok := awsPrices.EBSGBMonth[plan.VolumeType]; ok {
+			add(fmt.Sprintf("Root volume GiB (%s)", plan.VolumeType), float64(plan.VolumeSizeGB)*float64(plan.Instances), gbMonth*multiplier/hoursPerMonth)
+		}
+	}
+	spot := plan.Spot
+	for _, group := range plan.NodeGroups {
+		price, known := instanceHourlyPrice(region, group.InstanceType)
+		if !known {
+			estimate.Notes = append(estimate.Notes, fmt.Sprintf("no bundled price for %s, node pool %s not included", group.InstanceType, group.Name))
+		}
+		add(fmt.Sprintf("EKS node pool %s (%s)", group.Name, group.InstanceType), float64(group.Nodes), price)
+		if gbMonth, ok := awsPrices.EBSGBMonth[eksNodeVolumeType]; ok {
+			add(fmt.Sprintf("Node volume GiB (%s, pool %s)", eksNodeVolumeType, group.Name), float64(eksNodeVolumeSize*group.Nodes), gbMonth*multiplier/hoursPerMonth)
+		}
+		spot = spot || group.Spot
+	}
+	if spot {
+		estimate.Notes = append(estimate.Notes, "spot capacity is usually 60-90% cheaper than the on-demand instance price shown")
+	}
+
+	add(fmt.Sprintf("Interface VPC endpoints (%d × %d AZs)", plan.InterfaceEndpoints, plan.EndpointAZs),
+		float64(plan.InterfaceEndpoints*plan.EndpointAZs), awsPrices.Hourly.InterfaceEndpointPerAZ*multiplier)
+	add("NAT gateways", float64(plan.NATGateways), awsPrices.Hourly.NATGateway*multiplier)
+	add("Network load balancers", float64(plan.LoadBalancers), awsPrices.Hourly.NetworkLoadBalancer*multiplier)
+	add("KMS keys", float64(plan.KMSKeys), awsPrices.Hourly.KMSKey*multiplier)
+	if plan.LoadBalancers > 0 {
+		estimate.Notes = append(estimate.Notes, "load balancer capacity units (LCUs) are billed by traffic and not included")
+	}
+
+	return estimate
+}
+
+// ClusterCost queries Cost Explorer for the actual spend of resources tagged
+// with the cluster name over the last days.
+func (m *AWSManager) ClusterCost(ctx context.Context, name string, days int) (*CostReport, error) {
+	// Cost Explorer is only served from us-east-1
+	client := costexplorer.NewFromConfig(m.cfg, func(o *costexplorer.Options) {
+		o.Region = "us-east-1"
+	})
+
+	end := time.Now().UTC().AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -days)
+	report := &CostReport{
+		Name:      name,
+		Start:     start,
+		End:       end,
+		ByService: map[string]float64{},
+	}
+
+	input := &costexplorer.GetCostAndUsageInput{
+		Granularity: cetypes.GranularityDaily,
+		Metrics:     []string{"UnblendedCost"},
+		TimePeriod: &cetypes.DateInterval{
+			Start: aws.String(start.Format("2006-01-02")),
+			End:   aws.String(end.Format("2006-01-02")),
+		},
+		Filter: &cetypes.Expression{
+			Tags: &cetypes.TagValues{
+				Key:    aws.String(clusterTag),
+				Values: []string{name},
+			},
+		},
+		GroupBy: []cetypes.GroupDefinition{
+			{
+				Type: cetypes.GroupDefinitionTypeDimension,
+				Key:  aws.String("SERVICE"),
+			},
+		},
+	}
+
+	for {
+		result, err := client.GetCostAndUsage(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query Cost Explorer: %w", err)
+		}
+
+		for _, period := range result.ResultsByTime {
+			for _, group := range period.Groups {
+				if len(group.Keys) == 0 {
+					continue
+				}
+				amount, err := strconv.ParseFloat(aws.ToString(group.Metrics["UnblendedCost"].Amount), 64)
+				if err != nil {
+					continue
+				}
+				report.ByService[group.Keys[0]] += amount
+				report.Total += amount
+			}
+		}
+
+		if result.NextPageToken == nil {
+			break
+		}
+		input.NextPageToken = result.NextPageToken
+	}
+
+	return report, nil
+}
@@ -0,0 +1,157 @@
+package cloud
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultAWSRegion = "us-west-2"
+
+	// Session name of assumed roles, shown in CloudTrail
+	roleSessionName = "xstrapolate"
+)
+
+// loadAWSConfig resolves the region and credentials and returns an SDK
+// config. Credentials come from the first of:
+//
+//  1. the selected profile: --profile or cloud.aws.profile, either an entry
+//     of cloud.aws.profiles or a profile in ~/.aws/config (including SSO)
+//  2. AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY in the environment
+//  3. access_key_id and secret_access_key in the config file
+//  4. the SDK default chain: the default profile and instance roles
+//
+// A role from --role-arn or the profile is then assumed on top, prompting
+// for an MFA code when an MFA device is configured.
+func loadAWSConfig(ctx context.Context, conf config.AWSConfig) (aws.Config, error) {
+	profileName := firstNonEmpty(viper.GetString("profile"), conf.Profile)
+	profile, ok := conf.Profiles[profileName]
+	if !ok {
+		profile = config.AWSProfile{AWSProfile: profileName}
+	}
+	roleARN := firstNonEmpty(viper.GetString("role-arn"), profile.RoleARN, conf.RoleARN)
+	externalID := firstNonEmpty(viper.GetString("external-id"), profile.ExternalID, conf.ExternalID)
+	mfaSerial := firstNonEmpty(viper.GetString("mfa-serial"), profile.MFASerial, conf.MFASerial)
+
+	options := []func(*awsconfig.LoadOptions) error{
+		// Roles assumed by ~/.aws/config profiles with mfa_serial prompt too
+		awsconfig.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = promptMFAToken
+		}),
+	}
+
+	region, regionSource := resolveAWSRegion(conf, profile, profileName)
+	if region != "" {
+		options = append(options, awsconfig.WithRegion(region))
+	}
+
+	var source string
+	switch {
+	case profile.AWSProfile != "":
+		options = append(options, awsconfig.WithSharedConfigProfile(profile.AWSProfile))
+		source = "AWS profile " + profile.AWSProfile
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		source = "environment"
+	default:
+		provider, err := staticAWSCredentials(conf)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		if provider != nil {
+			options = append(options, awsconfig.WithCredentialsProvider(provider))
+			source = "config file"
+		} else {
+			source = "default credential chain"
+		}
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, withClass(fmt.Errorf("failed to load AWS config: %w\n\nPlease ensure you have AWS credentials configured:\n- Run 'aws configure' or 'aws sso login --profile <name>'\n- Or set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables\n- Or use IAM roles if running on EC2", err), ErrCredentials)
+	}
+
+	// A profile in ~/.aws/config may set the region; otherwise fall back
+	if region == "" {
+		if cfg.Region != "" {
+			regionSource = "AWS profile"
+		} else {
+			cfg.Region = defaultAWSRegion
+			regionSource = "default"
+		}
+	}
+	slog.Info("Using AWS region", "region", cfg.Region, "source", regionSource)
+
+	if roleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = roleSessionName
+			if externalID != "" {
+				o.ExternalID = aws.String(externalID)
+			}
+			if mfaSerial != "" {
+				o.SerialNumber = aws.String(mfaSerial)
+				o.TokenProvider = promptMFAToken
+			}
+		}))
+		source += ", assuming " + roleARN
+	}
+	slog.Info("Using AWS credentials", "source", source)
+
+	return cfg, nil
+}
+
+// resolveAWSRegion returns the region from, in order, --region, AWS_REGION,
+// the selected xstrapolate profile and cloud.aws.region. An empty region
+// lets the SDK read it from the AWS profile.
+func resolveAWSRegion(conf config.AWSConfig, profile config.AWSProfile, profileName string) (string, string) {
+	switch {
+	case viper.GetString("region") != "":
+		return viper.GetString("region"), "command line flag"
+	case os.Getenv("AWS_REGION") != "":
+		return os.Getenv("AWS_REGION"), "AWS_REGION environment variable"
+	case profile.Region != "":
+		return profile.Region, "profile " + profileName
+	case conf.Region != "":
+		return conf.Region, "config file"
+	default:
+		return "", ""
+	}
+}
+
+// staticAWSCredentials returns the access keys from the config file, or nil
+// when none are set.
+func staticAWSCredentials(conf config.AWSConfig) (aws.CredentialsProvider, error) {
+	if conf.AccessKeyID == "" && conf.SecretAccessKey == "" {
+		if conf.SessionToken != "" {
+			return nil, withClass(fmt.Errorf("cloud.aws.session_token is set without access_key_id and secret_access_key"), ErrCredentials)
+		}
+		return nil, nil
+	}
+	if conf.AccessKeyID == "" || conf.SecretAccessKey == "" {
+		return nil, withClass(fmt.Errorf("cloud.aws.access_key_id and cloud.aws.secret_access_key must be set together"), ErrCredentials)
+	}
+
+	return credentials.NewStaticCredentialsProvider(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken), nil
+}
+
+// promptMFAToken asks for an MFA code on the terminal. The prompt goes to
+// stderr so it does not end up in -o json output.
+func promptMFAToken() (string, error) {
+	fmt.Fprint(os.Stderr, "MFA token code: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read MFA token code: %w", err)
+	}
+	return strings.TrimSpace(code), nil
+}
@@ -0,0 +1,376 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultEKSVersion       = "1.28"
+	defaultNodePoolName     = "default"
+	defaultNodeInstanceType = "t3.medium"
+)
+
+var (
+	eksVersionPattern = regexp.MustCompile(`^1\.[0-9]+$`)
+	nodePoolPattern   = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_]{0,62}$`)
+)
+
+// Managed policies of the role EKS worker nodes run as
+var eksNodePolicyArns = []string{
+	"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+	"arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
+	"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+}
+
+// loadEKSConfig validates the EKS settings. Without configured node pools
+// one default pool of --node-count nodes is created.
+func loadEKSConfig(conf config.AWSEKSConfig) (config.AWSEKSConfig, error) {
+	eksConf := config.AWSEKSConfig{
+		Version:   firstNonEmpty(conf.Version, defaultEKSVersion),
+		NodePools: append([]config.NodePoolConfig(nil), conf.NodePools...),
+	}
+	if !eksVersionPattern.MatchString(eksConf.Version) {
+		return config.AWSEKSConfig{}, fmt.Errorf("invalid EKS version %q: expected a minor version such as %s", eksConf.Version, defaultEKSVersion)
+	}
+
+	if len(eksConf.NodePools) == 0 {
+		count := viper.GetInt32("node-count")
+		if count < 1 {
+			count = 1
+		}
+		eksConf.NodePools = []config.NodePoolConfig{{Name: defaultNodePoolName, Count: count}}
+	}
+
+	seen := map[string]bool{}
+	for i, pool := range eksConf.NodePools {
+		if !nodePoolPattern.MatchString(pool.Name) {
+			return config.AWSEKSConfig{}, fmt.Errorf("invalid node pool name %q: use letters, digits, - and _", pool.Name)
+		}
+		if seen[pool.Name] {
+			return config.AWSEKSConfig{}, fmt.Errorf("duplicate node pool name %q", pool.Name)
+		}
+		seen[pool.Name] = true
+		if pool.Count < 0 {
+			return config.AWSEKSConfig{}, fmt.Errorf("node pool %q: count must not be negative", pool.Name)
+		}
+		eksConf.NodePools[i].InstanceType = firstNonEmpty(pool.InstanceType, defaultNodeInstanceType)
+	}
+
+	return eksConf, nil
+}
+
+// CreateNodePool adds a managed node group to an existing EKS cluster and
+// waits until its nodes are ready.
+func (m *AWSManager) CreateNodePool(ctx context.Context, cluster string, pool NodePool) error {
+	result, err := m.eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{
+		Name: aws.String(cluster),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe EKS cluster: %w", err)
+	}
+
+	tags := m.tagsFor(cluster, time.Now())
+	roleArn, err := m.ensureEKSNodeRole(ctx, tags.Shared())
+	if err != nil {
+		return fmt.Errorf("failed to create EKS node role: %w", err)
+	}
+
+	nodePool := config.NodePoolConfig{
+		Name:         pool.Name,
+		InstanceType: firstNonEmpty(pool.InstanceType, defaultNodeInstanceType),
+		Count:        pool.Count,
+		Spot:         pool.Spot,
+	}
+	subnetIds := result.Cluster.ResourcesVpcConfig.SubnetIds
+	if err := m.createNodegroup(ctx, cluster, roleArn, subnetIds, nodePool, tags); err != nil {
+		return err
+	}
+	return m.waitForNodegroups(ctx, cluster, []string{pool.Name})
+}
+
+// ScaleNodePool sets the node count of a managed node group and waits for
+// the update to finish. An empty pool means the only node group. Single-node
+// clusters scale their k3s agents instead.
+func (m *AWSManager) ScaleNodePool(ctx context.Context, cluster, pool string, count int32) error {
+	if count < 0 {
+		return fmt.Errorf("node count must not be negative")
+	}
+
+	info, err := m.describeEKSCluster(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return m.scaleK3sAgents(ctx, cluster, pool, count)
+	}
+	if pool == "" {
+		names, err := m.listNodegroups(ctx, cluster)
+		if err != nil {
+			return err
+		}
+		if len(names) != 1 {
+			return fmt.Errorf("cluster '%s' has %d node pools (%s); choose one with --pool", cluster, len(names), strings.Join(names, ", "))
+		}
+		pool = names[0]
+	}
+
+	slog.Info("📏 Scaling node pool", "cluster", cluster, "pool", pool, "nodes", count)
+	_, err = m.eksClient.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{
+		ClusterName:   aws.String(cluster),
+		NodegroupName: aws.String(pool),
+		ScalingConfig: nodegroupScaling(count),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scale node pool %s: %w", pool, err)
+	}
+	return m.waitForNodegroups(ctx, cluster, []string{pool})
+}
+
+// createNodePools creates the configured node groups of a new cluster.
+func (m *AWSManager) createNodePools(ctx context.Context, cluster string, subnetIds []string, tags TagBuilder) error {
+	roleArn, err := m.ensureEKSNodeRole(ctx, tags.Shared())
+	if err != nil {
+		return fmt.Errorf("failed to create EKS node role: %w", err)
+	}
+
+	var names []string
+	for _, pool := range m.eks.NodePools {
+		if err := m.createNodegroup(ctx, cluster, roleArn, subnetIds, pool, tags); err != nil {
+			return err
+		}
+		names = append(names, pool.Name)
+	}
+	return m.waitForNodegroups(ctx, cluster, names)
+}
+
+func (m *AWSManager) createNodegroup(ctx context.Context, cluster, roleArn string, subnetIds []string, pool config.NodePoolConfig, tags TagBuilder) error {
+	capacityType := ekstypes.CapacityTypesOnDemand
+	if pool.Spot {
+		capacityType = ekstypes.CapacityTypesSpot
+	}
+
+	slog.Info("Creating node pool", "cluster", cluster, "pool", pool.Name, "instanceType", pool.InstanceType, "nodes", pool.Count, "spot", pool.Spot)
+	_, err := m.eksClient.CreateNodegroup(ctx, &eks.CreateNodegroupInput{
+		ClusterName:   aws.String(cluster),
+		NodegroupName: aws.String(pool.Name),
+		NodeRole:      aws.String(roleArn),
+		Subnets:       subnetIds,
+		InstanceTypes: []string{pool.InstanceType},
+		CapacityType:  capacityType,
+		ScalingConfig: nodegroupScaling(pool.Count),
+		Tags:          tags.For("eks-nodegroup", nil),
+	})
+	if err != nil {
+		if hasAWSErrorCode(err, "ResourceInUseException") {
+			err = withClass(err, ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to create node pool %s: %w", pool.Name, err)
+	}
+	return nil
+}
+
+// nodegroupScaling keeps a node group at exactly count nodes. EKS needs a
+// maximum of at least one, even for an empty group.
+func nodegroupScaling(count int32) *ekstypes.NodegroupScalingConfig {
+	maxSize := count
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	return &ekstypes.NodegroupScalingConfig{
+		MinSize:     aws.Int32(count),
+		MaxSize:     aws.Int32(maxSize),
+		DesiredSize: aws.Int32(count),
+	}
+}
+
+func (m *AWSManager) waitForNodegroups(ctx context.Context, cluster string, names []string) error {
+	slog.Info("⏳ Waiting for node pools to become active...", "cluster", cluster, "pools", names)
+	waiter := eks.NewNodegroupActiveWaiter(m.eksClient)
+	for _, name := range names {
+		err := waiter.Wait(ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(cluster),
+			NodegroupName: aws.String(name),
+		}, waitTimeout(ctx, 20*time.Minute))
+		if err != nil {
+			return fmt.Errorf("failed to wait for node pool %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// describeNodePools returns the node groups of an EKS cluster, sorted by
+// name.
+func (m *AWSManager) describeNodePools(ctx context.Context, cluster string) ([]NodePool, error) {
+	names, err := m.listNodegroups(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []NodePool
+	for _, name := range names {
+		result, err := m.eksClient.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(cluster),
+			NodegroupName: aws.String(name),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe node pool %s: %w", name, err)
+		}
+
+		nodegroup := result.Nodegroup
+		pool := NodePool{
+			Name:    name,
+			Spot:    nodegroup.CapacityType == ekstypes.CapacityTypesSpot,
+			Version: aws.ToString(nodegroup.Version),
+		}
+		if len(nodegroup.InstanceTypes) > 0 {
+			pool.InstanceType = nodegroup.InstanceTypes[0]
+		}
+		if nodegroup.ScalingConfig != nil {
+			pool.Count = aws.ToInt32(nodegroup.ScalingConfig.DesiredSize)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func (m *AWSManager) listNodegroups(ctx context.Context, cluster string) ([]string, error) {
+	var names []string
+	paginator := eks.NewListNodegroupsPaginator(m.eksClient, &eks.ListNodegroupsInput{
+		ClusterName: aws.String(cluster),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list node pools: %w", err)
+		}
+		names = append(names, page.Nodegroups...)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// deleteNodePools deletes every node group of an EKS cluster, which EKS
+// requires before the control plane can be deleted.
+func (m *AWSManager) deleteNodePools(ctx context.Context, cluster string) error {
+	names, err := m.listNodegroups(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		slog.Info("🛑 Deleting node pool", "cluster", cluster, "pool", name)
+		_, err := m.eksClient.DeleteNodegroup(ctx, &eks.DeleteNodegroupInput{
+			ClusterName:   aws.String(cluster),
+			NodegroupName: aws.String(name),
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to delete node pool %s: %w", name, err)
+		}
+	}
+
+	waiter := eks.NewNodegroupDeletedWaiter(m.eksClient)
+	for _, name := range names {
+		err := waiter.Wait(ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(cluster),
+			NodegroupName: aws.String(name),
+		}, waitTimeout(ctx, 20*time.Minute))
+		if err != nil {
+			return fmt.Errorf("failed waiting for node pool %s deletion: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (m *AWSManager) ensureEKSNodeRole(ctx context.Context, tags TagBuilder) (string, error) {
+	roleName := eksNodeRoleName
+
+	assumeRolePolicyDocument := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "ec2.amazonaws.com"
+				},
+				"Action": "sts:AssumeRole"
+			}
+		]
+	}`
+
+	_, err := m.iamClient.CreateRole(ctx, &iam.CreateRoleInput{
+		RoleName:                 aws.String(roleName),
+		AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocument),
+		Tags:                     iamTags(tags.For("iam-role", nil)),
+	})
+	if err != nil {
+		if !errors.Is(err, ErrAlreadyExists) {
+			return "", fmt.Errorf("failed to create role %s: %w", roleName, err)
+		}
+		slog.Debug("Role already exists, continuing...", "role", roleName)
+	}
+
+	for _, policyArn := range eksNodePolicyArns {
+		_, err = m.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(policyArn),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to attach policy %s: %w", policyArn, err)
+		}
+	}
+
+	accountID, err := m.getAccountID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get account ID: %w", err)
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName), nil
+}
+
+func (m *AWSManager) deleteEKSNodeRole(ctx context.Context) error {
+	roleName := eksNodeRoleName
+
+	_, err := m.iamClient.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			slog.Debug("EKS node role does not exist, skipping", "role", roleName)
+			return nil
+		}
+		return fmt.Errorf("failed to check EKS node role: %w", err)
+	}
+
+	for _, policyArn := range eksNodePolicyArns {
+		_, err = m.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(policyArn),
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			slog.Warn("failed to detach policy from EKS node role", "policy", policyArn, "err", err)
+		}
+	}
+
+	_, err = m.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		slog.Warn("failed to delete EKS node role", "err", err)
+		return err
+	}
+	slog.Info("✅ Deleted EKS node role")
+	return nil
+}
@@ -0,0 +1,97 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// AWS error codes that mean the request was not authenticated
+var awsCredentialCodes = map[string]bool{
+	"AuthFailure":                 true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"IncompleteSignature":         true,
+	"InvalidClientTokenId":        true,
+	"InvalidSignatureException":   true,
+	"MissingAuthenticationToken":  true,
+	"SignatureDoesNotMatch":       true,
+	"UnrecognizedClientException": true,
+}
+
+// AWS error codes for account limits and capacity that do not end in
+// LimitExceeded
+var awsQuotaCodes = map[string]bool{
+	"InsufficientInstanceCapacity":   true,
+	"MaxSpotInstanceCountExceeded":   true,
+	"ServiceQuotaExceededException":  true,
+	"ResourceLimitExceededException": true,
+}
+
+// awsErrorCode returns the API error code of err, or "" when it is not an
+// AWS API error.
+func awsErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// hasAWSErrorCode reports whether err is an AWS API error with one of codes.
+func hasAWSErrorCode(err error, codes ...string) bool {
+	code := awsErrorCode(err)
+	if code == "" {
+		return false
+	}
+	for _, c := range codes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// awsErrorClass maps an AWS API error code to one of the typed errors.
+func awsErrorClass(code string) error {
+	switch {
+	case code == "":
+		return nil
+	case awsCredentialCodes[code]:
+		return ErrCredentials
+	case awsQuotaCodes[code],
+		strings.HasSuffix(code, "LimitExceeded") && code != "RequestLimitExceeded",
+		code == "LimitExceededException":
+		return ErrQuotaExceeded
+	case code == "NoSuchEntity",
+		strings.HasSuffix(code, "NotFound"),
+		strings.HasSuffix(code, "NotFoundException"):
+		return ErrNotFound
+	case code == "EntityAlreadyExists",
+		strings.HasSuffix(code, ".Duplicate"),
+		strings.HasSuffix(code, "AlreadyExists"),
+		strings.HasSuffix(code, "AlreadyExistsException"):
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// classifyAWSErrors adds a middleware to every client built from cfg that
+// marks API errors with their typed error, so errors.Is works on anything an
+// AWS call returns.
+func classifyAWSErrors(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ClassifyErrors",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if err != nil {
+					err = withClass(err, awsErrorClass(awsErrorCode(err)))
+				}
+				return out, metadata, err
+			}), middleware.Before)
+	})
+}
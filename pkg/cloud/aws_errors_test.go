@@ -0,0 +1,107 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestAWSErrorClass(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"", nil},
+		{"AuthFailure", ErrCredentials},
+		{"ExpiredTokenException", ErrCredentials},
+		{"UnrecognizedClientException", ErrCredentials},
+		{"InsufficientInstanceCapacity", ErrQuotaExceeded},
+		{"VpcLimitExceeded", ErrQuotaExceeded},
+		{"LimitExceededException", ErrQuotaExceeded},
+		{"ServiceQuotaExceededException", ErrQuotaExceeded},
+		// Throttling, retried by the SDK rather than a quota
+		{"RequestLimitExceeded", nil},
+		{"NoSuchEntity", ErrNotFound},
+		{"InvalidVpcID.NotFound", ErrNotFound},
+		{"ParameterNotFound", ErrNotFound},
+		{"ResourceNotFoundException", ErrNotFound},
+		{"EntityAlreadyExists", ErrAlreadyExists},
+		{"InvalidPermission.Duplicate", ErrAlreadyExists},
+		{"AlreadyExistsException", ErrAlreadyExists},
+		{"InvalidParameterValue", nil},
+		{"DependencyViolation", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := awsErrorClass(tt.code); got != tt.want {
+				t.Errorf("awsErrorClass(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithClass(t *testing.T) {
+	apiErr := &smithy.GenericAPIError{Code: "InvalidVpcID.NotFound", Message: "vpc-123 does not exist"}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantIs    []error
+		wantNotIs []error
+		wantCode  string
+	}{
+		{
+			name:     "classified API error",
+			err:      withClass(apiErr, ErrNotFound),
+			wantIs:   []error{ErrNotFound, apiErr},
+			wantCode: "InvalidVpcID.NotFound",
+		},
+		{
+			name:      "wrapped classified API error",
+			err:       fmt.Errorf("failed to describe VPC: %w", withClass(apiErr, ErrNotFound)),
+			wantIs:    []error{ErrNotFound, apiErr},
+			wantNotIs: []error{ErrCredentials, ErrAlreadyExists},
+			wantCode:  "InvalidVpcID.NotFound",
+		},
+		{
+			name:      "no class",
+			err:       withClass(apiErr, nil),
+			wantIs:    []error{apiErr},
+			wantNotIs: []error{ErrNotFound},
+			wantCode:  "InvalidVpcID.NotFound",
+		},
+		{
+			name:   "deadline exceeded keeps its identity",
+			err:    fmt.Errorf("failed to wait for instance: %w", withClass(context.DeadlineExceeded, ErrQuotaExceeded)),
+			wantIs: []error{context.DeadlineExceeded, ErrQuotaExceeded},
+		},
+		{
+			name:      "canceled context is not classified",
+			err:       fmt.Errorf("failed to create VPC: %w", context.Canceled),
+			wantIs:    []error{context.Canceled},
+			wantNotIs: []error{ErrNotFound, ErrCredentials},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range tt.wantIs {
+				if !errors.Is(tt.err, target) {
+					t.Errorf("errors.Is(%v, %v) = false, want true", tt.err, target)
+				}
+			}
+			for _, target := range tt.wantNotIs {
+				if errors.Is(tt.err, target) {
+					t.Errorf("errors.Is(%v, %v) = true, want false", tt.err, target)
+				}
+			}
+			if got := awsErrorCode(tt.err); got != tt.wantCode {
+				t.Errorf("awsErrorCode() = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,580 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// liveClusters records which clusters and VPCs are still in use in a region.
+type liveClusters struct {
+	names map[string]bool
+	vpcs  map[string]bool
+}
+
+// owns reports whether a resource belongs to a live cluster, either through
+// its VPC or its cluster tag.
+func (l liveClusters) owns(vpcId string, tags map[string]string) bool {
+	if vpcId != "" && l.vpcs[vpcId] {
+		return true
+	}
+	return tags[clusterTag] != "" && l.names[tags[clusterTag]]
+}
+
+// FindOrphans scans for xstrapolate-managed resources that no live cluster
+// uses. IAM roles are global, so they are only reported when every region
+// was scanned: node roles of clusters that are gone, and the shared roles
+// once no cluster is left.
+func (m *AWSManager) FindOrphans(ctx context.Context, opts GCOptions) ([]OrphanResource, error) {
+	regions, err := m.gcRegions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var orphans []OrphanResource
+	liveNames := map[string]bool{}
+
+	for _, region := range regions {
+		slog.Info("🔍 Scanning region...", "region", region)
+		regional := m.forRegion(region)
+
+		live, err := regional.findLiveClusters(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find live clusters in %s: %w", region, err)
+		}
+		for name := range live.names {
+			liveNames[name] = true
+		}
+
+		found, err := regional.findRegionOrphans(ctx, live, opts.MinAge, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", region, err)
+		}
+		orphans = append(orphans, found...)
+	}
+
+	if opts.AllRegions {
+		found, err := m.findK3sNodeRoleOrphans(ctx, liveNames, opts.MinAge, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan IAM: %w", err)
+		}
+		orphans = append(orphans, found...)
+	}
+
+	switch {
+	case !opts.AllRegions:
+		slog.Info("ℹ️  IAM roles are shared across regions; use --all-regions to include them")
+	case len(liveNames) > 0:
+		slog.Info("ℹ️  Keeping IAM roles, still used by live clusters", "clusters", len(liveNames))
+	default:
+		found, err := m.findIAMOrphans(ctx, opts.MinAge, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan IAM: %w", err)
+		}
+		orphans = append(orphans, found...)
+	}
+
+	return orphans, nil
+}
+
+// DeleteOrphans deletes resources returned by FindOrphans. VPCs are deleted
+// with everything inside them, so resources in an orphaned VPC are not
+// deleted one by one.
+func (m *AWSManager) DeleteOrphans(ctx context.Context, orphans []OrphanResource) error {
+	byRegion := map[string][]OrphanResource{}
+	for _, orphan := range orphans {
+		byRegion[orphan.Region] = append(byRegion[orphan.Region], orphan)
+	}
+
+	var regions []string
+	for region := range byRegion {
+		if region != "" {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+
+	var failed []string
+	for _, region := range regions {
+		regional := m.forRegion(region)
+		resources := byRegion[region]
+
+		orphanVPCs := map[string]bool{}
+		var vpcIds []string
+		for _, resource := range resources {
+			if resource.Kind == "vpc" {
+				orphanVPCs[resource.ID] = true
+				vpcIds = append(vpcIds, resource.ID)
+			}
+		}
+		if len(vpcIds) > 0 {
+			if err := regional.teardownVPCs(ctx, vpcIds); err != nil {
+				for _, failure := range teardownFailures("vpc", err) {
+					slog.Warn("failed to delete resource", "id", failure.ID, "err", failure.Err)
+					failed = append(failed, failure.ID)
+				}
+			}
+		}
+
+		// Elastic IPs are released last, once the NAT gateways using them are gone
+		for _, resource := range resources {
+			if resource.Kind == "vpc" || orphanVPCs[resource.VpcID] {
+				continue
+			}
+			if err := regional.deleteOrphan(ctx, resource); err != nil {
+				slog.Warn("failed to delete resource", "kind", resource.Kind, "id", resource.ID, "err", err)
+				failed = append(failed, resource.ID)
+			}
+		}
+	}
+
+	iamOrphans := map[string]bool{}
+	for _, resource := range byRegion[""] {
+		iamOrphans[resource.ID] = true
+		if resource.Kind == "iam-role" && resource.Cluster != "" {
+			if err := m.deleteK3sNodeRole(ctx, resource.Cluster); err != nil {
+				slog.Warn("failed to delete resource", "kind", resource.Kind, "id", resource.ID, "err", err)
+				failed = append(failed, resource.ID)
+			}
+		}
+	}
+	if iamOrphans[ssmRoleName] || iamOrphans[ssmProfileName] {
+		if err := m.deleteSSMRole(ctx); err != nil {
+			failed = append(failed, ssmRoleName)
+		}
+	}
+	if iamOrphans[eksServiceRoleName] {
+		if err := m.deleteEKSRole(ctx); err != nil {
+			failed = append(failed, eksServiceRoleName)
+		}
+	}
+	if iamOrphans[eksNodeRoleName] {
+		if err := m.deleteEKSNodeRole(ctx); err != nil {
+			failed = append(failed, eksNodeRoleName)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w, failed to delete %d resource(s): %s", ErrPartialTeardown, len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (m *AWSManager) deleteOrphan(ctx context.Context, resource OrphanResource) error {
+	var err error
+	switch resource.Kind {
+	case "internet-gateway":
+		slog.Info("Deleting internet gateway", "igw", resource.ID)
+		_, err = m.ec2Client.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
+			InternetGatewayId: aws.String(resource.ID),
+		})
+	case "elastic-ip":
+		slog.Info("Releasing elastic IP", "allocation", resource.ID)
+		_, err = m.ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
+			AllocationId: aws.String(resource.ID),
+		})
+	case "kms-key":
+		slog.Info("Deleting k3s token key", "alias", resource.ID)
+		err = m.deleteK3sTokenKey(ctx, resource.Cluster)
+	case "ssm-parameter":
+		slog.Info("Deleting k3s token", "parameter", resource.ID)
+		err = m.deleteK3sToken(ctx, resource.Cluster)
+	default:
+		err = fmt.Errorf("unsupported resource kind outside an orphaned VPC")
+	}
+	return err
+}
+
+// gcRegions returns the regions a scan covers.
+func (m *AWSManager) gcRegions(ctx context.Context, opts GCOptions) ([]string, error) {
+	if !opts.AllRegions {
+		if len(opts.Regions) > 0 {
+			return opts.Regions, nil
+		}
+		return []string{m.region}, nil
+	}
+
+	result, err := m.ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list regions: %w", err)
+	}
+
+	var regions []string
+	for _, region := range result.Regions {
+		regions = append(regions, aws.ToString(region.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// forRegion returns a copy of the manager with clients for another region.
+func (m *AWSManager) forRegion(region string) *AWSManager {
+	if region == m.region {
+		return m
+	}
+
+	cfg := m.cfg.Copy()
+	cfg.Region = region
+
+	regional := *m
+	regional.cfg = cfg
+	regional.region = region
+	regional.eksClient = eks.NewFromConfig(cfg)
+	regional.ec2Client = ec2.NewFromConfig(cfg)
+	regional.elbClient = elasticloadbalancingv2.NewFromConfig(cfg)
+	regional.kmsClient = kms.NewFromConfig(cfg)
+	regional.ssmClient = ssm.NewFromConfig(cfg)
+	return &regional
+}
+
+// findLiveClusters collects the single-node, k3s and EKS clusters of the
+// region and the VPCs they run in.
+func (m *AWSManager) findLiveClusters(ctx context.Context) (liveClusters, error) {
+	live := liveClusters{names: map[string]bool{}, vpcs: map[string]bool{}}
+
+	instances := ec2.NewDescribeInstancesPaginator(m.ec2Client, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []string{clusterTag},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"running", "stopped", "stopping", "pending"},
+			},
+		},
+	})
+	for instances.HasMorePages() {
+		page, err := instances.NextPage(ctx)
+		if err != nil {
+			return live, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				live.names[ec2TagMap(instance.Tags)[clusterTag]] = true
+				if vpcId := aws.ToString(instance.VpcId); vpcId != "" {
+					live.vpcs[vpcId] = true
+				}
+			}
+		}
+	}
+
+	clusters := eks.NewListClustersPaginator(m.eksClient, &eks.ListClustersInput{})
+	for clusters.HasMorePages() {
+		page, err := clusters.NextPage(ctx)
+		if err != nil {
+			return live, fmt.Errorf("failed to list EKS clusters: %w", err)
+		}
+		for _, name := range page.Clusters {
+			live.names[name] = true
+
+			result, err := m.eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{
+				Name: aws.String(name),
+			})
+			if err != nil {
+				return live, fmt.Errorf("failed to describe EKS cluster %s: %w", name, err)
+			}
+			if result.Cluster.ResourcesVpcConfig != nil {
+				live.vpcs[aws.ToString(result.Cluster.ResourcesVpcConfig.VpcId)] = true
+			}
+		}
+	}
+
+	return live, nil
+}
+
+func (m *AWSManager) findRegionOrphans(ctx context.Context, live liveClusters, minAge time.Duration, now time.Time) ([]OrphanResource, error) {
+	var orphans []OrphanResource
+	managed := []types.Filter{
+		{
+			Name:   aws.String("tag:" + managedTag),
+			Values: []string{"true"},
+		},
+	}
+
+	add := func(kind, id, vpcId string, tags map[string]string) {
+		orphans = append(orphans, OrphanResource{
+			Region:  m.region,
+			Kind:    kind,
+			ID:      id,
+			Name:    tags["Name"],
+			Cluster: tags[clusterTag],
+			VpcID:   vpcId,
+		})
+	}
+
+	// VPCs decide the fate of everything inside them
+	orphanVPCs := map[string]bool{}
+	vpcs := ec2.NewDescribeVpcsPaginator(m.ec2Client, &ec2.DescribeVpcsInput{Filters: managed})
+	for vpcs.HasMorePages() {
+		page, err := vpcs.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe VPCs: %w", err)
+		}
+		for _, vpc := range page.Vpcs {
+			vpcId := aws.ToString(vpc.VpcId)
+			tags := ec2TagMap(vpc.Tags)
+			if live.owns(vpcId, tags) || !oldEnough(tags, minAge, now) {
+				continue
+			}
+			orphanVPCs[vpcId] = true
+			add("vpc", vpcId, vpcId, tags)
+		}
+	}
+
+	endpoints := ec2.NewDescribeVpcEndpointsPaginator(m.ec2Client, &ec2.DescribeVpcEndpointsInput{Filters: managed})
+	for endpoints.HasMorePages() {
+		page, err := endpoints.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe VPC endpoints: %w", err)
+		}
+		for _, endpoint := range page.VpcEndpoints {
+			vpcId := aws.ToString(endpoint.VpcId)
+			if orphanVPCs[vpcId] {
+				add("vpc-endpoint", aws.ToString(endpoint.VpcEndpointId), vpcId, ec2TagMap(endpoint.Tags))
+			}
+		}
+	}
+
+	groups := ec2.NewDescribeSecurityGroupsPaginator(m.ec2Client, &ec2.DescribeSecurityGroupsInput{Filters: managed})
+	for groups.HasMorePages() {
+		page, err := groups.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe security groups: %w", err)
+		}
+		for _, group := range page.SecurityGroups {
+			vpcId := aws.ToString(group.VpcId)
+			if orphanVPCs[vpcId] && aws.ToString(group.GroupName) != "default" {
+				add("security-group", aws.ToString(group.GroupId), vpcId, ec2TagMap(group.Tags))
+			}
+		}
+	}
+
+	gateways := ec2.NewDescribeInternetGatewaysPaginator(m.ec2Client, &ec2.DescribeInternetGatewaysInput{Filters: managed})
+	for gateways.HasMorePages() {
+		page, err := gateways.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe internet gateways: %w", err)
+		}
+		for _, igw := range page.InternetGateways {
+			tags := ec2TagMap(igw.Tags)
+			var vpcId string
+			if len(igw.Attachments) > 0 {
+				vpcId = aws.ToString(igw.Attachments[0].VpcId)
+			}
+			// Detached gateways are left behind when VPC creation fails half way
+			if orphanVPCs[vpcId] || (vpcId == "" && !live.owns("", tags) && oldEnough(tags, minAge, now)) {
+				add("internet-gateway", aws.ToString(igw.InternetGatewayId), vpcId, tags)
+			}
+		}
+	}
+
+	nats := ec2.NewDescribeNatGatewaysPaginator(m.ec2Client, &ec2.DescribeNatGatewaysInput{
+		Filter: append(managed, types.Filter{
+			Name:   aws.String("state"),
+			Values: []string{"pending", "available"},
+		}),
+	})
+	for nats.HasMorePages() {
+		page, err := nats.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe NAT gateways: %w", err)
+		}
+		for _, nat := range page.NatGateways {
+			vpcId := aws.ToString(nat.VpcId)
+			if orphanVPCs[vpcId] {
+				add("nat-gateway", aws.ToString(nat.NatGatewayId), vpcId, ec2TagMap(nat.Tags))
+			}
+		}
+	}
+
+	addresses, err := m.ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{Filters: managed})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe elastic IPs: %w", err)
+	}
+	for _, address := range addresses.Addresses {
+		tags := ec2TagMap(address.Tags)
+		if live.owns("", tags) || !oldEnough(tags, minAge, now) {
+			continue
+		}
+		// An associated address without a cluster tag may still be in use
+		if address.AssociationId != nil && tags[clusterTag] == "" {
+			continue
+		}
+		add("elastic-ip", aws.ToString(address.AllocationId), "", tags)
+	}
+
+	found, err := m.findK3sTokenOrphans(ctx, live, minAge, now)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, found...)
+
+	return orphans, nil
+}
+
+// findK3sTokenOrphans reports the token keys and parameters of k3s clusters
+// that are gone from the region. Both are found by their name, the alias
+// alias/xstrapolate/<cluster> and the parameter /xstrapolate/<cluster>/k3s-token.
+func (m *AWSManager) findK3sTokenOrphans(ctx context.Context, live liveClusters, minAge time.Duration, now time.Time) ([]OrphanResource, error) {
+	var orphans []OrphanResource
+	orphaned := func(cluster string, tags map[string]string) bool {
+		return tags[managedTag] == "true" && tags[clusterTag] == cluster &&
+			!live.names[cluster] && oldEnough(tags, minAge, now)
+	}
+
+	aliasPrefix := k3sTokenKeyAlias("")
+	aliases := kms.NewListAliasesPaginator(m.kmsClient, &kms.ListAliasesInput{})
+	for aliases.HasMorePages() {
+		page, err := aliases.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list KMS aliases: %w", err)
+		}
+		for _, alias := range page.Aliases {
+			aliasName := aws.ToString(alias.AliasName)
+			if !strings.HasPrefix(aliasName, aliasPrefix) || alias.TargetKeyId == nil {
+				continue
+			}
+			cluster := strings.TrimPrefix(aliasName, aliasPrefix)
+			result, err := m.kmsClient.ListResourceTags(ctx, &kms.ListResourceTagsInput{
+				KeyId: alias.TargetKeyId,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get tags of key %s: %w", aliasName, err)
+			}
+			if orphaned(cluster, kmsTagMap(result.Tags)) {
+				orphans = append(orphans, OrphanResource{Region: m.region, Kind: "kms-key", ID: aliasName, Name: aliasName, Cluster: cluster})
+			}
+		}
+	}
+
+	parameterPrefix := strings.TrimSuffix(k3sTokenParameter(""), "/k3s-token")
+	parameters := ssm.NewDescribeParametersPaginator(m.ssmClient, &ssm.DescribeParametersInput{
+		ParameterFilters: []ssmtypes.ParameterStringFilter{
+			{
+				Key:    aws.String("Name"),
+				Option: aws.String("BeginsWith"),
+				Values: []string{parameterPrefix},
+			},
+		},
+	})
+	for parameters.HasMorePages() {
+		page, err := parameters.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe SSM parameters: %w", err)
+		}
+		for _, parameter := range page.Parameters {
+			name := aws.ToString(parameter.Name)
+			cluster := strings.TrimSuffix(strings.TrimPrefix(name, parameterPrefix), "/k3s-token")
+			if name != k3sTokenParameter(cluster) {
+				continue
+			}
+			result, err := m.ssmClient.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+				ResourceType: ssmtypes.ResourceTypeForTaggingParameter,
+				ResourceId:   aws.String(name),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get tags of parameter %s: %w", name, err)
+			}
+			if orphaned(cluster, ssmTagMap(result.TagList)) {
+				orphans = append(orphans, OrphanResource{Region: m.region, Kind: "ssm-parameter", ID: name, Name: name, Cluster: cluster})
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// findIAMOrphans reports the shared IAM roles and instance profile that
+// still exist.
+func (m *AWSManager) findIAMOrphans(ctx context.Context, minAge time.Duration, now time.Time) ([]OrphanResource, error) {
+	var orphans []OrphanResource
+
+	for _, roleName := range []string{ssmRoleName, eksServiceRoleName, eksNodeRoleName} {
+		result, err := m.iamClient.GetRole(ctx, &iam.GetRoleInput{
+			RoleName: aws.String(roleName),
+		})
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get role %s: %w", roleName, err)
+		}
+		if !oldEnough(iamTagMap(result.Role.Tags), minAge, now) {
+			continue
+		}
+		orphans = append(orphans, OrphanResource{Kind: "iam-role", ID: roleName, Name: roleName})
+	}
+
+	result, err := m.iamClient.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(ssmProfileName),
+	})
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("failed to get instance profile %s: %w", ssmProfileName, err)
+		}
+	} else if oldEnough(iamTagMap(result.InstanceProfile.Tags), minAge, now) {
+		orphans = append(orphans, OrphanResource{Kind: "instance-profile", ID: ssmProfileName, Name: ssmProfileName})
+	}
+
+	return orphans, nil
+}
+
+// findK3sNodeRoleOrphans reports the node roles of clusters that are not
+// live in any region.
+func (m *AWSManager) findK3sNodeRoleOrphans(ctx context.Context, live map[string]bool, minAge time.Duration, now time.Time) ([]OrphanResource, error) {
+	var orphans []OrphanResource
+
+	roles := iam.NewListRolesPaginator(m.iamClient, &iam.ListRolesInput{
+		PathPrefix: aws.String(k3sNodeRolePath),
+	})
+	for roles.HasMorePages() {
+		page, err := roles.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list node roles: %w", err)
+		}
+		for _, role := range page.Roles {
+			roleName := aws.ToString(role.RoleName)
+			// ListRoles does not return tags
+			result, err := m.iamClient.ListRoleTags(ctx, &iam.ListRoleTagsInput{
+				RoleName: aws.String(roleName),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get tags of role %s: %w", roleName, err)
+			}
+			tags := iamTagMap(result.Tags)
+			cluster := tags[clusterTag]
+			if tags[managedTag] != "true" || cluster == "" || live[cluster] || !oldEnough(tags, minAge, now) {
+				continue
+			}
+			orphans = append(orphans, OrphanResource{Kind: "iam-role", ID: roleName, Name: roleName, Cluster: cluster})
+		}
+	}
+
+	return orphans, nil
+}
+
+// oldEnough reports whether a resource was created at least minAge ago.
+// Resources created before creation times were tagged always qualify.
+func oldEnough(tags map[string]string, minAge time.Duration, now time.Time) bool {
+	createdAt, err := time.Parse(time.RFC3339, tags[createdAtTag])
+	if err != nil {
+		return true
+	}
+	return now.Sub(createdAt) >= minAge
+}
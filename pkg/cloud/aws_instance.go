@@ -0,0 +1,167 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/viper"
+)
+
+const defaultInstanceType = "t3.medium"
+
+// spotSnapshotPolicyName is the inline policy on the SSM role that lets the
+// spot handler of one cluster upload its snapshots.
+func spotSnapshotPolicyName(clusterName string) string {
+	return "xstrapolate-spot-snapshots-" + clusterName
+}
+
+// loadInstanceConfig resolves EC2 instance settings. Command line flags take
+// precedence over cloud.aws.instance in the config file.
+func loadInstanceConfig(conf config.AWSInstanceConfig) config.AWSInstanceConfig {
+	instance := config.AWSInstanceConfig{
+		Type:           firstNonEmpty(viper.GetString("instance-type"), conf.Type, defaultInstanceType),
+		RootVolumeSize: conf.RootVolumeSize,
+		RootVolumeType: firstNonEmpty(viper.GetString("root-volume-type"), conf.RootVolumeType),
+		Encrypted:      viper.GetBool("encrypt-root-volume") || conf.Encrypted,
+		KMSKeyID:       firstNonEmpty(viper.GetString("root-volume-kms-key"), conf.KMSKeyID),
+	}
+	instance.Spot = config.AWSSpotConfig{
+		Enabled:        viper.GetBool("spot") || conf.Spot.Enabled,
+		MaxPrice:       firstNonEmpty(viper.GetString("spot-max-price"), conf.Spot.MaxPrice),
+		SnapshotBucket: firstNonEmpty(viper.GetString("spot-snapshot-bucket"), conf.Spot.SnapshotBucket),
+	}
+	if size := viper.GetInt32("root-volume-size"); size > 0 {
+		instance.RootVolumeSize = size
+	}
+	// A customer managed key only makes sense on an encrypted volume
+	if instance.KMSKeyID != "" {
+		instance.Encrypted = true
+	}
+	return instance
+}
+
+// resolveInstanceArchitecture returns the CPU architecture of an instance
+// type, preferring arm64 for Graviton types.
+func (m *AWSManager) resolveInstanceArchitecture(ctx context.Context, instanceType string) (types.ArchitectureValues, error) {
+	result, err := m.ec2Client.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{types.InstanceType(instanceType)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe instance type %s: %w", instanceType, err)
+	}
+	if len(result.InstanceTypes) == 0 || result.InstanceTypes[0].ProcessorInfo == nil {
+		return "", fmt.Errorf("instance type %s is not offered in region %s", instanceType, m.region)
+	}
+
+	archs := result.InstanceTypes[0].ProcessorInfo.SupportedArchitectures
+	for _, arch := range archs {
+		if arch == types.ArchitectureTypeX8664 {
+			return types.ArchitectureValuesX8664, nil
+		}
+	}
+	for _, arch := range archs {
+		if arch == types.ArchitectureTypeArm64 {
+			return types.ArchitectureValuesArm64, nil
+		}
+	}
+	return "", fmt.Errorf("instance type %s has no supported architecture (x86_64 or arm64)", instanceType)
+}
+
+// rootBlockDeviceMappings overrides the AMI's root volume when any volume
+// setting is configured. The AMI defaults are kept otherwise.
+func (m *AWSManager) rootBlockDeviceMappings(image *types.Image) []types.BlockDeviceMapping {
+	if m.instance.RootVolumeSize == 0 && m.instance.RootVolumeType == "" && !m.instance.Encrypted {
+		return nil
+	}
+
+	ebs := &types.EbsBlockDevice{
+		DeleteOnTermination: aws.Bool(true),
+	}
+	if m.instance.RootVolumeSize > 0 {
+		ebs.VolumeSize = aws.Int32(m.instance.RootVolumeSize)
+	}
+	if m.instance.RootVolumeType != "" {
+		ebs.VolumeType = types.VolumeType(m.instance.RootVolumeType)
+	}
+	if m.instance.Encrypted {
+		ebs.Encrypted = aws.Bool(true)
+		if m.instance.KMSKeyID != "" {
+			ebs.KmsKeyId = aws.String(m.instance.KMSKeyID)
+		}
+	}
+
+	return []types.BlockDeviceMapping{
+		{
+			DeviceName: image.RootDeviceName,
+			Ebs:        ebs,
+		},
+	}
+}
+
+// instanceMetadataOptions requires IMDSv2. The hop limit of two lets pods
+// reach the metadata service too.
+func instanceMetadataOptions() *types.InstanceMetadataOptionsRequest {
+	return &types.InstanceMetadataOptionsRequest{
+		HttpEndpoint:            types.InstanceMetadataEndpointStateEnabled,
+		HttpTokens:              types.HttpTokensStateRequired,
+		HttpPutResponseHopLimit: aws.Int32(2),
+	}
+}
+
+// kubernetesArch maps an EC2 architecture to the name used in Kubernetes
+// release artifacts.
+func kubernetesArch(arch types.ArchitectureValues) string {
+	if arch == types.ArchitectureValuesArm64 {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+// instanceShutdownBehavior is what a poweroff from inside the instance does:
+// stop it, or terminate a one-time spot instance, which cannot be stopped.
+func instanceShutdownBehavior(marketOptions *types.InstanceMarketOptionsRequest) types.ShutdownBehavior {
+	if marketOptions != nil {
+		return types.ShutdownBehaviorTerminate
+	}
+	return types.ShutdownBehaviorStop
+}
+
+// spotMarketOptions returns the market options for a one-time spot request,
+// or nil when spot is disabled.
+func (m *AWSManager) spotMarketOptions() (*types.InstanceMarketOptionsRequest, error) {
+	if !m.instance.Spot.Enabled {
+		return nil, nil
+	}
+
+	spotOptions := &types.SpotMarketOptions{
+		SpotInstanceType:             types.SpotInstanceTypeOneTime,
+		InstanceInterruptionBehavior: types.InstanceInterruptionBehaviorTerminate,
+	}
+	if m.instance.Spot.MaxPrice != "" {
+		if price, err := strconv.ParseFloat(m.instance.Spot.MaxPrice, 64); err != nil || price <= 0 {
+			return nil, fmt.Errorf("invalid spot max price %q: expected an hourly USD amount such as 0.02", m.instance.Spot.MaxPrice)
+		}
+		spotOptions.MaxPrice = aws.String(m.instance.Spot.MaxPrice)
+	}
+
+	return &types.InstanceMarketOptionsRequest{
+		MarketType:  types.MarketTypeSpot,
+		SpotOptions: spotOptions,
+	}, nil
+}
+
+// isSpotCapacityError reports whether a spot request failed for lack of
+// capacity or price, in which case on-demand is a sensible fallback.
+func isSpotCapacityError(err error) bool {
+	return hasAWSErrorCode(err,
+		"InsufficientInstanceCapacity",
+		"SpotMaxPriceTooLow",
+		"MaxSpotInstanceCountExceeded",
+		"InsufficientCapacity",
+	)
+}
@@ -0,0 +1,504 @@
+package cloud
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Clusters of type k3s run one or three k3s servers on embedded etcd and any
+// number of agents, spread over the private subnets of their VPC. The API is
+// served through an internal network load balancer in front of the servers.
+const (
+	k3sClusterType        = "k3s"
+	k3sServerResourceType = "k3s-server"
+	k3sServerPoolName     = "servers"
+	k3sAPIPort            = 6443
+
+	// Instance tags recording the cluster type and the load balancer's DNS name
+	clusterTypeTag = "xstrapolate-cluster-type"
+	k3sEndpointTag = "xstrapolate-k3s-endpoint"
+
+	// Where nodes write the join token read from SSM Parameter Store
+	k3sTokenFile = "/etc/rancher/k3s/token"
+)
+
+func (m *AWSManager) createK3sCluster(ctx context.Context, name string) (*ClusterInfo, error) {
+	servers, agents := m.k3s.Servers, m.k3s.Agents
+	if servers != 1 && servers != 3 {
+		return nil, fmt.Errorf("k3s clusters need 1 or 3 servers, not %d: embedded etcd keeps quorum with an odd number", servers)
+	}
+	if agents < 0 {
+		return nil, fmt.Errorf("the number of agents cannot be negative")
+	}
+	if m.instance.Spot.Enabled {
+		return nil, fmt.Errorf("spot instances are only supported for single-node clusters")
+	}
+	slog.Info("Creating k3s cluster with SSM access...", "servers", servers, "agents", agents)
+
+	arch, err := m.resolveInstanceArchitecture(ctx, m.instance.Type)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Using instance type", "type", m.instance.Type, "arch", arch)
+
+	createdAt := time.Now()
+	tags := m.tagsFor(name, createdAt).With(map[string]string{clusterTypeTag: k3sClusterType})
+
+	// Render user data first so invalid input fails before anything is created
+	if _, err := m.k3sServerUserData(name, arch, createdAt, ""); err != nil {
+		return nil, err
+	}
+
+	profile, err := m.ensureK3sNodeAccess(ctx, name, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	_, subnetIds, err := m.createVPCAndSubnetsForSSM(ctx, tags, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VPC and subnets: %w", err)
+	}
+	vpcId, err := m.subnetVPC(ctx, subnetIds[0])
+	if err != nil {
+		return nil, err
+	}
+	groupId, err := m.createK3sSecurityGroup(ctx, name, vpcId, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.putK3sToken(ctx, name, tags); err != nil {
+		return nil, err
+	}
+	endpoint, targetGroupArn, err := m.createK3sLoadBalancer(ctx, name, vpcId, subnetIds, tags)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := fmt.Sprintf("https://%s:%d", endpoint, k3sAPIPort)
+
+	image, err := m.getLatestAmazonLinuxAMI(ctx, arch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest AMI: %w", err)
+	}
+	launch := k3sInstances{
+		instanceType:     types.InstanceType(m.instance.Type),
+		image:            image,
+		profile:          profile,
+		securityGroupIds: []string{groupId},
+		count:            1,
+		tags:             tags.With(m.lifetime.Tags(createdAt)).With(map[string]string{k3sEndpointTag: endpoint}),
+	}
+
+	// The first server initialises etcd and runs the Flux bootstrap
+	launch.resourceType, launch.name, launch.subnetId = "instance", name+"-server", subnetIds[0]
+	launch.userData, err = m.k3sServerUserData(name, arch, createdAt, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	first, err := m.runK3sInstances(ctx, launch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch the first server: %w", err)
+	}
+	serverIds := []string{aws.ToString(first[0].InstanceId)}
+
+	// The other servers join the first directly rather than through the load
+	// balancer, which cannot route a target's connections back to itself
+	if servers > 1 {
+		launch.resourceType = k3sServerResourceType
+		launch.userData, err = m.k3sJoinUserData(name, arch, createdAt, "server", fmt.Sprintf("https://%s:%d", aws.ToString(first[0].PrivateIpAddress), k3sAPIPort))
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < int(servers); i++ {
+			launch.subnetId = subnetIds[i%len(subnetIds)]
+			joined, err := m.runK3sInstances(ctx, launch)
+			if err != nil {
+				return nil, fmt.Errorf("failed to launch server %d: %w", i+1, err)
+			}
+			serverIds = append(serverIds, aws.ToString(joined[0].InstanceId))
+		}
+	}
+	if err := m.registerK3sServers(ctx, targetGroupArn, serverIds); err != nil {
+		return nil, err
+	}
+
+	var agentIds []string
+	if agents > 0 {
+		launch.resourceType, launch.name = k3sAgentResourceType, name+"-agent"
+		launch.userData, err = m.k3sJoinUserData(name, arch, createdAt, "agent", apiURL)
+		if err != nil {
+			return nil, err
+		}
+		for i, count := range spreadCount(agents, len(subnetIds)) {
+			if count == 0 {
+				continue
+			}
+			launch.subnetId, launch.count = subnetIds[i], count
+			launched, err := m.runK3sInstances(ctx, launch)
+			if err != nil {
+				return nil, fmt.Errorf("failed to launch agents: %w", err)
+			}
+			for _, instance := range launched {
+				agentIds = append(agentIds, aws.ToString(instance.InstanceId))
+			}
+		}
+	}
+
+	slog.Info("k3s nodes launched in private subnets (SSM access only)", "servers", serverIds, "agents", agentIds)
+	slog.Info("Installing k3s and Flux...")
+	slog.Info("Setup is running in the background. This may take 5-10 minutes.")
+	slog.Info("Connect via SSM: aws ssm start-session --target " + serverIds[0])
+	slog.Info("Or follow from here: xstrapolate cluster logs " + name + " --cloud aws --follow")
+	slog.Info("API server (inside the VPC): " + apiURL)
+
+	nodePools := []NodePool{{Name: k3sServerPoolName, InstanceType: m.instance.Type, Count: servers}}
+	if agents > 0 {
+		nodePools = append(nodePools, NodePool{Name: k3sAgentPoolName, InstanceType: m.instance.Type, Count: agents})
+	}
+	return &ClusterInfo{
+		Name:           name,
+		Type:           k3sClusterType,
+		Provider:       "aws",
+		KubeconfigPath: k3sKubeconfigPath,
+		Endpoint:       apiURL,
+		Status:         "provisioning",
+		NodePools:      nodePools,
+	}, nil
+}
+
+// k3sServerUserData renders the bootstrap script of the first server of a
+// k3s cluster: a single-node bootstrap on embedded etcd, with the token from
+// Parameter Store and the load balancer in the API certificate.
+func (m *AWSManager) k3sServerUserData(name string, arch types.ArchitectureValues, createdAt time.Time, endpoint string) (string, error) {
+	params, err := m.userDataParams(name, arch, createdAt)
+	if err != nil {
+		return "", err
+	}
+	params.K3s.Datastore = "etcd"
+	params.Region = m.region
+	params.TokenParameter = k3sTokenParameter(name)
+	params.K3sArgs = []string{"--token-file", k3sTokenFile}
+	if endpoint != "" {
+		params.K3sArgs = append(params.K3sArgs, "--tls-san", endpoint)
+	}
+	return renderUserData(params)
+}
+
+// k3sJoinUserData renders the bootstrap script of a server or agent that
+// joins the cluster at serverURL.
+func (m *AWSManager) k3sJoinUserData(name string, arch types.ArchitectureValues, createdAt time.Time, role, serverURL string) (string, error) {
+	params, err := m.userDataParams(name, arch, createdAt)
+	if err != nil {
+		return "", err
+	}
+	params.Region = m.region
+	params.TokenParameter = k3sTokenParameter(name)
+	params.JoinRole = role
+	params.ServerURL = serverURL
+	return renderJoinUserData(params)
+}
+
+// k3sInstances describes instances of a k3s cluster launched together.
+type k3sInstances struct {
+	resourceType     string
+	name             string // Name tag of the instances and their root volumes
+	instanceType     types.InstanceType
+	image            *types.Image
+	profile          string // instance profile of the cluster's nodes
+	subnetId         string
+	securityGroupIds []string
+	userData         string
+	count            int32
+	tags             TagBuilder
+}
+
+// runK3sInstances launches instances with the cluster's instance profile
+// and waits until they are running. Launches are retried while a new instance
+// profile propagates to EC2.
+func (m *AWSManager) runK3sInstances(ctx context.Context, launch k3sInstances) ([]types.Instance, error) {
+	name := map[string]string{"Name": launch.name}
+	input := &ec2.RunInstancesInput{
+		ImageId:             launch.image.ImageId,
+		InstanceType:        launch.instanceType,
+		MinCount:            aws.Int32(launch.count),
+		MaxCount:            aws.Int32(launch.count),
+		SubnetId:            aws.String(launch.subnetId),
+		SecurityGroupIds:    launch.securityGroupIds,
+		UserData:            aws.String(base64.StdEncoding.EncodeToString([]byte(launch.userData))),
+		BlockDeviceMappings: m.rootBlockDeviceMappings(launch.image),
+		// Scheduled stops power the instances off from inside
+		InstanceInitiatedShutdownBehavior: types.ShutdownBehaviorStop,
+		MetadataOptions:                   instanceMetadataOptions(),
+		IamInstanceProfile: &types.IamInstanceProfileSpecification{
+			Name: aws.String(launch.profile),
+		},
+		TagSpecifications: append(
+			ec2TagSpecs(types.ResourceTypeInstance, launch.tags.For(launch.resourceType, name)),
+			ec2TagSpecs(types.ResourceTypeVolume, launch.tags.For("volume", name))...,
+		),
+	}
+
+	var result *ec2.RunInstancesOutput
+	err := retryWithBackoff(ctx, "instances/"+launch.name, func(err error) bool {
+		return hasAWSErrorCode(err, "InvalidParameterValue") && strings.Contains(err.Error(), "IAM Instance Profile")
+	}, func() error {
+		var err error
+		result, err = m.ec2Client.RunInstances(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var instanceIds []string
+	for _, instance := range result.Instances {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
+	}
+	slog.Info("Instances launched", "role", launch.resourceType, "instances", instanceIds, "subnet", launch.subnetId)
+
+	waiter := ec2.NewInstanceRunningWaiter(m.ec2Client)
+	err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIds,
+	}, waitTimeout(ctx, 10*time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for instances to start: %w", err)
+	}
+	return result.Instances, nil
+}
+
+// subnetVPC returns the VPC a subnet belongs to.
+func (m *AWSManager) subnetVPC(ctx context.Context, subnetId string) (string, error) {
+	result, err := m.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: []string{subnetId},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe subnet %s: %w", subnetId, err)
+	}
+	if len(result.Subnets) == 0 {
+		return "", withClass(fmt.Errorf("subnet %s not found", subnetId), ErrNotFound)
+	}
+	return aws.ToString(result.Subnets[0].VpcId), nil
+}
+
+// clusterPrivateSubnets returns the private subnets created for a cluster.
+func (m *AWSManager) clusterPrivateSubnets(ctx context.Context, cluster string) ([]string, error) {
+	result, err := m.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag:" + clusterTag),
+				Values: []string{cluster},
+			},
+			{
+				Name:   aws.String("tag:Type"),
+				Values: []string{"private"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster subnets: %w", err)
+	}
+	var subnetIds []string
+	for _, subnet := range result.Subnets {
+		subnetIds = append(subnetIds, aws.ToString(subnet.SubnetId))
+	}
+	return subnetIds, nil
+}
+
+// createK3sSecurityGroup lets the nodes of a k3s cluster reach each other on
+// any port, and the rest of the VPC, including the load balancer's health
+// checks, reach the API.
+func (m *AWSManager) createK3sSecurityGroup(ctx context.Context, cluster, vpcId string, tags TagBuilder) (string, error) {
+	groupName := "xstrapolate-k3s-" + cluster
+	result, err := m.ec2Client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:         aws.String(groupName),
+		Description:       aws.String("k3s nodes of cluster " + cluster),
+		VpcId:             aws.String(vpcId),
+		TagSpecifications: ec2TagSpecs(types.ResourceTypeSecurityGroup, tags.For("security-group", map[string]string{"Name": groupName})),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create security group: %w", err)
+	}
+	groupId := aws.ToString(result.GroupId)
+
+	_, err = m.ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: aws.String(groupId),
+		IpPermissions: []types.IpPermission{
+			{
+				IpProtocol:       aws.String("-1"),
+				UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String(groupId)}},
+			},
+			{
+				IpProtocol: aws.String("tcp"),
+				FromPort:   aws.Int32(k3sAPIPort),
+				ToPort:     aws.Int32(k3sAPIPort),
+				IpRanges:   []types.IpRange{{CidrIp: aws.String(m.network.cidr)}},
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to add security group rules: %w", err)
+	}
+	return groupId, nil
+}
+
+// k3sTokenParameter is the SSM Parameter Store name of a cluster's k3s token.
+func k3sTokenParameter(cluster string) string {
+	return "/xstrapolate/" + cluster + "/k3s-token"
+}
+
+// putK3sToken stores a new random join token for the cluster as a
+// SecureString. Nodes read it through the cluster's node role at boot.
+func (m *AWSManager) putK3sToken(ctx context.Context, cluster string, tags TagBuilder) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate k3s token: %w", err)
+	}
+	return m.putK3sTokenValue(ctx, cluster, hex.EncodeToString(secret), tags)
+}
+
+func (m *AWSManager) putK3sTokenValue(ctx context.Context, cluster, token string, tags TagBuilder) error {
+	_, err := m.ssmClient.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:        aws.String(k3sTokenParameter(cluster)),
+		Value:       aws.String(token),
+		Type:        ssmtypes.ParameterTypeSecureString,
+		KeyId:       aws.String(k3sTokenKeyAlias(cluster)),
+		Description: aws.String("k3s join token of cluster " + cluster),
+		Tags:        ssmTags(tags.For("ssm-parameter", nil)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store k3s token in Parameter Store: %w", err)
+	}
+	slog.Info("🔐 Stored k3s token", "parameter", k3sTokenParameter(cluster))
+	return nil
+}
+
+// deleteK3sToken removes the cluster's token parameter, if there is one.
+func (m *AWSManager) deleteK3sToken(ctx context.Context, cluster string) error {
+	_, err := m.ssmClient.DeleteParameter(ctx, &ssm.DeleteParameterInput{
+		Name: aws.String(k3sTokenParameter(cluster)),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// k3sLoadBalancerName derives the name of a cluster's load balancer and
+// target group, which are limited to 32 characters. Long cluster names are
+// shortened and made unique with a hash.
+func k3sLoadBalancerName(cluster string) string {
+	name := "k3s-" + cluster
+	if len(name) <= 32 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(cluster))
+	return strings.TrimRight(name[:23], "-") + "-" + hex.EncodeToString(sum[:4])
+}
+
+// createK3sLoadBalancer creates an internal network load balancer over the
+// private subnets forwarding the k3s API port to the servers, and returns
+// its DNS name and target group.
+func (m *AWSManager) createK3sLoadBalancer(ctx context.Context, cluster, vpcId string, subnetIds []string, tags TagBuilder) (string, string, error) {
+	name := k3sLoadBalancerName(cluster)
+	slog.Info("Creating internal load balancer for the k3s API...", "name", name)
+
+	lb, err := m.elbClient.CreateLoadBalancer(ctx, &elasticloadbalancingv2.CreateLoadBalancerInput{
+		Name:    aws.String(name),
+		Type:    elbtypes.LoadBalancerTypeEnumNetwork,
+		Scheme:  elbtypes.LoadBalancerSchemeEnumInternal,
+		Subnets: subnetIds,
+		Tags:    elbTags(tags.For("load-balancer", map[string]string{"Name": name})),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create load balancer: %w", err)
+	}
+	lbArn := aws.ToString(lb.LoadBalancers[0].LoadBalancerArn)
+	dnsName := aws.ToString(lb.LoadBalancers[0].DNSName)
+
+	tg, err := m.elbClient.CreateTargetGroup(ctx, &elasticloadbalancingv2.CreateTargetGroupInput{
+		Name:                aws.String(name),
+		Protocol:            elbtypes.ProtocolEnumTcp,
+		Port:                aws.Int32(k3sAPIPort),
+		VpcId:               aws.String(vpcId),
+		TargetType:          elbtypes.TargetTypeEnumInstance,
+		HealthCheckProtocol: elbtypes.ProtocolEnumTcp,
+		Tags:                elbTags(tags.For("target-group", map[string]string{"Name": name})),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create target group: %w", err)
+	}
+	targetGroupArn := aws.ToString(tg.TargetGroups[0].TargetGroupArn)
+
+	// Connections reach the servers from the load balancer's own addresses,
+	// so nodes in the cluster can use it too
+	_, err = m.elbClient.ModifyTargetGroupAttributes(ctx, &elasticloadbalancingv2.ModifyTargetGroupAttributesInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Attributes: []elbtypes.TargetGroupAttribute{
+			{Key: aws.String("preserve_client_ip.enabled"), Value: aws.String("false")},
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to configure target group: %w", err)
+	}
+
+	_, err = m.elbClient.CreateListener(ctx, &elasticloadbalancingv2.CreateListenerInput{
+		LoadBalancerArn: aws.String(lbArn),
+		Protocol:        elbtypes.ProtocolEnumTcp,
+		Port:            aws.Int32(k3sAPIPort),
+		DefaultActions: []elbtypes.Action{{
+			Type:           elbtypes.ActionTypeEnumForward,
+			TargetGroupArn: aws.String(targetGroupArn),
+		}},
+		Tags: elbTags(tags.For("listener", nil)),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create listener: %w", err)
+	}
+
+	slog.Info("Created load balancer", "dns", dnsName)
+	return dnsName, targetGroupArn, nil
+}
+
+func (m *AWSManager) registerK3sServers(ctx context.Context, targetGroupArn string, serverIds []string) error {
+	var targets []elbtypes.TargetDescription
+	for _, serverId := range serverIds {
+		targets = append(targets, elbtypes.TargetDescription{Id: aws.String(serverId)})
+	}
+	_, err := m.elbClient.RegisterTargets(ctx, &elasticloadbalancingv2.RegisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Targets:        targets,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register servers with the load balancer: %w", err)
+	}
+	return nil
+}
+
+// spreadCount splits total as evenly as possible over n buckets, the first
+// buckets taking the remainder.
+func spreadCount(total int32, n int) []int32 {
+	counts := make([]int32, n)
+	for i := range counts {
+		counts[i] = total / int32(n)
+		if int32(i) < total%int32(n) {
+			counts[i]++
+		}
+	}
+	return counts
+}
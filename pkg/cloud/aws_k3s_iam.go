@@ -0,0 +1,362 @@
+package cloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// The nodes of a cluster that joins agents through Parameter Store get a
+// role and instance profile of their own. Its inline policy only lets them
+// read the cluster's parameters, and the token is encrypted with a key of
+// the cluster that only this role may decrypt with.
+const (
+	// IAM path of the per-cluster roles and instance profiles, so gc can
+	// list them
+	k3sNodeRolePath = "/xstrapolate/"
+
+	k3sTokenPolicyName = "xstrapolate-k3s-token"
+
+	// Shortest waiting period KMS allows before a key is deleted
+	k3sTokenKeyDeletionDays = 7
+)
+
+// k3sNodeRoleName is the name of a cluster's node role and instance
+// profile. Role names are limited to 64 characters, so long cluster names
+// are shortened and made unique with a hash.
+func k3sNodeRoleName(cluster string) string {
+	name := "xstrapolate-k3s-" + cluster
+	if len(name) <= 64 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(cluster))
+	return strings.TrimRight(name[:55], "-") + "-" + hex.EncodeToString(sum[:4])
+}
+
+// k3sTokenKeyAlias is the alias of the KMS key encrypting a cluster's token.
+func k3sTokenKeyAlias(cluster string) string {
+	return "alias/xstrapolate/" + cluster
+}
+
+// k3sParameterScope matches the Parameter Store parameters of a cluster.
+func k3sParameterScope(region, accountID, cluster string) string {
+	return fmt.Sprintf("arn:aws:ssm:%s:%s:parameter/xstrapolate/%s/*", region, accountID, cluster)
+}
+
+// ensureK3sNodeAccess creates the node role, instance profile and token key
+// of a cluster, or completes them, and returns the instance profile name
+// once EC2 can use it.
+func (m *AWSManager) ensureK3sNodeAccess(ctx context.Context, cluster string, tags TagBuilder) (string, error) {
+	accountID, err := m.getAccountID(ctx)
+	if err != nil {
+		return "", err
+	}
+	scope := k3sParameterScope(m.region, accountID, cluster)
+
+	roleArn, err := m.ensureK3sNodeRole(ctx, cluster, tags)
+	if err != nil {
+		return "", err
+	}
+	keyArn, err := m.ensureK3sTokenKey(ctx, cluster, accountID, roleArn, scope, tags)
+	if err != nil {
+		return "", err
+	}
+
+	// AmazonSSMManagedInstanceCore allows ssm:GetParameter on every
+	// parameter; the deny narrows that down to the cluster's own
+	tokenPolicy := fmt.Sprintf(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Action": "ssm:GetParameter",
+				"Resource": %[1]q
+			},
+			{
+				"Effect": "Deny",
+				"Action": ["ssm:GetParameter", "ssm:GetParameters", "ssm:GetParametersByPath", "ssm:GetParameterHistory"],
+				"NotResource": %[1]q
+			},
+			{
+				"Effect": "Allow",
+				"Action": "kms:Decrypt",
+				"Resource": %[2]q,
+				"Condition": {"StringLike": {"kms:EncryptionContext:PARAMETER_ARN": %[1]q}}
+			}
+		]
+	}`, scope, keyArn)
+
+	roleName := k3sNodeRoleName(cluster)
+	_, err = m.iamClient.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(k3sTokenPolicyName),
+		PolicyDocument: aws.String(tokenPolicy),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to grant access to the k3s token: %w", err)
+	}
+
+	_, err = m.iamClient.CreateInstanceProfile(ctx, &iam.CreateInstanceProfileInput{
+		InstanceProfileName: aws.String(roleName),
+		Path:                aws.String(k3sNodeRolePath),
+		Tags:                iamTags(tags.For("instance-profile", nil)),
+	})
+	if err != nil && !errors.Is(err, ErrAlreadyExists) {
+		return "", fmt.Errorf("failed to create instance profile %s: %w", roleName, err)
+	}
+	_, err = m.iamClient.AddRoleToInstanceProfile(ctx, &iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String(roleName),
+		RoleName:            aws.String(roleName),
+	})
+	// A profile holds one role, so a second add fails with LimitExceeded
+	if err != nil && !hasAWSErrorCode(err, "LimitExceeded", "EntityAlreadyExists") {
+		return "", fmt.Errorf("failed to add role to instance profile: %w", err)
+	}
+
+	if _, err := m.waitForInstanceProfile(ctx, roleName); err != nil {
+		return "", fmt.Errorf("instance profile not ready: %w", err)
+	}
+	return roleName, nil
+}
+
+// ensureK3sNodeRole creates the node role of a cluster with SSM access and
+// returns its ARN.
+func (m *AWSManager) ensureK3sNodeRole(ctx context.Context, cluster string, tags TagBuilder) (string, error) {
+	roleName := k3sNodeRoleName(cluster)
+
+	assumeRolePolicyDocument := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Principal": {
+					"Service": "ec2.amazonaws.com"
+				},
+				"Action": "sts:AssumeRole"
+			}
+		]
+	}`
+
+	var roleArn string
+	result, err := m.iamClient.CreateRole(ctx, &iam.CreateRoleInput{
+		RoleName:                 aws.String(roleName),
+		Path:                     aws.String(k3sNodeRolePath),
+		AssumeRolePolicyDocument: aws.String(assumeRolePolicyDocument),
+		Description:              aws.String("k3s nodes of cluster " + cluster),
+		Tags:                     iamTags(tags.For("iam-role", nil)),
+	})
+	switch {
+	case err == nil:
+		roleArn = aws.ToString(result.Role.Arn)
+		slog.Info("✅ Created node role", "role", roleName)
+	case errors.Is(err, ErrAlreadyExists):
+		existing, err := m.iamClient.GetRole(ctx, &iam.GetRoleInput{
+			RoleName: aws.String(roleName),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get role %s: %w", roleName, err)
+		}
+		roleArn = aws.ToString(existing.Role.Arn)
+	default:
+		return "", fmt.Errorf("failed to create role %s: %w", roleName, err)
+	}
+
+	_, err = m.iamClient.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+		RoleName:  aws.String(roleName),
+		PolicyArn: aws.String("arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to attach SSM policy: %w", err)
+	}
+	return roleArn, nil
+}
+
+// ensureK3sTokenKey returns the ARN of the KMS key encrypting the cluster's
+// token, creating it if needed. The key policy lets the account encrypt and
+// the node role decrypt, both only for the cluster's parameters.
+func (m *AWSManager) ensureK3sTokenKey(ctx context.Context, cluster, accountID, roleArn, scope string, tags TagBuilder) (string, error) {
+	alias := k3sTokenKeyAlias(cluster)
+	existing, err := m.kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(alias),
+	})
+	if err == nil {
+		return aws.ToString(existing.KeyMetadata.Arn), nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("failed to look up key %s: %w", alias, err)
+	}
+
+	condition := fmt.Sprintf(`{
+		"StringLike": {"kms:EncryptionContext:PARAMETER_ARN": %q},
+		"StringEquals": {"kms:ViaService": %q}
+	}`, scope, "ssm."+m.region+".amazonaws.com")
+	keyPolicy := fmt.Sprintf(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "Administer",
+				"Effect": "Allow",
+				"Principal": {"AWS": "arn:aws:iam::%[1]s:root"},
+				"Action": [
+					"kms:CreateAlias",
+					"kms:DeleteAlias",
+					"kms:DescribeKey",
+					"kms:GetKeyPolicy",
+					"kms:PutKeyPolicy",
+					"kms:ListResourceTags",
+					"kms:TagResource",
+					"kms:UntagResource",
+					"kms:ScheduleKeyDeletion",
+					"kms:CancelKeyDeletion"
+				],
+				"Resource": "*"
+			},
+			{
+				"Sid": "StoreToken",
+				"Effect": "Allow",
+				"Principal": {"AWS": "arn:aws:iam::%[1]s:root"},
+				"Action": ["kms:Encrypt", "kms:GenerateDataKey*"],
+				"Resource": "*",
+				"Condition": %[3]s
+			},
+			{
+				"Sid": "ReadToken",
+				"Effect": "Allow",
+				"Principal": {"AWS": %[2]q},
+				"Action": "kms:Decrypt",
+				"Resource": "*",
+				"Condition": %[3]s
+			}
+		]
+	}`, accountID, roleArn, condition)
+
+	// KMS rejects a policy naming a role it cannot see yet
+	var created *kms.CreateKeyOutput
+	err = retryWithBackoff(ctx, "key/"+alias, func(err error) bool {
+		return hasAWSErrorCode(err, "MalformedPolicyDocumentException")
+	}, func() error {
+		var err error
+		created, err = m.kmsClient.CreateKey(ctx, &kms.CreateKeyInput{
+			Description: aws.String("k3s token of cluster " + cluster),
+			Policy:      aws.String(keyPolicy),
+			Tags:        kmsTags(tags.For("kms-key", nil)),
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create key for the k3s token: %w", err)
+	}
+	keyArn := aws.ToString(created.KeyMetadata.Arn)
+
+	_, err = m.kmsClient.CreateAlias(ctx, &kms.CreateAliasInput{
+		AliasName:   aws.String(alias),
+		TargetKeyId: aws.String(keyArn),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create alias %s: %w", alias, err)
+	}
+	slog.Info("🔐 Created key for the k3s token", "alias", alias)
+	return keyArn, nil
+}
+
+// deleteK3sNodeAccess schedules the token key of a cluster for deletion and
+// removes its node role and instance profile. The role is created first and
+// deleted last, so clusters without one are skipped.
+func (m *AWSManager) deleteK3sNodeAccess(ctx context.Context, cluster string) error {
+	_, err := m.iamClient.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(k3sNodeRoleName(cluster)),
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to check node role: %w", err)
+	}
+
+	if err := m.deleteK3sTokenKey(ctx, cluster); err != nil {
+		return err
+	}
+	return m.deleteK3sNodeRole(ctx, cluster)
+}
+
+func (m *AWSManager) deleteK3sNodeRole(ctx context.Context, cluster string) error {
+	roleName := k3sNodeRoleName(cluster)
+
+	_, err := m.iamClient.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+		InstanceProfileName: aws.String(roleName),
+		RoleName:            aws.String(roleName),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to remove role from instance profile: %w", err)
+	}
+	_, err = m.iamClient.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{
+		InstanceProfileName: aws.String(roleName),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete instance profile: %w", err)
+	}
+
+	_, err = m.iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(k3sTokenPolicyName),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete token policy: %w", err)
+	}
+	_, err = m.iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+		RoleName:  aws.String(roleName),
+		PolicyArn: aws.String("arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to detach SSM policy: %w", err)
+	}
+
+	_, err = m.iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	slog.Info("✅ Deleted node role and instance profile", "role", roleName)
+	return nil
+}
+
+func (m *AWSManager) deleteK3sTokenKey(ctx context.Context, cluster string) error {
+	alias := k3sTokenKeyAlias(cluster)
+	key, err := m.kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(alias),
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to look up key %s: %w", alias, err)
+	}
+
+	_, err = m.kmsClient.DeleteAlias(ctx, &kms.DeleteAliasInput{
+		AliasName: aws.String(alias),
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete alias %s: %w", alias, err)
+	}
+	_, err = m.kmsClient.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{
+		KeyId:               key.KeyMetadata.KeyId,
+		PendingWindowInDays: aws.Int32(k3sTokenKeyDeletionDays),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule deletion of the k3s token key: %w", err)
+	}
+	slog.Info("✅ Scheduled deletion of the k3s token key", "days", k3sTokenKeyDeletionDays)
+	return nil
+}
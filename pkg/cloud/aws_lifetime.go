@@ -0,0 +1,89 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// ListExpiredClusters finds single-node, k3s and EKS clusters in the region
+// whose expiry tag is before now.
+func (m *AWSManager) ListExpiredClusters(ctx context.Context, now time.Time) ([]ExpiredCluster, error) {
+	expired := map[string]ExpiredCluster{}
+
+	instances := ec2.NewDescribeInstancesPaginator(m.ec2Client, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []string{expiresAtTag},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"running", "stopped", "stopping", "pending"},
+			},
+		},
+	})
+	for instances.HasMorePages() {
+		page, err := instances.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				tags := map[string]string{}
+				for _, tag := range instance.Tags {
+					tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+				}
+
+				name := tags[clusterTag]
+				expiresAt, ok := parseExpiresAt(tags[expiresAtTag])
+				if name == "" || !ok || expiresAt.After(now) {
+					continue
+				}
+				expired[name] = ExpiredCluster{Name: name, Type: firstNonEmpty(tags[clusterTypeTag], "single-node"), ExpiresAt: expiresAt}
+			}
+		}
+	}
+
+	paginator := eks.NewListClustersPaginator(m.eksClient, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list EKS clusters: %w", err)
+		}
+
+		for _, name := range page.Clusters {
+			cluster, err := m.eksClient.DescribeCluster(ctx, &eks.DescribeClusterInput{
+				Name: aws.String(name),
+			})
+			if err != nil {
+				slog.Warn("failed to describe EKS cluster", "cluster", name, "err", err)
+				continue
+			}
+
+			tags := cluster.Cluster.Tags
+			expiresAt, ok := parseExpiresAt(tags[expiresAtTag])
+			if tags[managedTag] != "true" || !ok || expiresAt.After(now) {
+				continue
+			}
+			expired[name] = ExpiredCluster{Name: name, Type: "eks", ExpiresAt: expiresAt}
+		}
+	}
+
+	var clusters []ExpiredCluster
+	for _, cluster := range expired {
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters, nil
+}
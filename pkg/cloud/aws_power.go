@@ -0,0 +1,169 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Tag recording when a cluster was stopped, used to estimate savings on start
+const stoppedAtTag = "xstrapolate-stopped-at"
+
+func (m *AWSManager) StopCluster(ctx context.Context, name string) error {
+	instances, err := m.describeClusterInstances(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to find cluster instances: %w", err)
+	}
+	if len(instances) == 0 {
+		return withClass(fmt.Errorf("no instances found for cluster '%s'", name), ErrNotFound)
+	}
+
+	var instanceIds []string
+	for _, instance := range instances {
+		instanceId := aws.ToString(instance.InstanceId)
+		if instance.InstanceLifecycle == types.InstanceLifecycleTypeSpot {
+			return fmt.Errorf("instance %s is a one-time spot instance and cannot be stopped; use teardown instead", instanceId)
+		}
+		if instance.State != nil && instance.State.Name == types.InstanceStateNameStopped {
+			slog.Info("Instance is already stopped", "instance", instanceId)
+			continue
+		}
+		instanceIds = append(instanceIds, instanceId)
+	}
+	if len(instanceIds) == 0 {
+		return nil
+	}
+
+	slog.Info("🛑 Stopping instances", "instances", instanceIds)
+	_, err = m.ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: instanceIds,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stop instances: %w", err)
+	}
+
+	_, err = m.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: instanceIds,
+		Tags: []types.Tag{
+			{
+				Key:   aws.String(stoppedAtTag),
+				Value: aws.String(time.Now().UTC().Format(time.RFC3339)),
+			},
+		},
+	})
+	if err != nil {
+		slog.Warn("failed to record stop time", "err", err)
+	}
+
+	slog.Info("⏳ Waiting for instances to stop...")
+	waiter := ec2.NewInstanceStoppedWaiter(m.ec2Client)
+	err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIds,
+	}, waitTimeout(ctx, 10*time.Minute))
+	if err != nil {
+		return fmt.Errorf("failed waiting for instances to stop: %w", err)
+	}
+
+	return nil
+}
+
+func (m *AWSManager) StartCluster(ctx context.Context, name string) (*StartResult, error) {
+	instances, err := m.describeClusterInstances(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cluster instances: %w", err)
+	}
+	if len(instances) == 0 {
+		return nil, withClass(fmt.Errorf("no instances found for cluster '%s'", name), ErrNotFound)
+	}
+
+	result := &StartResult{}
+	var instanceIds []string
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
+
+		stoppedAt, ok := instanceStoppedAt(instance)
+		if !ok {
+			continue
+		}
+		stoppedFor := time.Since(stoppedAt)
+		if stoppedFor > result.StoppedFor {
+			result.StoppedFor = stoppedFor
+		}
+		if price, known := instanceHourlyPrice(m.region, string(instance.InstanceType)); known {
+			result.EstimatedSavings += price * stoppedFor.Hours()
+		}
+	}
+
+	slog.Info("▶️  Starting instances", "instances", instanceIds)
+	_, err = m.ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{
+		InstanceIds: instanceIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start instances: %w", err)
+	}
+
+	slog.Info("⏳ Waiting for instances to be running...")
+	waiter := ec2.NewInstanceRunningWaiter(m.ec2Client)
+	err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIds,
+	}, waitTimeout(ctx, 10*time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for instances to start: %w", err)
+	}
+
+	_, err = m.ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: instanceIds,
+		Tags:      []types.Tag{{Key: aws.String(stoppedAtTag)}},
+	})
+	if err != nil {
+		slog.Warn("failed to clear stop time", "err", err)
+	}
+
+	server := splitClusterInstances(instances).server
+	if server == nil {
+		return result, nil
+	}
+	slog.Info("⏳ Waiting for k3s to become healthy...")
+	if err := m.waitForK3sHealthy(ctx, aws.ToString(server.InstanceId), waitTimeout(ctx, 10*time.Minute)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// waitForK3sHealthy polls the k3s API server readiness endpoint over SSM.
+// The SSM agent needs a moment after boot, so command failures are retried.
+func (m *AWSManager) waitForK3sHealthy(ctx context.Context, instanceId string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	check := []string{"k3s kubectl get --raw /readyz && k3s kubectl wait --for=condition=Ready nodes --all --timeout=60s"}
+
+	var lastErr error
+	for time.Now().Before(deadline) {
+		_, lastErr = m.runSSMCommand(ctx, instanceId, check, 2*time.Minute)
+		if lastErr == nil {
+			slog.Info("✅ k3s is healthy")
+			return nil
+		}
+		if err := sleepContext(ctx, 15*time.Second); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("k3s did not become healthy within %s: %w", timeout, lastErr)
+}
+
+func instanceStoppedAt(instance types.Instance) (time.Time, bool) {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) != stoppedAtTag {
+			continue
+		}
+		stoppedAt, err := time.Parse(time.RFC3339, aws.ToString(tag.Value))
+		return stoppedAt, err == nil
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,157 @@
+package cloud
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+const (
+	cloudInitOutputLog = "/var/log/cloud-init-output.log"
+
+	// SSM truncates command output at 24000 characters, so the log is
+	// fetched in smaller chunks.
+	logChunkSize = 16000
+)
+
+// logChunkScript prints the log from a 1-based byte offset, at most
+// logChunkSize bytes of it. A full chunk is cut after its last newline so a
+// multibyte character is never split, which SSM would re-encode. The first
+// line reports how many bytes of the log follow and whether the chunk was
+// full, because the output SSM returns may not have the same length.
+const logChunkScript = `export LC_ALL=C
+f=$(mktemp)
+tail -c +%[1]d %[2]s 2>/dev/null | head -c %[3]d > "$f"
+full=0
+if [ "$(wc -c < "$f")" -eq %[3]d ]; then
+  full=1
+  if [ -n "$(tail -c 1 "$f")" ] && [ "$(wc -l < "$f")" -gt 0 ]; then
+    sed -i '$d' "$f"
+  fi
+fi
+echo "$(wc -c < "$f") $full"
+cat "$f"
+rm -f "$f"`
+
+// runSSMCommand runs a shell script on an instance through SSM Run Command
+// and returns its standard output.
+func (m *AWSManager) runSSMCommand(ctx context.Context, instanceId string, commands []string, timeout time.Duration) (string, error) {
+	sendResult, err := m.ssmClient.SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  []string{instanceId},
+		Parameters: map[string][]string{
+			"commands": commands,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send SSM command: %w", err)
+	}
+
+	commandId := aws.ToString(sendResult.Command.CommandId)
+
+	// The invocation is not visible immediately after SendCommand returns
+	if err := sleepContext(ctx, 2*time.Second); err != nil {
+		return "", err
+	}
+
+	output, err := ssm.NewCommandExecutedWaiter(m.ssmClient).WaitForOutput(ctx, &ssm.GetCommandInvocationInput{
+		CommandId:  aws.String(commandId),
+		InstanceId: aws.String(instanceId),
+	}, timeout)
+	if output != nil && output.Status == ssmtypes.CommandInvocationStatusSuccess {
+		return aws.ToString(output.StandardOutputContent), nil
+	}
+	if output != nil {
+		return "", fmt.Errorf("SSM command %s %s: %s", commandId, output.Status, strings.TrimSpace(aws.ToString(output.StandardErrorContent)))
+	}
+	return "", fmt.Errorf("failed waiting for SSM command %s: %w", commandId, err)
+}
+
+// RunOnCluster runs a script on the first server of a single-node or k3s
+// cluster through SSM, with KUBECONFIG set to the k3s kubeconfig.
+func (m *AWSManager) RunOnCluster(ctx context.Context, name string, script []string) (string, error) {
+	instanceId, err := m.runningClusterInstance(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	commands := append([]string{"export KUBECONFIG=" + k3sKubeconfigPath, "export PATH=$PATH:/usr/local/bin"}, script...)
+	return m.runSSMCommand(ctx, instanceId, commands, 20*time.Minute)
+}
+
+// runningClusterInstance returns the first server of a single-node or k3s
+// cluster, which must be running to take SSM commands.
+func (m *AWSManager) runningClusterInstance(ctx context.Context, name string) (string, error) {
+	nodes, err := m.describeK3sNodes(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkRunning(name, *nodes.server); err != nil {
+		return "", err
+	}
+	return aws.ToString(nodes.server.InstanceId), nil
+}
+
+// StreamBootstrapLogs reads the cloud-init output log from the cluster
+// instance over SSM and passes each line to onLine. With follow set it keeps
+// polling until the bootstrap script reports completion or failure.
+func (m *AWSManager) StreamBootstrapLogs(ctx context.Context, name string, follow bool, onLine func(line string)) error {
+	nodes, err := m.describeK3sNodes(ctx, name)
+	if err != nil {
+		return err
+	}
+	instanceId := aws.ToString(nodes.server.InstanceId)
+
+	offset := 1 // tail -c +N is 1-based
+	var partial string
+
+	for {
+		script := fmt.Sprintf(logChunkScript, offset, cloudInitOutputLog, logChunkSize)
+		output, err := m.runSSMCommand(ctx, instanceId, []string{script}, 2*time.Minute)
+		if err != nil {
+			return fmt.Errorf("failed to read bootstrap log from %s: %w", instanceId, err)
+		}
+		header, chunk, _ := strings.Cut(output, "\n")
+		var size, full int
+		if _, err := fmt.Sscanf(header, "%d %d", &size, &full); err != nil {
+			return fmt.Errorf("failed to read bootstrap log from %s: unexpected output %q", instanceId, header)
+		}
+		offset += size
+
+		// Hold back an unterminated last line until the rest of it arrives
+		data := partial + chunk
+		cut := strings.LastIndex(data, "\n") + 1
+		partial = data[cut:]
+
+		finished := false
+		scanner := bufio.NewScanner(strings.NewReader(data[:cut]))
+		for scanner.Scan() {
+			line := scanner.Text()
+			onLine(line)
+			if event, ok := ParseBootstrapMarker(line); ok && event.Kind != BootstrapEventProgress {
+				finished = true
+			}
+		}
+
+		if finished {
+			return nil
+		}
+		if full == 1 {
+			continue // more data is already available
+		}
+		if !follow {
+			if partial != "" {
+				onLine(partial)
+			}
+			return nil
+		}
+		if err := sleepContext(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package cloud
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// tagsFor returns the tag builder for resources of a cluster created at
+// createdAt.
+func (m *AWSManager) tagsFor(cluster string, createdAt time.Time) TagBuilder {
+	return NewTagBuilder(cluster, m.callerArn, createdAt, m.userTags)
+}
+
+// ec2TagSpecs converts tags into the TagSpecifications of an EC2 create call.
+func ec2TagSpecs(resourceType types.ResourceType, tags map[string]string) []types.TagSpecification {
+	return []types.TagSpecification{
+		{
+			ResourceType: resourceType,
+			Tags:         ec2Tags(tags),
+		},
+	}
+}
+
+func ec2Tags(tags map[string]string) []types.Tag {
+	ec2Tags := make([]types.Tag, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		ec2Tags = append(ec2Tags, types.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return ec2Tags
+}
+
+func iamTags(tags map[string]string) []iamtypes.Tag {
+	iamTags := make([]iamtypes.Tag, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		iamTags = append(iamTags, iamtypes.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return iamTags
+}
+
+func elbTags(tags map[string]string) []elbtypes.Tag {
+	elbTags := make([]elbtypes.Tag, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		elbTags = append(elbTags, elbtypes.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return elbTags
+}
+
+func ssmTags(tags map[string]string) []ssmtypes.Tag {
+	ssmTags := make([]ssmtypes.Tag, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		ssmTags = append(ssmTags, ssmtypes.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return ssmTags
+}
+
+func kmsTags(tags map[string]string) []kmstypes.Tag {
+	kmsTags := make([]kmstypes.Tag, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		kmsTags = append(kmsTags, kmstypes.Tag{
+			TagKey:   aws.String(key),
+			TagValue: aws.String(tags[key]),
+		})
+	}
+	return kmsTags
+}
+
+func ec2TagMap(tags []types.Tag) map[string]string {
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tagMap[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tagMap
+}
+
+func iamTagMap(tags []iamtypes.Tag) map[string]string {
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tagMap[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tagMap
+}
+
+func ssmTagMap(tags []ssmtypes.Tag) map[string]string {
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tagMap[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tagMap
+}
+
+func kmsTagMap(tags []kmstypes.Tag) map[string]string {
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tagMap[aws.ToString(tag.TagKey)] = aws.ToString(tag.TagValue)
+	}
+	return tagMap
+}
+
+func elbTagMap(tags []elbtypes.Tag) map[string]string {
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tagMap[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tagMap
+}
@@ -0,0 +1,537 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+)
+
+// teardownVPCs deletes xstrapolate-managed VPCs and everything in them as one
+// dependency graph:
+//
+//	endpoints, NAT gateways, load balancers → network interfaces → security groups, subnets
+//	load balancers → target groups
+//	NAT gateways → elastic IPs → internet gateways
+//	everything → VPC
+//
+// Route tables have no dependencies and are deleted straight away.
+func (m *AWSManager) teardownVPCs(ctx context.Context, vpcIds []string) error {
+	var nodes []*teardownNode
+	var failures []TeardownFailure
+
+	for _, vpcId := range vpcIds {
+		slog.Info("🗑️  Cleaning up VPC", "vpc", vpcId)
+		vpcNodes, err := m.vpcTeardownGraph(ctx, vpcId)
+		if err != nil {
+			failures = append(failures, TeardownFailure{ID: "vpc/" + vpcId, Err: err})
+			continue
+		}
+		nodes = append(nodes, vpcNodes...)
+	}
+
+	failures = append(failures, runTeardown(nodes)...)
+	if len(failures) > 0 {
+		return &TeardownError{Remaining: failures}
+	}
+	return nil
+}
+
+// vpcTeardownGraph discovers the resources of a VPC and links them by the
+// order AWS requires them to be deleted in.
+func (m *AWSManager) vpcTeardownGraph(ctx context.Context, vpcId string) ([]*teardownNode, error) {
+	inVPC := func(filterName string, extra ...types.Filter) []types.Filter {
+		return append([]types.Filter{
+			{
+				Name:   aws.String(filterName),
+				Values: []string{vpcId},
+			},
+		}, extra...)
+	}
+	managed := types.Filter{
+		Name:   aws.String("tag:" + managedTag),
+		Values: []string{"true"},
+	}
+
+	var nodes []*teardownNode
+	var endpointIds, natIds, eipIds, igwIds, routeTableIds, groupIds, subnetIds []string
+
+	endpoints, err := m.ec2Client.DescribeVpcEndpoints(ctx, &ec2.DescribeVpcEndpointsInput{
+		Filters: inVPC("vpc-id", managed),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe VPC endpoints: %w", err)
+	}
+	for _, endpoint := range endpoints.VpcEndpoints {
+		endpointId := aws.ToString(endpoint.VpcEndpointId)
+		id := "vpc-endpoint/" + endpointId
+		endpointIds = append(endpointIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:     id,
+			delete: func() error { return m.deleteVPCEndpoint(ctx, endpointId) },
+		})
+	}
+
+	nats, err := m.ec2Client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: inVPC("vpc-id", types.Filter{
+			Name:   aws.String("state"),
+			Values: []string{"pending", "available", "deleting"},
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe NAT gateways: %w", err)
+	}
+	var natAllocationIds []string
+	for _, nat := range nats.NatGateways {
+		natId := aws.ToString(nat.NatGatewayId)
+		id := "nat-gateway/" + natId
+		natIds = append(natIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:     id,
+			delete: func() error { return m.deleteNATGateway(ctx, natId) },
+		})
+		for _, address := range nat.NatGatewayAddresses {
+			if address.AllocationId != nil {
+				natAllocationIds = append(natAllocationIds, aws.ToString(address.AllocationId))
+			}
+		}
+	}
+
+	// Only release addresses xstrapolate allocated itself
+	if len(natAllocationIds) > 0 {
+		addresses, err := m.ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+			AllocationIds: natAllocationIds,
+			Filters:       []types.Filter{managed},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe elastic IPs: %w", err)
+		}
+		for _, address := range addresses.Addresses {
+			allocationId := aws.ToString(address.AllocationId)
+			id := "elastic-ip/" + allocationId
+			eipIds = append(eipIds, id)
+			nodes = append(nodes, &teardownNode{
+				id:   id,
+				deps: natIds,
+				delete: func() error {
+					slog.Info("Releasing elastic IP", "allocation", allocationId)
+					return retryWithBackoff(ctx, id, isAWSRetryable, func() error {
+						_, err := m.ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
+							AllocationId: aws.String(allocationId),
+						})
+						return err
+					})
+				},
+			})
+		}
+	}
+
+	lbNodes, lbIds, targetGroupIds, err := m.loadBalancerTeardownNodes(ctx, vpcId)
+	if err != nil {
+		return nil, err
+	}
+	nodes = append(nodes, lbNodes...)
+
+	eniId := "network-interfaces/" + vpcId
+	nodes = append(nodes, &teardownNode{
+		id:     eniId,
+		deps:   concat(endpointIds, natIds, lbIds),
+		delete: func() error { return m.drainNetworkInterfaces(ctx, vpcId) },
+	})
+
+	igws, err := m.ec2Client.DescribeInternetGateways(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: inVPC("attachment.vpc-id", managed),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe internet gateways: %w", err)
+	}
+	for _, igw := range igws.InternetGateways {
+		igwId := aws.ToString(igw.InternetGatewayId)
+		id := "internet-gateway/" + igwId
+		igwIds = append(igwIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:     id,
+			deps:   concat(natIds, eipIds),
+			delete: func() error { return m.deleteInternetGateway(ctx, igwId, vpcId) },
+		})
+	}
+
+	routeTables, err := m.ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: inVPC("vpc-id", managed),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe route tables: %w", err)
+	}
+	for _, routeTable := range routeTables.RouteTables {
+		if isMainRouteTable(routeTable) {
+			continue
+		}
+		routeTable := routeTable
+		id := "route-table/" + aws.ToString(routeTable.RouteTableId)
+		routeTableIds = append(routeTableIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:     id,
+			delete: func() error { return m.deleteRouteTable(ctx, routeTable) },
+		})
+	}
+
+	groups, err := m.ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: inVPC("vpc-id", managed),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe security groups: %w", err)
+	}
+	for _, group := range groups.SecurityGroups {
+		// The default security group goes away with the VPC
+		if aws.ToString(group.GroupName) == "default" {
+			continue
+		}
+		groupId := aws.ToString(group.GroupId)
+		id := "security-group/" + groupId
+		groupIds = append(groupIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:   id,
+			deps: []string{eniId},
+			delete: func() error {
+				slog.Info("Deleting security group", "sg", groupId)
+				return retryWithBackoff(ctx, id, isAWSRetryable, func() error {
+					_, err := m.ec2Client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{
+						GroupId: aws.String(groupId),
+					})
+					return err
+				})
+			},
+		})
+	}
+
+	subnets, err := m.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: inVPC("vpc-id", managed),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe subnets: %w", err)
+	}
+	for _, subnet := range subnets.Subnets {
+		subnetId := aws.ToString(subnet.SubnetId)
+		id := "subnet/" + subnetId
+		subnetIds = append(subnetIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:   id,
+			deps: concat([]string{eniId}, natIds),
+			delete: func() error {
+				slog.Info("Deleting subnet", "subnet", subnetId)
+				return retryWithBackoff(ctx, id, isAWSRetryable, func() error {
+					_, err := m.ec2Client.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{
+						SubnetId: aws.String(subnetId),
+					})
+					return err
+				})
+			},
+		})
+	}
+
+	vpcNode := "vpc/" + vpcId
+	nodes = append(nodes, &teardownNode{
+		id:   vpcNode,
+		deps: concat([]string{eniId}, endpointIds, natIds, lbIds, targetGroupIds, eipIds, igwIds, routeTableIds, groupIds, subnetIds),
+		delete: func() error {
+			err := retryWithBackoff(ctx, vpcNode, isAWSRetryable, func() error {
+				_, err := m.ec2Client.DeleteVpc(ctx, &ec2.DeleteVpcInput{
+					VpcId: aws.String(vpcId),
+				})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			slog.Info("✅ VPC deleted", "vpc", vpcId)
+			return nil
+		},
+	})
+
+	return nodes, nil
+}
+
+// loadBalancerTeardownNodes returns nodes deleting the xstrapolate-managed
+// load balancers and target groups of a VPC, and the IDs of both. Target
+// groups wait for the load balancers, whose listeners still use them.
+func (m *AWSManager) loadBalancerTeardownNodes(ctx context.Context, vpcId string) ([]*teardownNode, []string, []string, error) {
+	var lbArns, targetGroupArns []string
+	lbPaginator := elasticloadbalancingv2.NewDescribeLoadBalancersPaginator(m.elbClient, &elasticloadbalancingv2.DescribeLoadBalancersInput{})
+	for lbPaginator.HasMorePages() {
+		page, err := lbPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to describe load balancers: %w", err)
+		}
+		for _, lb := range page.LoadBalancers {
+			if aws.ToString(lb.VpcId) == vpcId {
+				lbArns = append(lbArns, aws.ToString(lb.LoadBalancerArn))
+			}
+		}
+	}
+	tgPaginator := elasticloadbalancingv2.NewDescribeTargetGroupsPaginator(m.elbClient, &elasticloadbalancingv2.DescribeTargetGroupsInput{})
+	for tgPaginator.HasMorePages() {
+		page, err := tgPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to describe target groups: %w", err)
+		}
+		for _, tg := range page.TargetGroups {
+			if aws.ToString(tg.VpcId) == vpcId {
+				targetGroupArns = append(targetGroupArns, aws.ToString(tg.TargetGroupArn))
+			}
+		}
+	}
+
+	lbArns, err := m.managedELBResources(ctx, lbArns)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	targetGroupArns, err = m.managedELBResources(ctx, targetGroupArns)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var nodes []*teardownNode
+	var lbIds, targetGroupIds []string
+	for _, lbArn := range lbArns {
+		lbArn := lbArn
+		id := "load-balancer/" + lbArn
+		lbIds = append(lbIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:     id,
+			delete: func() error { return m.deleteLoadBalancer(ctx, lbArn) },
+		})
+	}
+	for _, targetGroupArn := range targetGroupArns {
+		targetGroupArn := targetGroupArn
+		id := "target-group/" + targetGroupArn
+		targetGroupIds = append(targetGroupIds, id)
+		nodes = append(nodes, &teardownNode{
+			id:   id,
+			deps: lbIds,
+			delete: func() error {
+				slog.Info("Deleting target group", "arn", targetGroupArn)
+				return retryWithBackoff(ctx, id, isAWSRetryable, func() error {
+					_, err := m.elbClient.DeleteTargetGroup(ctx, &elasticloadbalancingv2.DeleteTargetGroupInput{
+						TargetGroupArn: aws.String(targetGroupArn),
+					})
+					return err
+				})
+			},
+		})
+	}
+	return nodes, lbIds, targetGroupIds, nil
+}
+
+// managedELBResources keeps the load balancer or target group ARNs tagged as
+// created by xstrapolate. Tags are read 20 resources at a time.
+func (m *AWSManager) managedELBResources(ctx context.Context, arns []string) ([]string, error) {
+	var managed []string
+	for start := 0; start < len(arns); start += 20 {
+		end := min(start+20, len(arns))
+		result, err := m.elbClient.DescribeTags(ctx, &elasticloadbalancingv2.DescribeTagsInput{
+			ResourceArns: arns[start:end],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe load balancer tags: %w", err)
+		}
+		for _, description := range result.TagDescriptions {
+			if elbTagMap(description.Tags)[managedTag] == "true" {
+				managed = append(managed, aws.ToString(description.ResourceArn))
+			}
+		}
+	}
+	return managed, nil
+}
+
+// deleteLoadBalancer deletes a load balancer with its listeners and waits
+// until it is gone, which releases its network interfaces.
+func (m *AWSManager) deleteLoadBalancer(ctx context.Context, lbArn string) error {
+	slog.Info("Deleting load balancer", "arn", lbArn)
+	_, err := m.elbClient.DeleteLoadBalancer(ctx, &elasticloadbalancingv2.DeleteLoadBalancerInput{
+		LoadBalancerArn: aws.String(lbArn),
+	})
+	if err != nil {
+		return err
+	}
+
+	waiter := elasticloadbalancingv2.NewLoadBalancersDeletedWaiter(m.elbClient)
+	return waiter.Wait(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{
+		LoadBalancerArns: []string{lbArn},
+	}, waitTimeout(ctx, 10*time.Minute))
+}
+
+func (m *AWSManager) deleteVPCEndpoint(ctx context.Context, endpointId string) error {
+	slog.Info("Deleting VPC endpoint", "endpoint", endpointId)
+	_, err := m.ec2Client.DeleteVpcEndpoints(ctx, &ec2.DeleteVpcEndpointsInput{
+		VpcEndpointIds: []string{endpointId},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deletion is asynchronous; the endpoint's network interfaces are only
+	// released once it is gone
+	return retryWithBackoff(ctx, "vpc-endpoint/"+endpointId, isAWSRetryable, func() error {
+		result, err := m.ec2Client.DescribeVpcEndpoints(ctx, &ec2.DescribeVpcEndpointsInput{
+			VpcEndpointIds: []string{endpointId},
+		})
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+		for _, endpoint := range result.VpcEndpoints {
+			if endpoint.State != types.StateDeleted {
+				return errStillInUse
+			}
+		}
+		return nil
+	})
+}
+
+func (m *AWSManager) deleteNATGateway(ctx context.Context, natId string) error {
+	slog.Info("Deleting NAT gateway", "nat", natId)
+	_, err := m.ec2Client.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{
+		NatGatewayId: aws.String(natId),
+	})
+	if err != nil {
+		return err
+	}
+
+	waiter := ec2.NewNatGatewayDeletedWaiter(m.ec2Client)
+	return waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{
+		NatGatewayIds: []string{natId},
+	}, waitTimeout(ctx, 10*time.Minute))
+}
+
+// drainNetworkInterfaces waits until the VPC has no network interfaces left,
+// deleting detached ones. Interfaces owned by endpoints, NAT gateways and
+// terminated instances disappear on their own shortly after their owner.
+func (m *AWSManager) drainNetworkInterfaces(ctx context.Context, vpcId string) error {
+	return retryWithBackoff(ctx, "network-interfaces/"+vpcId, isAWSRetryable, func() error {
+		result, err := m.ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []types.Filter{
+				{
+					Name:   aws.String("vpc-id"),
+					Values: []string{vpcId},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		inUse := 0
+		for _, eni := range result.NetworkInterfaces {
+			eniId := aws.ToString(eni.NetworkInterfaceId)
+			if eni.Status != types.NetworkInterfaceStatusAvailable || aws.ToBool(eni.RequesterManaged) {
+				inUse++
+				continue
+			}
+
+			slog.Info("Deleting network interface", "eni", eniId)
+			_, err := m.ec2Client.DeleteNetworkInterface(ctx, &ec2.DeleteNetworkInterfaceInput{
+				NetworkInterfaceId: aws.String(eniId),
+			})
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				inUse++
+			}
+		}
+
+		if inUse > 0 {
+			return fmt.Errorf("%d network interface(s) %w", inUse, errStillInUse)
+		}
+		return nil
+	})
+}
+
+func (m *AWSManager) deleteInternetGateway(ctx context.Context, igwId, vpcId string) error {
+	slog.Info("Detaching and deleting internet gateway", "igw", igwId)
+	id := "internet-gateway/" + igwId
+
+	err := retryWithBackoff(ctx, id, isAWSRetryable, func() error {
+		_, err := m.ec2Client.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
+			InternetGatewayId: aws.String(igwId),
+			VpcId:             aws.String(vpcId),
+		})
+		if err != nil && hasAWSErrorCode(err, "Gateway.NotAttached") {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to detach: %w", err)
+	}
+
+	return retryWithBackoff(ctx, id, isAWSRetryable, func() error {
+		_, err := m.ec2Client.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
+			InternetGatewayId: aws.String(igwId),
+		})
+		return err
+	})
+}
+
+func (m *AWSManager) deleteRouteTable(ctx context.Context, routeTable types.RouteTable) error {
+	rtId := aws.ToString(routeTable.RouteTableId)
+	slog.Info("Deleting route table", "rtb", rtId)
+
+	// A route table cannot be deleted while subnets are associated with it
+	for _, association := range routeTable.Associations {
+		if aws.ToBool(association.Main) || association.RouteTableAssociationId == nil {
+			continue
+		}
+		_, err := m.ec2Client.DisassociateRouteTable(ctx, &ec2.DisassociateRouteTableInput{
+			AssociationId: association.RouteTableAssociationId,
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to disassociate: %w", err)
+		}
+	}
+
+	return retryWithBackoff(ctx, "route-table/"+rtId, isAWSRetryable, func() error {
+		_, err := m.ec2Client.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{
+			RouteTableId: aws.String(rtId),
+		})
+		return err
+	})
+}
+
+func isMainRouteTable(routeTable types.RouteTable) bool {
+	for _, association := range routeTable.Associations {
+		if aws.ToBool(association.Main) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAWSRetryable reports whether a delete failed only because another
+// resource has not been released yet.
+func isAWSRetryable(err error) bool {
+	return hasAWSErrorCode(err, "DependencyViolation", "InvalidIPAddress.InUse", "AuthFailure.AddressInUse")
+}
+
+// teardownFailures flattens an error returned by a teardown into the list of
+// resources left behind.
+func teardownFailures(id string, err error) []TeardownFailure {
+	var teardownErr *TeardownError
+	if errors.As(err, &teardownErr) {
+		return teardownErr.Remaining
+	}
+	return []TeardownFailure{{ID: id, Err: err}}
+}
+
+func concat(lists ...[]string) []string {
+	var all []string
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	return all
+}
@@ -0,0 +1,288 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// Release assets of a k3s version, downloaded by upgradeK3sNode
+const k3sReleaseURL = "https://github.com/k3s-io/k3s/releases/download/"
+
+// PlanUpgrade checks that the cluster can move to version and lists the
+// steps: the EKS control plane then its node groups, or the k3s servers of
+// a single-node or k3s cluster then its agents.
+func (m *AWSManager) PlanUpgrade(ctx context.Context, name, version string) (*UpgradePlan, error) {
+	info, err := m.describeEKSCluster(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		return m.planEKSUpgrade(ctx, info, version)
+	}
+
+	nodes, err := m.describeK3sNodes(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return m.planK3sUpgrade(ctx, name, nodes, version)
+}
+
+func (m *AWSManager) planEKSUpgrade(ctx context.Context, info *ClusterInfo, version string) (*UpgradePlan, error) {
+	if !eksVersionPattern.MatchString(version) {
+		return nil, fmt.Errorf("invalid EKS version %q: expected a minor version such as 1.30", version)
+	}
+	from, err := parseKubeVersion(info.Version)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseKubeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := m.describeNodePools(ctx, info.Name)
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[string]kubeVersion{}
+	for _, pool := range pools {
+		if nodes[pool.Name], err = parseKubeVersion(pool.Version); err != nil {
+			return nil, fmt.Errorf("node pool %s: %w", pool.Name, err)
+		}
+	}
+	if err := checkUpgradeSkew(info.Name, from, to, nodes); err != nil {
+		return nil, err
+	}
+
+	plan := &UpgradePlan{Cluster: info.Name, Type: "eks", From: info.Version, To: version}
+	if from.compare(to) < 0 {
+		plan.Steps = append(plan.Steps, UpgradeStep{Component: "control plane", From: info.Version, To: version})
+	}
+	// Node groups follow the control plane, never the other way round
+	for _, pool := range pools {
+		if nodes[pool.Name].compare(to) < 0 {
+			plan.Steps = append(plan.Steps, UpgradeStep{Component: "node pool", Target: pool.Name, From: pool.Version, To: version})
+		}
+	}
+	return plan, nil
+}
+
+func (m *AWSManager) planK3sUpgrade(ctx context.Context, name string, nodes k3sNodes, version string) (*UpgradePlan, error) {
+	instanceId := aws.ToString(nodes.server.InstanceId)
+	for _, instance := range nodes.all() {
+		if err := checkRunning(name, instance); err != nil {
+			return nil, err
+		}
+	}
+
+	target, err := resolveK3sVersion(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	current, err := m.k3sVersion(ctx, instanceId)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := parseKubeVersion(current)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseKubeVersion(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions of the joined servers and agents, which must not fall too far
+	// behind the target
+	nodeVersions := map[string]string{}
+	versions := map[string]kubeVersion{}
+	for _, instance := range append(append([]types.Instance(nil), nodes.servers...), nodes.agents...) {
+		nodeId := aws.ToString(instance.InstanceId)
+		if nodeVersions[nodeId], err = m.k3sVersion(ctx, nodeId); err != nil {
+			return nil, err
+		}
+		if versions[nodeId], err = parseKubeVersion(nodeVersions[nodeId]); err != nil {
+			return nil, fmt.Errorf("node %s: %w", nodeId, err)
+		}
+	}
+	if err := checkUpgradeSkew(name, from, to, versions); err != nil {
+		return nil, err
+	}
+
+	plan := &UpgradePlan{Cluster: name, Type: nodes.clusterType(), From: current, To: target}
+	if from.compare(to) < 0 {
+		plan.Steps = append(plan.Steps, UpgradeStep{Component: "k3s", Target: instanceId, From: current, To: target})
+	}
+	// Servers one at a time so etcd keeps quorum, then the agents, never the
+	// other way round
+	for _, server := range nodes.servers {
+		serverId := aws.ToString(server.InstanceId)
+		if versions[serverId].compare(to) < 0 {
+			plan.Steps = append(plan.Steps, UpgradeStep{Component: "k3s", Target: serverId, From: nodeVersions[serverId], To: target})
+		}
+	}
+	for _, agent := range nodes.agents {
+		agentId := aws.ToString(agent.InstanceId)
+		if versions[agentId].compare(to) < 0 {
+			plan.Steps = append(plan.Steps, UpgradeStep{Component: "k3s agent", Target: agentId, From: nodeVersions[agentId], To: target})
+		}
+	}
+	return plan, nil
+}
+
+// Upgrade carries out the steps of a plan in order, waiting for each to
+// finish before starting the next.
+func (m *AWSManager) Upgrade(ctx context.Context, plan *UpgradePlan) error {
+	for _, step := range plan.Steps {
+		var err error
+		switch step.Component {
+		case "control plane":
+			slog.Info("⬆️  Upgrading EKS control plane (this will take 20-40 minutes)...", "cluster", plan.Cluster, "from", step.From, "to", step.To)
+			err = m.updateEKSVersion(ctx, plan.Cluster, "", step.To)
+		case "node pool":
+			slog.Info("⬆️  Upgrading node pool", "cluster", plan.Cluster, "pool", step.Target, "from", step.From, "to", step.To)
+			err = m.updateEKSVersion(ctx, plan.Cluster, step.Target, step.To)
+		case "k3s":
+			slog.Info("⬆️  Upgrading k3s", "cluster", plan.Cluster, "instance", step.Target, "from", step.From, "to", step.To)
+			err = m.upgradeK3sNode(ctx, step.Target, step.Target, "k3s", step.To)
+		case "k3s agent":
+			slog.Info("⬆️  Upgrading k3s agent", "cluster", plan.Cluster, "instance", step.Target, "from", step.From, "to", step.To)
+			var serverId string
+			if serverId, err = m.runningClusterInstance(ctx, plan.Cluster); err == nil {
+				err = m.upgradeK3sNode(ctx, serverId, step.Target, "k3s-agent", step.To)
+			}
+		default:
+			err = fmt.Errorf("unknown upgrade step %s", step.Component)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info("✅ Cluster upgraded", "cluster", plan.Cluster, "version", plan.To)
+	return nil
+}
+
+// updateEKSVersion upgrades the control plane, or the node group when
+// nodegroup is set, and waits for the update to finish. Node groups roll
+// their nodes onto the latest AMI of the version, respecting pod disruption
+// budgets.
+func (m *AWSManager) updateEKSVersion(ctx context.Context, cluster, nodegroup, version string) error {
+	var update *ekstypes.Update
+	if nodegroup == "" {
+		result, err := m.eksClient.UpdateClusterVersion(ctx, &eks.UpdateClusterVersionInput{
+			Name:    aws.String(cluster),
+			Version: aws.String(version),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upgrade control plane: %w", err)
+		}
+		update = result.Update
+	} else {
+		result, err := m.eksClient.UpdateNodegroupVersion(ctx, &eks.UpdateNodegroupVersionInput{
+			ClusterName:   aws.String(cluster),
+			NodegroupName: aws.String(nodegroup),
+			Version:       aws.String(version),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upgrade node pool %s: %w", nodegroup, err)
+		}
+		update = result.Update
+	}
+
+	return m.waitForEKSUpdate(ctx, cluster, nodegroup, aws.ToString(update.Id))
+}
+
+// waitForEKSUpdate polls an EKS update until it succeeds or fails.
+func (m *AWSManager) waitForEKSUpdate(ctx context.Context, cluster, nodegroup, updateId string) error {
+	input := &eks.DescribeUpdateInput{
+		Name:     aws.String(cluster),
+		UpdateId: aws.String(updateId),
+	}
+	if nodegroup != "" {
+		input.NodegroupName = aws.String(nodegroup)
+	}
+
+	deadline := time.Now().Add(waitTimeout(ctx, 90*time.Minute))
+	for time.Now().Before(deadline) {
+		result, err := m.eksClient.DescribeUpdate(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to describe update %s: %w", updateId, err)
+		}
+
+		switch result.Update.Status {
+		case ekstypes.UpdateStatusSuccessful:
+			return nil
+		case ekstypes.UpdateStatusFailed, ekstypes.UpdateStatusCancelled:
+			var messages []string
+			for _, updateErr := range result.Update.Errors {
+				messages = append(messages, aws.ToString(updateErr.ErrorMessage))
+			}
+			return fmt.Errorf("update %s %s: %s", updateId, strings.ToLower(string(result.Update.Status)), strings.Join(messages, "; "))
+		}
+
+		if err := sleepContext(ctx, 30*time.Second); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("update %s did not finish in time", updateId)
+}
+
+// k3sVersion returns the k3s release running on an instance.
+func (m *AWSManager) k3sVersion(ctx context.Context, instanceId string) (string, error) {
+	output, err := m.runSSMCommand(ctx, instanceId, []string{"k3s --version | head -n 1"}, time.Minute)
+	if err != nil {
+		return "", fmt.Errorf("failed to read k3s version: %w", err)
+	}
+
+	// k3s version v1.29.4+k3s1 (94e29e2e)
+	fields := strings.Fields(output)
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected k3s version output %q", strings.TrimSpace(output))
+	}
+	return fields[2], nil
+}
+
+// upgradeK3sNode replaces the k3s binary of a server or agent with a
+// checksum-verified release and restarts its service. The install script is
+// not re-run, so the arguments in the systemd unit are kept. Health is
+// checked on the server.
+func (m *AWSManager) upgradeK3sNode(ctx context.Context, serverId, instanceId, service, version string) error {
+	releaseURL := k3sReleaseURL + strings.ReplaceAll(version, "+", "%2B")
+	script := []string{
+		"set -eu",
+		`case "$(uname -m)" in aarch64|arm64) BIN=k3s-arm64; SUMS=sha256sum-arm64.txt ;; *) BIN=k3s; SUMS=sha256sum-amd64.txt ;; esac`,
+		`cd "$(mktemp -d)"`,
+		fmt.Sprintf(`curl -sfL -o "$BIN" %s/"$BIN"`, shellQuote(releaseURL)),
+		fmt.Sprintf(`curl -sfL -o sums %s/"$SUMS"`, shellQuote(releaseURL)),
+		`grep " $BIN\$" sums | sha256sum -c -`,
+		"install -m 755 \"$BIN\" /usr/local/bin/k3s",
+		"systemctl restart " + service,
+	}
+	if _, err := m.runSSMCommand(ctx, instanceId, script, 10*time.Minute); err != nil {
+		return fmt.Errorf("failed to upgrade k3s on %s: %w", instanceId, err)
+	}
+
+	slog.Info("⏳ Waiting for k3s to become healthy...")
+	if err := m.waitForK3sHealthy(ctx, serverId, waitTimeout(ctx, 10*time.Minute)); err != nil {
+		return err
+	}
+
+	current, err := m.k3sVersion(ctx, instanceId)
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return fmt.Errorf("k3s on %s reports %s after the upgrade, expected %s", instanceId, current, version)
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package cloud
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/drduker/xstrapolate/pkg/config"
 	"github.com/spf13/viper"
 )
 
@@ -14,35 +19,69 @@ type AzureManager struct {
 	credential     azcore.TokenCredential
 	subscriptionID string
 	location       string
+	userTags       map[string]string
 }
 
-func NewAzureManager() (*AzureManager, error) {
-	subscriptionID := viper.GetString("cloud.azure.subscription_id")
+// NewAzureManager builds a manager from the loaded config.
+func NewAzureManager(conf *config.Config) (*AzureManager, error) {
+	azure := conf.Cloud.Azure
+
+	subscriptionID := firstNonEmpty(os.Getenv("AZURE_SUBSCRIPTION_ID"), azure.SubscriptionID)
 	if subscriptionID == "" {
-		return nil, fmt.Errorf("Azure subscription ID not configured")
+		return nil, fmt.Errorf("Azure subscription ID not configured (set cloud.azure.subscription_id or AZURE_SUBSCRIPTION_ID)")
 	}
 
-	location := viper.GetString("location")
-	if location == "" {
-		location = viper.GetString("cloud.azure.location")
-	}
-	if location == "" {
-		location = "eastus"
+	location := firstNonEmpty(viper.GetString("location"), azure.Location, "eastus")
+
+	userTags, err := loadUserTags(conf.Tags)
+	if err != nil {
+		return nil, err
 	}
 
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	cred, source, err := azureCredential(azure)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+		return nil, withClass(fmt.Errorf("failed to create Azure credential: %w", err), ErrCredentials)
 	}
+	slog.Info("Using Azure credentials", "source", source)
 
 	return &AzureManager{
 		credential:     cred,
 		subscriptionID: subscriptionID,
 		location:       location,
+		userTags:       userTags,
 	}, nil
 }
 
-func (m *AzureManager) CreateCluster(name, clusterType string) (*ClusterInfo, error) {
+// azureCredential picks where Azure credentials come from, in this order:
+//
+//  1. AZURE_CLIENT_SECRET and friends in the environment
+//  2. tenant_id, client_id and client_secret in the config file
+//  3. the SDK default chain: managed identity and the Azure CLI login
+func azureCredential(azure config.AzureConfig) (azcore.TokenCredential, string, error) {
+	if os.Getenv("AZURE_CLIENT_SECRET") != "" {
+		cred, err := azidentity.NewEnvironmentCredential(nil)
+		return cred, "environment", err
+	}
+
+	set := 0
+	for _, value := range []string{azure.TenantID, azure.ClientID, azure.ClientSecret} {
+		if value != "" {
+			set++
+		}
+	}
+	switch set {
+	case 0:
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		return cred, "default credential chain", err
+	case 3:
+		cred, err := azidentity.NewClientSecretCredential(azure.TenantID, azure.ClientID, azure.ClientSecret, nil)
+		return cred, "config file", err
+	default:
+		return nil, "", fmt.Errorf("cloud.azure.tenant_id, client_id and client_secret must be set together")
+	}
+}
+
+func (m *AzureManager) CreateCluster(ctx context.Context, name, clusterType string) (*ClusterInfo, error) {
 	switch clusterType {
 	case "aks":
 		return m.createAKSCluster(name)
@@ -54,23 +93,26 @@ func (m *AzureManager) CreateCluster(name, clusterType string) (*ClusterInfo, er
 }
 
 func (m *AzureManager) createAKSCluster(name string) (*ClusterInfo, error) {
-	fmt.Println("Creating AKS cluster (this will take 10-15 minutes)...")
+	slog.Info("Creating AKS cluster (this will take 10-15 minutes)...")
 
 	resourceGroupName := fmt.Sprintf("rg-%s", name)
+	tags := NewTagBuilder(name, "", time.Now(), m.userTags)
 
 	// Note: In a real implementation, you would:
-	// 1. Create a resource group
-	// 2. Create the AKS cluster using the containerservice client
+	// 1. Create a resource group tagged with tags.For("resource-group", nil)
+	// 2. Create the AKS cluster using the containerservice client, tagged
+	//    with tags.For("aks-cluster", nil)
 	// 3. Wait for completion
 	// 4. Generate kubeconfig
 
-	fmt.Printf("AKS cluster '%s' would be created in resource group '%s'\n", name, resourceGroupName)
-	fmt.Printf("Location: %s\n", m.location)
+	slog.Info("AKS cluster would be created", "cluster", name, "resourceGroup", resourceGroupName)
+	slog.Info("Location", "location", m.location)
+	slog.Info("Tags", "tags", tags.For("aks-cluster", nil))
 
 	kubeconfigPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s-kubeconfig", name))
 
-	fmt.Printf("Generate kubeconfig with: az aks get-credentials --resource-group %s --name %s --file %s\n",
-		resourceGroupName, name, kubeconfigPath)
+	slog.Info(fmt.Sprintf("Generate kubeconfig with: az aks get-credentials --resource-group %s --name %s --file %s",
+		resourceGroupName, name, kubeconfigPath))
 
 	return &ClusterInfo{
 		Name:           name,
@@ -83,15 +125,19 @@ func (m *AzureManager) createAKSCluster(name string) (*ClusterInfo, error) {
 }
 
 func (m *AzureManager) createSingleNodeCluster(name string) (*ClusterInfo, error) {
-	fmt.Println("Creating single-node cluster on Azure VM...")
+	slog.Info("Creating single-node cluster on Azure VM...")
+
+	tags := NewTagBuilder(name, "", time.Now(), m.userTags)
 
 	// Note: In a real implementation, you would:
-	// 1. Create a VM with cloud-init to install k3s
+	// 1. Create a VM with cloud-init to install k3s, tagging the VM, disk
+	//    and NIC with tags.For("vm", nil)
 	// 2. Wait for VM to be ready
 	// 3. Retrieve kubeconfig
 
-	fmt.Printf("Single-node cluster '%s' would be created on Azure VM\n", name)
-	fmt.Printf("Location: %s\n", m.location)
+	slog.Info("Single-node cluster would be created on Azure VM", "cluster", name)
+	slog.Info("Location", "location", m.location)
+	slog.Info("Tags", "tags", tags.For("vm", nil))
 
 	return &ClusterInfo{
 		Name:           name,
@@ -103,10 +149,70 @@ func (m *AzureManager) createSingleNodeCluster(name string) (*ClusterInfo, error
 	}, nil
 }
 
-func (m *AzureManager) DeleteCluster(name string) error {
+func (m *AzureManager) DeleteCluster(ctx context.Context, name string) error {
 	return fmt.Errorf("delete cluster not implemented yet")
 }
 
-func (m *AzureManager) GetCluster(name string) (*ClusterInfo, error) {
+func (m *AzureManager) StopCluster(ctx context.Context, name string) error {
+	// Note: In a real implementation, you would deallocate the VM
+	// (virtualMachinesClient.BeginDeallocate) so compute is no longer billed
+	return fmt.Errorf("stop cluster not implemented yet")
+}
+
+func (m *AzureManager) StartCluster(ctx context.Context, name string) (*StartResult, error) {
+	return nil, fmt.Errorf("start cluster not implemented yet")
+}
+
+func (m *AzureManager) ListExpiredClusters(ctx context.Context, now time.Time) ([]ExpiredCluster, error) {
+	return nil, fmt.Errorf("reap not implemented yet")
+}
+
+func (m *AzureManager) GetCluster(ctx context.Context, name string) (*ClusterInfo, error) {
 	return nil, fmt.Errorf("get cluster not implemented yet")
-}
\ No newline at end of file
+}
+
+// Diagnose checks that the Azure credentials can get a token for the
+// resource manager. Quota and permission checks are not implemented yet.
+func (m *AzureManager) Diagnose(ctx context.Context, clusterType string) []CheckResult {
+	_, err := m.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{"https://management.azure.com/.default"},
+	})
+	if err != nil {
+		return []CheckResult{failed("azure credentials", "%v", err)}
+	}
+	return []CheckResult{
+		passed("azure credentials", "subscription %s", m.subscriptionID),
+		warned("azure quotas", "not checked for %s in %s", clusterType, m.location),
+	}
+}
+
+func (m *AzureManager) PlanUpgrade(ctx context.Context, name, version string) (*UpgradePlan, error) {
+	// Note: In a real implementation, you would read the current version and
+	// the versions AKS offers from managedClustersClient.GetUpgradeProfile,
+	// check them with checkUpgradeSkew and plan the control plane followed by
+	// each agent pool
+	return nil, fmt.Errorf("upgrade cluster not implemented yet")
+}
+
+func (m *AzureManager) Upgrade(ctx context.Context, plan *UpgradePlan) error {
+	// Note: In a real implementation, you would set
+	// Properties.KubernetesVersion and call
+	// managedClustersClient.BeginCreateOrUpdate for the control plane, then
+	// agentPoolsClient.BeginCreateOrUpdate with OrchestratorVersion per pool
+	return fmt.Errorf("upgrade cluster not implemented yet")
+}
+
+func (m *AzureManager) CreateNodePool(ctx context.Context, cluster string, pool NodePool) error {
+	// Note: In a real implementation, you would call
+	// agentPoolsClient.BeginCreateOrUpdate in resource group rg-<cluster>
+	// with Count, VMSize and, for spot pools, ScaleSetPriority set
+	return fmt.Errorf("create node pool not implemented yet")
+}
+
+func (m *AzureManager) ScaleNodePool(ctx context.Context, cluster, pool string, count int32) error {
+	// Note: In a real implementation, you would read the agent pool with
+	// agentPoolsClient.Get (the only pool when pool is empty), set
+	// Properties.Count and call agentPoolsClient.BeginCreateOrUpdate, then
+	// poll the returned poller until the pool has scaled
+	return fmt.Errorf("scale node pool not implemented yet")
+}
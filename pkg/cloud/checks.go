@@ -0,0 +1,118 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+)
+
+// CheckStatus is the outcome of a doctor or preflight check.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// CheckResult is one row of the doctor table.
+type CheckResult struct {
+	Name   string      `json:"name" yaml:"name"`
+	Status CheckStatus `json:"status" yaml:"status"`
+	Detail string      `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// Doctor is implemented by managers that can check the account they act
+// in: permissions, quotas and availability for the given cluster type.
+type Doctor interface {
+	Diagnose(ctx context.Context, clusterType string) []CheckResult
+}
+
+// Preflighter is implemented by managers that check quotas and permissions
+// before create touches anything. Preflight returns an error naming every
+// failed check; checks that could not run are logged as warnings.
+type Preflighter interface {
+	Preflight(ctx context.Context, clusterType string) error
+}
+
+func passed(name, format string, args ...interface{}) CheckResult {
+	return CheckResult{Name: name, Status: CheckPass, Detail: fmt.Sprintf(format, args...)}
+}
+
+func warned(name, format string, args ...interface{}) CheckResult {
+	return CheckResult{Name: name, Status: CheckWarn, Detail: fmt.Sprintf(format, args...)}
+}
+
+func failed(name, format string, args ...interface{}) CheckResult {
+	return CheckResult{Name: name, Status: CheckFail, Detail: fmt.Sprintf(format, args...)}
+}
+
+// CheckConfig validates the settings that are otherwise only checked deep
+// inside create: network ranges, EKS and k3s settings, GitOps and tags.
+func CheckConfig(conf *config.Config) []CheckResult {
+	var results []CheckResult
+	check := func(name string, err error, detail string) {
+		if err != nil {
+			results = append(results, failed(name, "%v", err))
+			return
+		}
+		results = append(results, passed(name, "%s", detail))
+	}
+
+	switch conf.Provider {
+	case "", "aws", "azure", "local":
+		results = append(results, passed("config provider", "%s", firstNonEmpty(conf.Provider, "not set")))
+	default:
+		results = append(results, failed("config provider", "unsupported provider %q (use aws, azure or local)", conf.Provider))
+	}
+
+	network, err := loadVPCNetwork(conf.Network.VPCCIDR)
+	check("config network", err, "VPC "+network.cidr)
+
+	eks, err := loadEKSConfig(conf.Cloud.AWS.EKS)
+	check("config eks", err, fmt.Sprintf("version %s, %d node pool(s)", eks.Version, len(eks.NodePools)))
+
+	_, err = k3sServerArgs(loadK3sConfig(conf.K3s))
+	check("config k3s", err, "version "+firstNonEmpty(conf.K3s.Version, "latest stable"))
+
+	if conf.GitOps.URL == "" {
+		results = append(results, passed("config gitops", "no repository configured"))
+	} else {
+		check("config gitops", ValidateGitOps(conf.GitOps), conf.GitOps.URL)
+	}
+
+	tags, err := loadUserTags(conf.Tags)
+	check("config tags", err, fmt.Sprintf("%d user tag(s)", len(tags)))
+
+	for _, name := range conf.ContextNames() {
+		switch cloud := conf.Contexts[name].Cloud; cloud {
+		case "", "aws", "azure", "local":
+		default:
+			results = append(results, failed("config contexts", "context %s: unsupported cloud %q", name, cloud))
+		}
+	}
+
+	return results
+}
+
+// preflightError logs warnings and returns an error listing the failed
+// checks, or nil when none failed. class marks the error, e.g. as a quota
+// failure.
+func preflightError(results []CheckResult, class error) error {
+	var failures []string
+	for _, result := range results {
+		switch result.Status {
+		case CheckWarn:
+			slog.Warn("⚠️  Preflight: "+result.Name, "detail", result.Detail)
+		case CheckFail:
+			failures = append(failures, fmt.Sprintf("%s: %s", result.Name, result.Detail))
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return withClass(fmt.Errorf("preflight failed:\n  %s", strings.Join(failures, "\n  ")), class)
+}
@@ -0,0 +1,44 @@
+package cloud
+
+import "errors"
+
+// Classes of failure callers can branch on with errors.Is. Cloud API errors
+// are matched to them by error code; the original error stays in the chain
+// and keeps its message.
+var (
+	// ErrCredentials means the cloud credentials are missing, invalid or
+	// expired
+	ErrCredentials = errors.New("cloud credentials missing or invalid")
+	// ErrQuotaExceeded means an account limit or the available capacity
+	// was reached
+	ErrQuotaExceeded = errors.New("cloud quota exceeded")
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrPartialTeardown means a teardown deleted some resources but left
+	// others behind
+	ErrPartialTeardown = errors.New("teardown incomplete")
+)
+
+// classifiedError adds a failure class to an error without changing its
+// message.
+type classifiedError struct {
+	err   error
+	class error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// withClass marks err as belonging to class. Nil errors and errors already
+// in the class are returned unchanged.
+func withClass(err, class error) error {
+	if err == nil || class == nil || errors.Is(err, class) {
+		return err
+	}
+	return &classifiedError{err: err, class: class}
+}
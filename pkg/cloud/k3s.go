@@ -0,0 +1,179 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/viper"
+)
+
+var (
+	k3sVersionPattern = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+(-rc[0-9]+)?\+k3s[0-9]+$`)
+	k3sChannelPattern = regexp.MustCompile(`^v?1\.[0-9]+$`)
+	hostnamePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9.]*[A-Za-z0-9])?$`)
+)
+
+// Components that can be skipped with k3s --disable
+var k3sDisableableComponents = map[string]bool{
+	"coredns":        true,
+	"servicelb":      true,
+	"traefik":        true,
+	"local-storage":  true,
+	"metrics-server": true,
+}
+
+// loadK3sConfig resolves k3s settings. Command line flags take precedence
+// over the k3s section of the config file.
+func loadK3sConfig(conf config.K3sConfig) config.K3sConfig {
+	k3s := config.K3sConfig{
+		Version:     firstNonEmpty(viper.GetString("k3s-version"), conf.Version),
+		Disable:     firstNonEmptySlice(viper.GetStringSlice("k3s-disable"), conf.Disable),
+		ClusterCIDR: firstNonEmpty(viper.GetString("cluster-cidr"), conf.ClusterCIDR),
+		ServiceCIDR: firstNonEmpty(viper.GetString("service-cidr"), conf.ServiceCIDR),
+		TLSSANs:     firstNonEmptySlice(viper.GetStringSlice("tls-san"), conf.TLSSANs),
+		Datastore:   firstNonEmpty(viper.GetString("k3s-datastore"), conf.Datastore),
+		Servers:     conf.Servers,
+		Agents:      conf.Agents,
+	}
+	if servers := viper.GetInt32("servers"); servers > 0 {
+		k3s.Servers = servers
+	}
+	// Zero agents is a valid choice, so only a flag that was given wins
+	if viper.IsSet("agents") {
+		k3s.Agents = viper.GetInt32("agents")
+	}
+	if k3s.Servers == 0 {
+		k3s.Servers = 1
+	}
+	return k3s
+}
+
+// loadRegistryMirrors merges --registry-mirror host=endpoint flags into the
+// mirrors from the bootstrap section of the config file.
+func loadRegistryMirrors(configured map[string][]string) (map[string][]string, error) {
+	mirrors := make(map[string][]string, len(configured))
+	for host, endpoints := range configured {
+		mirrors[host] = append([]string(nil), endpoints...)
+	}
+
+	for _, entry := range viper.GetStringSlice("registry-mirror") {
+		host, endpoint, ok := strings.Cut(entry, "=")
+		if !ok || host == "" || endpoint == "" {
+			return nil, fmt.Errorf("invalid --registry-mirror %q: expected registry=endpoint", entry)
+		}
+		mirrors[host] = append(mirrors[host], endpoint)
+	}
+
+	return mirrors, nil
+}
+
+// k3sServerArgs validates the k3s settings and converts them into arguments
+// for the k3s install script.
+func k3sServerArgs(k3s config.K3sConfig) ([]string, error) {
+	if k3s.Version != "" && !k3sVersionPattern.MatchString(k3s.Version) {
+		return nil, fmt.Errorf("invalid k3s version %q: expected a release such as v1.29.4+k3s1", k3s.Version)
+	}
+
+	args := []string{"--write-kubeconfig-mode", "644"}
+
+	for _, component := range k3s.Disable {
+		if !k3sDisableableComponents[component] {
+			return nil, fmt.Errorf("unknown k3s component to disable: %q", component)
+		}
+		args = append(args, "--disable", component)
+	}
+
+	for flag, cidr := range map[string]string{"--cluster-cidr": k3s.ClusterCIDR, "--service-cidr": k3s.ServiceCIDR} {
+		if cidr == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", strings.TrimPrefix(flag, "--"), cidr, err)
+		}
+	}
+	if k3s.ClusterCIDR != "" {
+		args = append(args, "--cluster-cidr", k3s.ClusterCIDR)
+	}
+	if k3s.ServiceCIDR != "" {
+		args = append(args, "--service-cidr", k3s.ServiceCIDR)
+	}
+
+	for _, san := range k3s.TLSSANs {
+		if net.ParseIP(san) == nil && !hostnamePattern.MatchString(san) {
+			return nil, fmt.Errorf("invalid TLS SAN %q: expected a hostname or IP address", san)
+		}
+		args = append(args, "--tls-san", san)
+	}
+
+	switch k3s.Datastore {
+	case "", "sqlite":
+	case "etcd":
+		args = append(args, "--cluster-init")
+	default:
+		return nil, fmt.Errorf("unsupported k3s datastore %q: use sqlite or etcd", k3s.Datastore)
+	}
+
+	return args, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonEmptySlice(values ...[]string) []string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return nil
+}
+
+// k3s release channels redirect to the newest release of a minor version
+const k3sChannelURL = "https://update.k3s.io/v1-release/channels/"
+
+// resolveK3sVersion turns an upgrade target into a k3s release: releases
+// such as v1.30.2+k3s1 are used as given, minor versions such as 1.30 are
+// looked up in the k3s release channel of the same name.
+func resolveK3sVersion(ctx context.Context, version string) (string, error) {
+	if k3sVersionPattern.MatchString(version) {
+		return version, nil
+	}
+	if !k3sChannelPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid k3s version %q: expected a minor version such as 1.30 or a release such as v1.30.2+k3s1", version)
+	}
+
+	channel := "v" + strings.TrimPrefix(version, "v")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k3sChannelURL+channel, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up k3s channel %s: %w", channel, err)
+	}
+	resp.Body.Close()
+
+	// The channel redirects to .../releases/tag/<release>
+	release := path.Base(resp.Header.Get("Location"))
+	if !k3sVersionPattern.MatchString(release) {
+		return "", fmt.Errorf("no k3s release found in channel %s (status %s)", channel, resp.Status)
+	}
+	return release, nil
+}
@@ -0,0 +1,109 @@
+package cloud
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // auto-stop time zones must resolve on every platform
+
+	"github.com/spf13/viper"
+)
+
+// Tags recording when an ephemeral cluster should be stopped or removed
+const (
+	expiresAtTag = "xstrapolate-expires-at"
+	autoStopTag  = "xstrapolate-auto-stop"
+)
+
+// LifetimeOptions limits how long a cluster runs unattended.
+type LifetimeOptions struct {
+	// TTL is how long after creation the cluster expires; zero disables it
+	TTL time.Duration
+	// AutoStop is a daily stop time such as "19:00 America/New_York"
+	AutoStop string
+}
+
+// ExpiredCluster is a cluster whose TTL has passed.
+type ExpiredCluster struct {
+	Name      string
+	Type      string
+	ExpiresAt time.Time
+}
+
+// loadLifetimeOptions reads --ttl and --auto-stop and validates them.
+func loadLifetimeOptions() (LifetimeOptions, error) {
+	opts := LifetimeOptions{
+		AutoStop: strings.TrimSpace(viper.GetString("auto-stop")),
+	}
+
+	if ttl := viper.GetString("ttl"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return opts, fmt.Errorf("invalid --ttl %q: expected a duration such as 8h or 90m", ttl)
+		}
+		opts.TTL = d
+	}
+
+	if opts.AutoStop != "" {
+		if _, _, err := parseAutoStop(opts.AutoStop); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// Tags returns the lifetime tags for a cluster created at now.
+func (o LifetimeOptions) Tags(now time.Time) map[string]string {
+	tags := map[string]string{}
+	if o.TTL > 0 {
+		tags[expiresAtTag] = now.Add(o.TTL).UTC().Format(time.RFC3339)
+	}
+	if o.AutoStop != "" {
+		tags[autoStopTag] = o.AutoStop
+	}
+	return tags
+}
+
+// parseAutoStop splits "HH:MM Zone" into a clock time and a location. The
+// zone defaults to UTC.
+func parseAutoStop(spec string) (time.Time, *time.Location, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, nil, fmt.Errorf("invalid --auto-stop %q: expected \"HH:MM [Time/Zone]\"", spec)
+	}
+
+	clock, err := time.Parse("15:04", fields[0])
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid --auto-stop time %q: expected HH:MM", fields[0])
+	}
+
+	loc := time.UTC
+	if len(fields) == 2 {
+		loc, err = time.LoadLocation(fields[1])
+		if err != nil {
+			return time.Time{}, nil, fmt.Errorf("invalid --auto-stop time zone %q: %w", fields[1], err)
+		}
+	}
+
+	return clock, loc, nil
+}
+
+// systemdCalendar converts an auto-stop spec into a daily OnCalendar value.
+func systemdCalendar(spec string) (string, error) {
+	clock, loc, err := parseAutoStop(spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("*-*-* %s:00 %s", clock.Format("15:04"), loc.String()), nil
+}
+
+// parseExpiresAt reads an expiry tag value; ok is false when it is missing
+// or malformed.
+func parseExpiresAt(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
@@ -0,0 +1,601 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Cluster names must be valid as kind and k3d cluster names and as Docker
+// container names
+var localClusterNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// localRuntime is a way of running a cluster in Docker containers and the
+// labels it puts on them.
+type localRuntime struct {
+	name string
+	// binary is the command line tool that creates and deletes the clusters
+	binary       string
+	clusterLabel string
+	roleLabel    string
+	// managed is set when the containers carry the xstrapolate tags as
+	// labels. kind cannot label its containers, so any kind cluster of the
+	// name is taken for xstrapolate's.
+	managed bool
+}
+
+// Runtimes in the order they are picked when none is configured
+var localRuntimes = []localRuntime{
+	{name: "k3d", binary: "k3d", clusterLabel: "k3d.cluster", roleLabel: "k3d.role", managed: true},
+	{name: "kind", binary: "kind", clusterLabel: "io.x-k8s.kind.cluster", roleLabel: "io.x-k8s.kind.role"},
+	{name: "k3s", binary: "docker", clusterLabel: clusterTag, roleLabel: resourceTypeTag, managed: true},
+}
+
+// k3s image of the k3d and k3s runtimes; kind uses its own node image
+const k3sImage = "rancher/k3s"
+
+// LocalManager runs clusters in Docker on this machine with kind, k3d or
+// plain k3s containers, so GitOps repositories can be tried without a cloud
+// account. Create installs Flux the same way as for managed clusters.
+type LocalManager struct {
+	// runtime is empty when the first installed runtime should be used
+	runtime  string
+	k3s      config.K3sConfig
+	userTags map[string]string
+}
+
+// NewLocalManager builds a manager from the loaded config.
+func NewLocalManager(conf *config.Config) (*LocalManager, error) {
+	runtime := firstNonEmpty(viper.GetString("runtime"), conf.Cloud.Local.Runtime)
+	if runtime != "" {
+		if _, err := lookupLocalRuntime(runtime); err != nil {
+			return nil, err
+		}
+	}
+
+	userTags, err := loadUserTags(conf.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalManager{
+		runtime:  runtime,
+		k3s:      loadK3sConfig(conf.K3s),
+		userTags: userTags,
+	}, nil
+}
+
+func lookupLocalRuntime(name string) (localRuntime, error) {
+	for _, rt := range localRuntimes {
+		if rt.name == name {
+			return rt, nil
+		}
+	}
+	return localRuntime{}, fmt.Errorf("unsupported local runtime %q (use kind, k3d or k3s)", name)
+}
+
+// resolveRuntime returns the configured runtime, or the first one whose
+// tool is installed. Every runtime needs Docker.
+func (m *LocalManager) resolveRuntime() (localRuntime, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return localRuntime{}, fmt.Errorf("docker not found in PATH; local clusters run in Docker")
+	}
+
+	if m.runtime != "" {
+		rt, err := lookupLocalRuntime(m.runtime)
+		if err != nil {
+			return localRuntime{}, err
+		}
+		if _, err := exec.LookPath(rt.binary); err != nil {
+			return localRuntime{}, fmt.Errorf("%s not found in PATH; install it or pick another runtime with --runtime", rt.binary)
+		}
+		return rt, nil
+	}
+
+	for _, rt := range localRuntimes {
+		if _, err := exec.LookPath(rt.binary); err == nil {
+			return rt, nil
+		}
+	}
+	return localRuntime{}, fmt.Errorf("no local runtime found")
+}
+
+func (m *LocalManager) CreateCluster(ctx context.Context, name, clusterType string) (*ClusterInfo, error) {
+	if clusterType != "single-node" {
+		return nil, fmt.Errorf("unsupported cluster type for local: %s (use single-node)", clusterType)
+	}
+	if !localClusterNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid cluster name %q: use lowercase letters, digits and dashes", name)
+	}
+
+	rt, err := m.resolveRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, existing, err := m.findCluster(ctx, name); err != nil {
+		return nil, err
+	} else if len(existing) > 0 {
+		return nil, withClass(fmt.Errorf("cluster '%s' already exists", name), ErrAlreadyExists)
+	}
+
+	kubeconfigPath, err := clusterKubeconfigPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve kubeconfig path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(kubeconfigPath), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create kubeconfig directory: %w", err)
+	}
+
+	tags := NewTagBuilder(name, "", time.Now(), m.userTags)
+
+	slog.Info("🐳 Creating local cluster (this will take 1-2 minutes)...", "cluster", name, "runtime", rt.name)
+	var kubeconfig string
+	switch rt.name {
+	case "kind":
+		kubeconfig, err = m.createKindCluster(ctx, name, kubeconfigPath)
+	case "k3d":
+		kubeconfig, err = m.createK3dCluster(ctx, name, tags)
+	case "k3s":
+		kubeconfig, err = m.createK3sContainer(ctx, name, tags)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if kubeconfig != "" {
+		if err := os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write kubeconfig: %w", err)
+		}
+	}
+	slog.Info("✅ Local cluster is ready", "cluster", name, "kubeconfig", kubeconfigPath)
+
+	return m.GetCluster(ctx, name)
+}
+
+// createKindCluster creates a kind cluster, which writes its own kubeconfig
+// and waits for the control plane.
+func (m *LocalManager) createKindCluster(ctx context.Context, name, kubeconfigPath string) (string, error) {
+	if m.k3s.Version != "" || len(m.k3s.Disable) > 0 || m.k3s.Datastore != "" {
+		slog.Warn("k3s settings do not apply to kind clusters; use --runtime k3d or k3s")
+	}
+
+	_, err := runLocal(ctx, "kind", "create", "cluster",
+		"--name", name,
+		"--kubeconfig", kubeconfigPath,
+		"--wait", waitTimeout(ctx, 5*time.Minute).String())
+	if err != nil {
+		return "", fmt.Errorf("failed to create kind cluster: %w", err)
+	}
+	return "", nil
+}
+
+// createK3dCluster creates a k3d cluster with the k3s settings and returns
+// its kubeconfig. The default kubeconfig is left alone.
+func (m *LocalManager) createK3dCluster(ctx context.Context, name string, tags TagBuilder) (string, error) {
+	k3sArgs, err := k3sServerArgs(m.k3s)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"cluster", "create", name,
+		"--wait", "--timeout", waitTimeout(ctx, 5*time.Minute).String(),
+		"--kubeconfig-update-default=false",
+		"--kubeconfig-switch-context=false",
+	}
+	if m.k3s.Version != "" {
+		args = append(args, "--image", k3sImageRef(m.k3s.Version))
+	}
+	for _, arg := range joinFlagValues(k3sArgs) {
+		args = append(args, "--k3s-arg", arg+"@server:*")
+	}
+	labels := tags.For("", nil)
+	for _, key := range sortedKeys(labels) {
+		args = append(args, "--runtime-label", key+"="+labels[key]+"@server:*;agent:*;loadbalancer")
+	}
+
+	if _, err := runLocal(ctx, "k3d", args...); err != nil {
+		return "", fmt.Errorf("failed to create k3d cluster: %w", err)
+	}
+
+	kubeconfig, err := runLocal(ctx, "k3d", "kubeconfig", "get", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	return kubeconfig, nil
+}
+
+// createK3sContainer runs the k3s server in a single privileged container
+// with the API published on a random port of 127.0.0.1, and returns a
+// kubeconfig pointing at that port.
+func (m *LocalManager) createK3sContainer(ctx context.Context, name string, tags TagBuilder) (string, error) {
+	k3sArgs, err := k3sServerArgs(m.k3s)
+	if err != nil {
+		return "", err
+	}
+
+	container := localK3sContainer(name)
+	args := []string{"run", "--detach",
+		"--name", container,
+		"--hostname", name,
+		"--privileged",
+		"--tmpfs", "/run",
+		"--tmpfs", "/var/run",
+		"--publish", fmt.Sprintf("127.0.0.1::%d", k3sAPIPort),
+	}
+	labels := tags.For("server", nil)
+	for _, key := range sortedKeys(labels) {
+		args = append(args, "--label", key+"="+labels[key])
+	}
+	args = append(args, k3sImageRef(m.k3s.Version), "server")
+	args = append(args, k3sArgs...)
+
+	if _, err := runLocal(ctx, "docker", args...); err != nil {
+		return "", fmt.Errorf("failed to start k3s container: %w", err)
+	}
+
+	slog.Info("⏳ Waiting for k3s to become healthy...")
+	if err := waitForLocalK3s(ctx, container, waitTimeout(ctx, 5*time.Minute)); err != nil {
+		return "", err
+	}
+
+	address, err := runLocal(ctx, "docker", "port", container, fmt.Sprintf("%d/tcp", k3sAPIPort))
+	if err != nil {
+		return "", fmt.Errorf("failed to read API port: %w", err)
+	}
+	address, _, _ = strings.Cut(strings.TrimSpace(address), "\n")
+
+	kubeconfig, err := runLocal(ctx, "docker", "exec", container, "cat", k3sKubeconfigPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	return strings.ReplaceAll(kubeconfig, fmt.Sprintf("https://127.0.0.1:%d", k3sAPIPort), "https://"+address), nil
+}
+
+// waitForLocalK3s waits until the node of a k3s container has registered
+// and is ready. The API server needs a moment after the container starts,
+// so failures are retried.
+func waitForLocalK3s(ctx context.Context, container string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	var lastErr error
+	for time.Now().Before(deadline) {
+		_, lastErr = runLocal(ctx, "docker", "exec", container,
+			"kubectl", "wait", "--for=condition=Ready", "nodes", "--all", "--timeout=60s")
+		if lastErr == nil {
+			return nil
+		}
+		if err := sleepContext(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("k3s did not become healthy within %s: %w", timeout, lastErr)
+}
+
+func (m *LocalManager) DeleteCluster(ctx context.Context, name string) error {
+	rt, containers, err := m.findCluster(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return withClass(fmt.Errorf("local cluster '%s' not found", name), ErrNotFound)
+	}
+
+	kubeconfigPath, err := clusterKubeconfigPath(name)
+	if err != nil {
+		return fmt.Errorf("failed to resolve kubeconfig path: %w", err)
+	}
+
+	slog.Info("🗑️  Deleting local cluster", "cluster", name, "runtime", rt.name)
+	switch rt.name {
+	case "kind":
+		_, err = runLocal(ctx, "kind", "delete", "cluster", "--name", name, "--kubeconfig", kubeconfigPath)
+	case "k3d":
+		_, err = runLocal(ctx, "k3d", "cluster", "delete", name)
+	case "k3s":
+		args := []string{"rm", "--force", "--volumes"}
+		for _, container := range containers {
+			args = append(args, container.name)
+		}
+		_, err = runLocal(ctx, "docker", args...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s cluster: %w", rt.name, err)
+	}
+
+	if err := os.Remove(kubeconfigPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to remove kubeconfig", "path", kubeconfigPath, "err", err)
+	}
+	return nil
+}
+
+func (m *LocalManager) GetCluster(ctx context.Context, name string) (*ClusterInfo, error) {
+	rt, containers, err := m.findCluster(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, withClass(fmt.Errorf("local cluster '%s' not found", name), ErrNotFound)
+	}
+
+	info := localClusterInfo(name, rt, containers)
+	if path, err := clusterKubeconfigPath(name); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			info.KubeconfigPath = path
+			info.Endpoint = kubeconfigServer(path)
+		}
+	}
+	return info, nil
+}
+
+// ListClusters returns the local clusters of every runtime, sorted by name.
+// Resources, kubeconfig and endpoint are not filled in.
+func (m *LocalManager) ListClusters(ctx context.Context) ([]ClusterInfo, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, fmt.Errorf("docker not found in PATH; local clusters run in Docker")
+	}
+
+	var list []ClusterInfo
+	for _, rt := range localRuntimes {
+		containers, err := listLocalContainers(ctx, rt, "")
+		if err != nil {
+			return nil, err
+		}
+
+		clusters := map[string][]localContainer{}
+		for _, container := range containers {
+			clusters[container.cluster] = append(clusters[container.cluster], container)
+		}
+		for name, clusterContainers := range clusters {
+			info := localClusterInfo(name, rt, clusterContainers)
+			info.Resources = nil
+			list = append(list, *info)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Diagnose checks that Docker is running and a runtime is installed.
+func (m *LocalManager) Diagnose(ctx context.Context, clusterType string) []CheckResult {
+	var results []CheckResult
+
+	rt, err := m.resolveRuntime()
+	if err != nil {
+		return []CheckResult{failed("local runtime", "%v", err)}
+	}
+	results = append(results, passed("local runtime", "%s", rt.name))
+
+	version, err := runLocal(ctx, "docker", "version", "--format", "{{.Server.Version}}")
+	if err != nil {
+		detail, _, _ := strings.Cut(err.Error(), "\n")
+		results = append(results, failed("docker daemon", "%s", detail))
+	} else {
+		results = append(results, passed("docker daemon", "version %s", strings.TrimSpace(version)))
+	}
+
+	if clusterType != "single-node" {
+		results = append(results, failed("local cluster type", "%s is not supported locally (use single-node)", clusterType))
+	}
+	return results
+}
+
+// localContainer is a node, or k3d load balancer, of a local cluster.
+type localContainer struct {
+	name    string
+	state   string
+	image   string
+	cluster string
+	role    string
+}
+
+// findCluster looks for the containers of a cluster in every runtime and
+// returns the first runtime that has any.
+func (m *LocalManager) findCluster(ctx context.Context, name string) (localRuntime, []localContainer, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return localRuntime{}, nil, fmt.Errorf("docker not found in PATH; local clusters run in Docker")
+	}
+
+	for _, rt := range localRuntimes {
+		containers, err := listLocalContainers(ctx, rt, name)
+		if err != nil {
+			return localRuntime{}, nil, err
+		}
+		if len(containers) > 0 {
+			return rt, containers, nil
+		}
+	}
+	return localRuntime{}, nil, nil
+}
+
+// listLocalContainers lists the containers of a runtime's clusters, or of
+// one cluster when name is set, stopped ones included.
+func listLocalContainers(ctx context.Context, rt localRuntime, name string) ([]localContainer, error) {
+	clusterFilter := "label=" + rt.clusterLabel
+	if name != "" {
+		clusterFilter += "=" + name
+	}
+	// Every runtime labels the role of its containers; k3d containers also
+	// carry the xstrapolate tags but no resource type, which keeps them out
+	// of the k3s runtime's list
+	args := []string{"ps", "--all", "--filter", clusterFilter, "--filter", "label=" + rt.roleLabel}
+	if rt.managed {
+		args = append(args, "--filter", "label="+managedTag+"=true")
+	}
+	format := fmt.Sprintf(`{{.Names}}\t{{.State}}\t{{.Image}}\t{{.Label %q}}\t{{.Label %q}}`, rt.clusterLabel, rt.roleLabel)
+	args = append(args, "--format", format)
+
+	output, err := runLocal(ctx, "docker", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s containers: %w", rt.name, err)
+	}
+
+	var containers []localContainer
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		containers = append(containers, localContainer{
+			name:    fields[0],
+			state:   fields[1],
+			image:   fields[2],
+			cluster: fields[3],
+			role:    fields[4],
+		})
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].name < containers[j].name
+	})
+	return containers, nil
+}
+
+// localClusterInfo describes a local cluster from its containers. The
+// status is the servers': running when all of them run, stopped when one
+// has exited.
+func localClusterInfo(name string, rt localRuntime, containers []localContainer) *ClusterInfo {
+	info := &ClusterInfo{
+		Name:      name,
+		Type:      "single-node",
+		Provider:  "local",
+		Status:    "running",
+		Resources: map[string][]string{},
+	}
+
+	var servers, agents []localContainer
+	for _, container := range containers {
+		kind := rt.name + "-" + firstNonEmpty(container.role, "node")
+		info.Resources[kind] = append(info.Resources[kind], container.name)
+
+		switch container.role {
+		case "server", "control-plane":
+			servers = append(servers, container)
+		case "agent", "worker":
+			agents = append(agents, container)
+		}
+	}
+
+	for _, server := range servers {
+		if server.state == "running" {
+			continue
+		}
+		if server.state == "exited" {
+			info.Status = "stopped"
+			break
+		}
+		info.Status = server.state
+	}
+
+	if len(servers) > 0 {
+		info.Version = imageVersion(servers[0].image)
+		info.NodePools = append(info.NodePools, NodePool{
+			Name:         "servers",
+			InstanceType: servers[0].image,
+			Count:        int32(len(servers)),
+		})
+	}
+	if len(agents) > 0 {
+		info.NodePools = append(info.NodePools, NodePool{
+			Name:         k3sAgentPoolName,
+			InstanceType: agents[0].image,
+			Count:        int32(len(agents)),
+		})
+	}
+	return info
+}
+
+// localK3sContainer is the container name of a cluster of the k3s runtime.
+func localK3sContainer(cluster string) string {
+	return "xstrapolate-" + cluster
+}
+
+// k3sImageRef returns the rancher/k3s image of a k3s release. Image tags
+// cannot hold a +, so v1.29.4+k3s1 is tagged v1.29.4-k3s1.
+func k3sImageRef(version string) string {
+	if version == "" {
+		return k3sImage + ":latest"
+	}
+	return k3sImage + ":" + strings.ReplaceAll(version, "+", "-")
+}
+
+// imageVersion returns the Kubernetes version in a node image tag, such as
+// kindest/node:v1.29.2 or rancher/k3s:v1.29.4-k3s1, or "" when the tag does
+// not name one.
+func imageVersion(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	tag := image[i+1:]
+	if !strings.HasPrefix(tag, "v1.") {
+		return ""
+	}
+	return strings.Replace(tag, "-k3s", "+k3s", 1)
+}
+
+// joinFlagValues turns arguments such as "--disable traefik" into
+// "--disable=traefik", the form k3d passes on to k3s.
+func joinFlagValues(args []string) []string {
+	var joined []string
+	for i := 0; i < len(args); i++ {
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			joined = append(joined, args[i]+"="+args[i+1])
+			i++
+			continue
+		}
+		joined = append(joined, args[i])
+	}
+	return joined
+}
+
+// kubeconfigServer returns the API server address of the first cluster in
+// a kubeconfig, or "" when it cannot be read.
+func kubeconfigServer(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var kubeconfig struct {
+		Clusters []struct {
+			Cluster struct {
+				Server string `yaml:"server"`
+			} `yaml:"cluster"`
+		} `yaml:"clusters"`
+	}
+	if err := yaml.Unmarshal(data, &kubeconfig); err != nil || len(kubeconfig.Clusters) == 0 {
+		return ""
+	}
+	return kubeconfig.Clusters[0].Cluster.Server
+}
+
+// runLocal runs a command line tool and returns its standard output.
+func runLocal(ctx context.Context, name string, args ...string) (string, error) {
+	execCmd := exec.CommandContext(ctx, name, args...)
+	slog.Debug("Running", "command", execCmd.String())
+
+	var stderr bytes.Buffer
+	execCmd.Stderr = &stderr
+	output, err := execCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s %s: %w\nOutput: %s", name, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
@@ -0,0 +1,37 @@
+package cloud
+
+import (
+	"fmt"
+	"net"
+)
+
+// Address range of VPCs when network.vpc_cidr is not set
+const defaultVPCCIDR = "10.0.0.0/16"
+
+// vpcNetwork is the /16 block that xstrapolate VPCs and their /24 subnets
+// are carved from.
+type vpcNetwork struct {
+	cidr   string
+	prefix net.IP
+}
+
+// loadVPCNetwork validates the configured VPC CIDR. Subnets are numbered
+// by their third octet, so only /16 blocks are accepted.
+func loadVPCNetwork(cidr string) (vpcNetwork, error) {
+	cidr = firstNonEmpty(cidr, defaultVPCCIDR)
+
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return vpcNetwork{}, fmt.Errorf("invalid network.vpc_cidr %q: must be an IPv4 CIDR such as %s", cidr, defaultVPCCIDR)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones != 16 {
+		return vpcNetwork{}, fmt.Errorf("invalid network.vpc_cidr %q: must be a /16 block", cidr)
+	}
+
+	return vpcNetwork{cidr: ipNet.String(), prefix: ipNet.IP.To4()}, nil
+}
+
+// subnet returns the /24 subnet of the VPC whose third octet is index.
+func (n vpcNetwork) subnet(index int) string {
+	return fmt.Sprintf("%d.%d.%d.0/24", n.prefix[0], n.prefix[1], index)
+}
@@ -0,0 +1,132 @@
+package cloud
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hoursPerMonth is the average month length AWS uses for monthly estimates
+const hoursPerMonth = 730
+
+// Approximate on-demand prices for us-east-1 with per-region multipliers.
+// The table is bundled so estimates work offline; refresh it from the AWS
+// price list when prices change.
+//
+//go:embed data/aws-prices.json
+var awsPriceData []byte
+
+type awsPriceTable struct {
+	Currency          string             `json:"currency"`
+	Effective         string             `json:"effective"`
+	BaseRegion        string             `json:"base_region"`
+	RegionMultipliers map[string]float64 `json:"region_multipliers"`
+	Hourly            struct {
+		EKSControlPlane        float64 `json:"eks_control_plane"`
+		InterfaceEndpointPerAZ float64 `json:"interface_endpoint_per_az"`
+		NATGateway             float64 `json:"nat_gateway"`
+		NetworkLoadBalancer    float64 `json:"network_load_balancer"`
+		PublicIPv4             float64 `json:"public_ipv4"`
+		KMSKey                 float64 `json:"kms_key"`
+	} `json:"hourly"`
+	EBSGBMonth map[string]float64 `json:"ebs_gb_month"`
+	Instances  map[string]float64 `json:"instances"`
+}
+
+var awsPrices = mustLoadPriceTable(awsPriceData)
+
+func mustLoadPriceTable(data []byte) *awsPriceTable {
+	var table awsPriceTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		panic(fmt.Sprintf("invalid bundled price table: %v", err))
+	}
+	return &table
+}
+
+// regionMultiplier returns the price factor of a region relative to the base
+// region. Unknown regions are priced like the base region.
+func (t *awsPriceTable) regionMultiplier(region string) float64 {
+	if multiplier, ok := t.RegionMultipliers[region]; ok {
+		return multiplier
+	}
+	return 1.0
+}
+
+// instanceHourlyPrice returns the approximate on-demand price of an instance
+// type in a region and whether the type is in the price table.
+func instanceHourlyPrice(region, instanceType string) (float64, bool) {
+	price, ok := awsPrices.Instances[instanceType]
+	return price * awsPrices.regionMultiplier(region), ok
+}
+
+// CostItem is one line of a cost estimate.
+type CostItem struct {
+	Description string
+	Quantity    float64
+	// UnitHourly is the price of one unit per hour, in USD
+	UnitHourly float64
+}
+
+func (i CostItem) Hourly() float64 {
+	return i.Quantity * i.UnitHourly
+}
+
+// CostEstimate is the approximate running cost of a cluster.
+type CostEstimate struct {
+	Region string
+	Items  []CostItem
+	// Notes explain assumptions, such as unknown prices or spot discounts
+	Notes []string
+}
+
+func (e *CostEstimate) HourlyTotal() float64 {
+	var total float64
+	for _, item := range e.Items {
+		total += item.Hourly()
+	}
+	return total
+}
+
+func (e *CostEstimate) MonthlyTotal() float64 {
+	return e.HourlyTotal() * hoursPerMonth
+}
+
+// String renders the estimate as a table.
+func (e *CostEstimate) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Estimated cost in %s (on-demand, bundled prices from %s):\n", e.Region, awsPrices.Effective)
+	for _, item := range e.Items {
+		fmt.Fprintf(&b, "  %-45s %4g × $%.4f/h = $%8.2f/month\n",
+			item.Description, item.Quantity, item.UnitHourly, item.Hourly()*hoursPerMonth)
+	}
+	fmt.Fprintf(&b, "  %-45s $%.4f/h ≈ $%.2f/month\n", "Total", e.HourlyTotal(), e.MonthlyTotal())
+	for _, note := range e.Notes {
+		fmt.Fprintf(&b, "  Note: %s\n", note)
+	}
+	return b.String()
+}
+
+// CostReport is the actual spend of a cluster as reported by the cloud's
+// billing API.
+type CostReport struct {
+	Name      string
+	Start     time.Time
+	End       time.Time
+	ByService map[string]float64
+	Total     float64
+}
+
+// Services returns the report's services ordered by spend, highest first.
+func (r *CostReport) Services() []string {
+	services := make([]string, 0, len(r.ByService))
+	for service := range r.ByService {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return r.ByService[services[i]] > r.ByService[services[j]]
+	})
+	return services
+}
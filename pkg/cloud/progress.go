@@ -0,0 +1,79 @@
+package cloud
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Markers emitted by the single-node bootstrap script so the CLI can follow
+// provisioning without parsing free-form log output.
+const (
+	BootstrapProgressMarker = "XSTRAP-PROGRESS"
+	BootstrapFailedMarker   = "XSTRAP-FAILED"
+	BootstrapDoneMarker     = "XSTRAP-DONE"
+)
+
+type BootstrapEventKind string
+
+const (
+	BootstrapEventProgress BootstrapEventKind = "progress"
+	BootstrapEventFailed   BootstrapEventKind = "failed"
+	BootstrapEventDone     BootstrapEventKind = "done"
+)
+
+// BootstrapEvent is a parsed progress marker from the bootstrap log.
+type BootstrapEvent struct {
+	Kind     BootstrapEventKind
+	Step     int
+	Total    int
+	Name     string
+	ExitCode int
+	Line     int
+}
+
+// ParseBootstrapMarker parses a single log line. It returns false when the
+// line is ordinary log output rather than a marker.
+func ParseBootstrapMarker(line string) (BootstrapEvent, bool) {
+	line = strings.TrimSpace(line)
+
+	var kind BootstrapEventKind
+	var rest string
+	switch {
+	case strings.HasPrefix(line, BootstrapProgressMarker):
+		kind = BootstrapEventProgress
+		rest = strings.TrimPrefix(line, BootstrapProgressMarker)
+	case strings.HasPrefix(line, BootstrapFailedMarker):
+		kind = BootstrapEventFailed
+		rest = strings.TrimPrefix(line, BootstrapFailedMarker)
+	case line == BootstrapDoneMarker:
+		return BootstrapEvent{Kind: BootstrapEventDone}, true
+	default:
+		return BootstrapEvent{}, false
+	}
+
+	event := BootstrapEvent{Kind: kind}
+	for _, field := range strings.Fields(rest) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "step":
+			if kind == BootstrapEventFailed {
+				event.Name = value
+				continue
+			}
+			current, total, _ := strings.Cut(value, "/")
+			event.Step, _ = strconv.Atoi(current)
+			event.Total, _ = strconv.Atoi(total)
+		case "name":
+			event.Name = value
+		case "exit":
+			event.ExitCode, _ = strconv.Atoi(value)
+		case "line":
+			event.Line, _ = strconv.Atoi(value)
+		}
+	}
+
+	return event, true
+}
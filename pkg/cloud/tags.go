@@ -0,0 +1,117 @@
+package cloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Tags applied to every resource xstrapolate creates
+const (
+	managedTag      = "xstrapolate-managed"
+	clusterTag      = "xstrapolate-cluster"
+	resourceTypeTag = "xstrapolate-resource-type"
+	createdByTag    = "xstrapolate-created-by"
+	createdAtTag    = "xstrapolate-created-at"
+)
+
+// TagBuilder produces the common tag set for one create call. Every create
+// call in pkg/cloud should take its tags from here so resources stay
+// discoverable for teardown, garbage collection and cost reports.
+type TagBuilder struct {
+	base map[string]string
+}
+
+// NewTagBuilder returns a builder for a cluster. User tags are applied
+// first so they can never override the xstrapolate-* tags. An empty cluster
+// name is used for resources shared between clusters, such as IAM roles.
+func NewTagBuilder(cluster, creator string, createdAt time.Time, userTags map[string]string) TagBuilder {
+	base := make(map[string]string, len(userTags)+5)
+	for key, value := range userTags {
+		base[key] = value
+	}
+
+	base[managedTag] = "true"
+	base[createdAtTag] = createdAt.UTC().Format(time.RFC3339)
+	if cluster != "" {
+		base[clusterTag] = cluster
+	}
+	if creator != "" {
+		base[createdByTag] = creator
+	}
+
+	return TagBuilder{base: base}
+}
+
+// Shared returns a builder for resources used by several clusters. It keeps
+// every tag except the cluster name.
+func (b TagBuilder) Shared() TagBuilder {
+	shared := make(map[string]string, len(b.base))
+	for key, value := range b.base {
+		if key != clusterTag {
+			shared[key] = value
+		}
+	}
+	return TagBuilder{base: shared}
+}
+
+// With returns a builder with additional tags, such as lifetime tags.
+func (b TagBuilder) With(extra map[string]string) TagBuilder {
+	return TagBuilder{base: b.For("", extra)}
+}
+
+// For returns the tags of one resource. resourceType sets
+// xstrapolate-resource-type when not empty; extra tags such as Name are
+// added on top.
+func (b TagBuilder) For(resourceType string, extra map[string]string) map[string]string {
+	tags := make(map[string]string, len(b.base)+len(extra)+1)
+	for key, value := range b.base {
+		tags[key] = value
+	}
+	if resourceType != "" {
+		tags[resourceTypeTag] = resourceType
+	}
+	for key, value := range extra {
+		tags[key] = value
+	}
+	return tags
+}
+
+// loadUserTags merges the tags section of the config file with --tag k=v
+// flags, which win on conflicts.
+func loadUserTags(configured map[string]string) (map[string]string, error) {
+	tags := map[string]string{}
+	for key, value := range configured {
+		tags[key] = value
+	}
+
+	for _, entry := range viper.GetStringSlice("tag") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --tag %q: expected key=value", entry)
+		}
+		tags[key] = value
+	}
+
+	for key := range tags {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "aws:") || strings.HasPrefix(lower, "xstrapolate-") {
+			return nil, fmt.Errorf("tag %q uses a reserved prefix (aws: or xstrapolate-)", key)
+		}
+	}
+
+	return tags, nil
+}
+
+// sortedKeys returns the keys of a tag map in a stable order.
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
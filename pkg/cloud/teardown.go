@@ -0,0 +1,172 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Independent resources deleted at the same time
+const teardownConcurrency = 8
+
+// Variables so tests can retry without waiting minutes
+var (
+	retryInitialDelay = 2 * time.Second
+	retryMaxDelay     = 30 * time.Second
+	retryTimeout      = 5 * time.Minute
+)
+
+// errStillInUse is returned by delete functions that are waiting for the
+// cloud to release a resource. It is always retried.
+var errStillInUse = errors.New("still in use")
+
+// teardownNode is one resource in a teardown graph. It is deleted once every
+// node listed in deps has been deleted.
+type teardownNode struct {
+	id     string
+	deps   []string
+	delete func() error
+}
+
+// TeardownFailure is a resource a teardown could not delete.
+type TeardownFailure struct {
+	ID  string
+	Err error
+}
+
+// TeardownError lists the resources left behind by a teardown.
+type TeardownError struct {
+	Remaining []TeardownFailure
+}
+
+func (e *TeardownError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "teardown incomplete, %d resource(s) remain:", len(e.Remaining))
+	for _, failure := range e.Remaining {
+		fmt.Fprintf(&b, "\n  %s: %v", failure.ID, failure.Err)
+	}
+	return b.String()
+}
+
+// Is makes errors.Is(err, ErrPartialTeardown) true for teardown errors.
+func (e *TeardownError) Is(target error) bool {
+	return target == ErrPartialTeardown
+}
+
+// runTeardown deletes the nodes of a graph, running independent branches
+// concurrently. Nodes whose dependencies failed are not attempted and are
+// reported as remaining too.
+func runTeardown(nodes []*teardownNode) []TeardownFailure {
+	done := make(map[string]chan struct{}, len(nodes))
+	for _, node := range nodes {
+		done[node.id] = make(chan struct{})
+	}
+
+	var mu sync.Mutex
+	failed := map[string]error{}
+	sem := make(chan struct{}, teardownConcurrency)
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *teardownNode) {
+			defer wg.Done()
+			defer close(done[node.id])
+
+			for _, dep := range node.deps {
+				ch, ok := done[dep]
+				if !ok {
+					continue
+				}
+				<-ch
+
+				mu.Lock()
+				depErr := failed[dep]
+				if depErr != nil {
+					failed[node.id] = fmt.Errorf("blocked by %s", dep)
+				}
+				mu.Unlock()
+				if depErr != nil {
+					return
+				}
+			}
+
+			sem <- struct{}{}
+			err := node.delete()
+			<-sem
+
+			if err != nil {
+				mu.Lock()
+				failed[node.id] = err
+				mu.Unlock()
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	var failures []TeardownFailure
+	for _, node := range nodes {
+		if err := failed[node.id]; err != nil {
+			failures = append(failures, TeardownFailure{ID: node.id, Err: err})
+		}
+	}
+	return failures
+}
+
+// retryWithBackoff calls fn until it succeeds, fails with an error that is
+// not retryable, or retryTimeout passes. The delay doubles after every
+// attempt up to retryMaxDelay.
+func retryWithBackoff(ctx context.Context, description string, retryable func(error) bool, fn func() error) error {
+	delay := retryInitialDelay
+	deadline := time.Now().Add(retryTimeout)
+
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errStillInUse) && !retryable(err) {
+			return err
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("gave up after %s: %w", retryTimeout, err)
+		}
+
+		slog.Debug("Retrying", "what", description, "err", err, "delay", delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+		delay *= 2
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitTimeout returns how long a waiter may run: until the context deadline
+// when --timeout set one, otherwise the waiter's usual limit.
+func waitTimeout(ctx context.Context, fallback time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining > 0 {
+			return remaining
+		}
+	}
+	return fallback
+}
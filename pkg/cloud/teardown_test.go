@@ -0,0 +1,254 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunTeardown(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name string
+		// deps of each node, in the order the nodes are passed
+		deps  map[string][]string
+		order []string
+		fail  map[string]bool
+		// Nodes that must run, and after which others
+		wantRun    []string
+		wantBefore [][2]string
+		wantFailed map[string]string
+	}{
+		{
+			name:       "chain runs in dependency order",
+			deps:       map[string][]string{"vpc": {"subnet"}, "subnet": {"instance"}, "instance": nil},
+			order:      []string{"vpc", "subnet", "instance"},
+			wantRun:    []string{"instance", "subnet", "vpc"},
+			wantBefore: [][2]string{{"instance", "subnet"}, {"subnet", "vpc"}},
+		},
+		{
+			name:       "node waits for every dependency",
+			deps:       map[string][]string{"vpc": {"igw", "sg"}, "igw": nil, "sg": nil},
+			order:      []string{"vpc", "igw", "sg"},
+			wantRun:    []string{"igw", "sg", "vpc"},
+			wantBefore: [][2]string{{"igw", "vpc"}, {"sg", "vpc"}},
+		},
+		{
+			name:    "unknown dependency is ignored",
+			deps:    map[string][]string{"vpc": {"gone"}},
+			order:   []string{"vpc"},
+			wantRun: []string{"vpc"},
+		},
+		{
+			name:    "failure blocks dependents transitively",
+			deps:    map[string][]string{"instance": nil, "subnet": {"instance"}, "vpc": {"subnet"}, "eip": nil},
+			order:   []string{"instance", "subnet", "vpc", "eip"},
+			fail:    map[string]bool{"instance": true},
+			wantRun: []string{"eip", "instance"},
+			wantFailed: map[string]string{
+				"instance": "boom",
+				"subnet":   "blocked by instance",
+				"vpc":      "blocked by subnet",
+			},
+		},
+		{
+			name:    "failure of one dependency blocks the node",
+			deps:    map[string][]string{"vpc": {"igw", "sg"}, "igw": nil, "sg": nil},
+			order:   []string{"vpc", "igw", "sg"},
+			fail:    map[string]bool{"sg": true},
+			wantRun: []string{"igw", "sg"},
+			wantFailed: map[string]string{
+				"sg":  "boom",
+				"vpc": "blocked by sg",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var ran []string
+
+			var nodes []*teardownNode
+			for _, id := range tt.order {
+				id := id
+				nodes = append(nodes, &teardownNode{
+					id:   id,
+					deps: tt.deps[id],
+					delete: func() error {
+						mu.Lock()
+						ran = append(ran, id)
+						mu.Unlock()
+						if tt.fail[id] {
+							return errBoom
+						}
+						return nil
+					},
+				})
+			}
+
+			failures := runTeardown(nodes)
+
+			position := map[string]int{}
+			for i, id := range ran {
+				position[id] = i
+			}
+			var gotRun []string
+			for id := range position {
+				gotRun = append(gotRun, id)
+			}
+			if !sameElements(gotRun, tt.wantRun) || len(ran) != len(tt.wantRun) {
+				t.Errorf("ran %v, want %v once each", ran, tt.wantRun)
+			}
+			for _, pair := range tt.wantBefore {
+				if position[pair[0]] > position[pair[1]] {
+					t.Errorf("%s ran before %s: %v", pair[1], pair[0], ran)
+				}
+			}
+
+			gotFailed := map[string]string{}
+			for _, failure := range failures {
+				gotFailed[failure.ID] = failure.Err.Error()
+			}
+			if len(gotFailed) == 0 && len(tt.wantFailed) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(gotFailed, tt.wantFailed) {
+				t.Errorf("failures %v, want %v", gotFailed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	initialDelay, maxDelay, timeout := retryInitialDelay, retryMaxDelay, retryTimeout
+	t.Cleanup(func() {
+		retryInitialDelay, retryMaxDelay, retryTimeout = initialDelay, maxDelay, timeout
+	})
+	retryInitialDelay = time.Millisecond
+	retryMaxDelay = 4 * time.Millisecond
+	retryTimeout = 50 * time.Millisecond
+
+	errRetryable := errors.New("retryable")
+	errFatal := errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errRetryable) }
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		// Errors returned by successive calls, nil after the last one
+		errs []error
+		// Keep returning the last error instead
+		forever   bool
+		wantCalls int
+		wantErr   error
+		wantMsg   string
+	}{
+		{
+			name:      "success",
+			ctx:       context.Background(),
+			wantCalls: 1,
+		},
+		{
+			name:      "retryable errors then success",
+			ctx:       context.Background(),
+			errs:      []error{errRetryable, errRetryable},
+			wantCalls: 3,
+		},
+		{
+			name:      "still in use is always retried",
+			ctx:       context.Background(),
+			errs:      []error{errStillInUse},
+			wantCalls: 2,
+		},
+		{
+			name:      "error that is not retryable is returned at once",
+			ctx:       context.Background(),
+			errs:      []error{errFatal},
+			wantCalls: 1,
+			wantErr:   errFatal,
+		},
+		{
+			name:      "canceled context stops retrying",
+			ctx:       canceled,
+			errs:      []error{errRetryable},
+			wantCalls: 1,
+			wantErr:   context.Canceled,
+		},
+		{
+			name:    "gives up after the timeout",
+			ctx:     context.Background(),
+			errs:    []error{errRetryable},
+			forever: true,
+			wantErr: errRetryable,
+			wantMsg: "gave up after",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			start := time.Now()
+			err := retryWithBackoff(tt.ctx, "test", retryable, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				if tt.forever {
+					return tt.errs[len(tt.errs)-1]
+				}
+				return nil
+			})
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error %q does not contain %q", err, tt.wantMsg)
+			}
+			if tt.wantCalls > 0 && calls != tt.wantCalls {
+				t.Errorf("%d calls, want %d", calls, tt.wantCalls)
+			}
+			if tt.forever {
+				if calls < 2 {
+					t.Errorf("%d calls, want retries before giving up", calls)
+				}
+				// Generous for slow machines, the deadline itself is 50ms
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("gave up after %s, want about %s", elapsed, retryTimeout)
+				}
+			}
+		})
+	}
+}
+
+// sameElements reports whether a and b hold the same strings in any order.
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
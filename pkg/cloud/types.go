@@ -1,16 +1,130 @@
 package cloud
 
+import (
+	"context"
+	"time"
+)
+
 type ClusterInfo struct {
-	Name           string
-	Type           string
-	Provider       string
-	KubeconfigPath string
-	Endpoint       string
-	Status         string
+	Name           string `json:"name" yaml:"name"`
+	Type           string `json:"type" yaml:"type"`
+	Provider       string `json:"provider" yaml:"provider"`
+	Region         string `json:"region,omitempty" yaml:"region,omitempty"`
+	KubeconfigPath string `json:"kubeconfigPath,omitempty" yaml:"kubeconfigPath,omitempty"`
+	Endpoint       string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Status         string `json:"status" yaml:"status"`
+	// Version is the Kubernetes version, when the cloud reports it
+	Version   string     `json:"version,omitempty" yaml:"version,omitempty"`
+	NodePools []NodePool `json:"nodePools,omitempty" yaml:"nodePools,omitempty"`
+	// Resources lists the IDs of the cloud resources behind the cluster,
+	// keyed by resource type such as "instance" or "vpc"
+	Resources map[string][]string `json:"resources,omitempty" yaml:"resources,omitempty"`
 }
 
 type ClusterManager interface {
-	CreateCluster(name, clusterType string) (*ClusterInfo, error)
-	DeleteCluster(name string) error
-	GetCluster(name string) (*ClusterInfo, error)
-}
\ No newline at end of file
+	CreateCluster(ctx context.Context, name, clusterType string) (*ClusterInfo, error)
+	DeleteCluster(ctx context.Context, name string) error
+	GetCluster(ctx context.Context, name string) (*ClusterInfo, error)
+}
+
+// NodePool is a group of identical worker nodes, such as an EKS managed
+// node group.
+type NodePool struct {
+	Name         string `json:"name" yaml:"name"`
+	InstanceType string `json:"instanceType,omitempty" yaml:"instanceType,omitempty"`
+	Count        int32  `json:"count" yaml:"count"`
+	Spot         bool   `json:"spot,omitempty" yaml:"spot,omitempty"`
+	// Version is the Kubernetes version of the nodes, when it can differ
+	// from the control plane
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// NodePoolManager is implemented by managers that can add node pools to an
+// existing cluster and resize them.
+type NodePoolManager interface {
+	CreateNodePool(ctx context.Context, cluster string, pool NodePool) error
+	ScaleNodePool(ctx context.Context, cluster, pool string, count int32) error
+}
+
+// Upgrader is implemented by managers that can move a cluster to a newer
+// Kubernetes version. PlanUpgrade checks the version skew rules without
+// changing anything; Upgrade carries the plan out step by step.
+type Upgrader interface {
+	PlanUpgrade(ctx context.Context, name, version string) (*UpgradePlan, error)
+	Upgrade(ctx context.Context, plan *UpgradePlan) error
+}
+
+// ClusterShell is implemented by managers with clusters that are only
+// reachable through their nodes, such as single-node clusters behind SSM.
+// RunOnCluster runs a shell script on a node with kubectl, flux and helm
+// pointed at the cluster and returns its standard output.
+type ClusterShell interface {
+	RunOnCluster(ctx context.Context, name string, script []string) (string, error)
+}
+
+// ClusterLister is implemented by managers that can list the clusters they
+// created.
+type ClusterLister interface {
+	ListClusters(ctx context.Context) ([]ClusterInfo, error)
+}
+
+// LogStreamer is implemented by managers that can read the bootstrap log of
+// a cluster node.
+type LogStreamer interface {
+	StreamBootstrapLogs(ctx context.Context, name string, follow bool, onLine func(line string)) error
+}
+
+// PowerManager is implemented by managers that can stop cluster compute
+// without deleting it, and start it again later.
+type PowerManager interface {
+	StopCluster(ctx context.Context, name string) error
+	StartCluster(ctx context.Context, name string) (*StartResult, error)
+}
+
+// StartResult describes a cluster brought back from a stopped state.
+type StartResult struct {
+	StoppedFor time.Duration
+	// EstimatedSavings is the approximate compute cost avoided, in USD
+	EstimatedSavings float64
+}
+
+// Reaper is implemented by managers that can find clusters past their TTL.
+type Reaper interface {
+	ListExpiredClusters(ctx context.Context, now time.Time) ([]ExpiredCluster, error)
+}
+
+// CostReporter is implemented by managers that can price a cluster before
+// it is created and report its actual spend afterwards.
+type CostReporter interface {
+	EstimateCost(clusterType string) (*CostEstimate, error)
+	ClusterCost(ctx context.Context, name string, days int) (*CostReport, error)
+}
+
+// GarbageCollector is implemented by managers that can find and delete
+// resources left behind by failed creates or teardowns.
+type GarbageCollector interface {
+	FindOrphans(ctx context.Context, opts GCOptions) ([]OrphanResource, error)
+	DeleteOrphans(ctx context.Context, orphans []OrphanResource) error
+}
+
+// GCOptions selects where to look for orphaned resources.
+type GCOptions struct {
+	// Regions to scan; empty means the manager's region
+	Regions    []string
+	AllRegions bool
+	// MinAge skips resources younger than this, which may belong to a
+	// create that is still running
+	MinAge time.Duration
+}
+
+// OrphanResource is a resource created by xstrapolate that no live cluster
+// uses.
+type OrphanResource struct {
+	// Region is empty for global resources such as IAM roles
+	Region  string
+	Kind    string
+	ID      string
+	Name    string
+	Cluster string
+	VpcID   string
+}
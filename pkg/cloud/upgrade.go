@@ -0,0 +1,143 @@
+package cloud
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UpgradePlan is the ordered list of steps that moves a cluster to a newer
+// Kubernetes version. It is shown before anything changes.
+type UpgradePlan struct {
+	Cluster string        `json:"cluster" yaml:"cluster"`
+	Type    string        `json:"type" yaml:"type"`
+	From    string        `json:"from" yaml:"from"`
+	To      string        `json:"to" yaml:"to"`
+	Steps   []UpgradeStep `json:"steps" yaml:"steps"`
+}
+
+// UpgradeStep upgrades one part of the cluster: the control plane, a node
+// pool or the k3s binary of a node.
+type UpgradeStep struct {
+	Component string `json:"component" yaml:"component"`
+	// Target names the node pool or instance the step acts on
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	From   string `json:"from" yaml:"from"`
+	To     string `json:"to" yaml:"to"`
+}
+
+// Empty reports whether the cluster is already on the target version.
+func (p *UpgradePlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// String renders the plan for the terminal, one numbered line per step.
+func (p *UpgradePlan) String() string {
+	if p.Empty() {
+		return fmt.Sprintf("Cluster '%s' is already on %s\n", p.Cluster, p.To)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Upgrade cluster '%s' (%s) from %s to %s:\n", p.Cluster, p.Type, p.From, p.To)
+	for i, step := range p.Steps {
+		component := step.Component
+		if step.Target != "" {
+			component += " " + step.Target
+		}
+		fmt.Fprintf(&b, "  %d. %s: %s -> %s\n", i+1, component, step.From, step.To)
+	}
+	return b.String()
+}
+
+// Matches 1.29, 1.29.4, v1.29.4+k3s1 and v1.30.0-rc1+k3s1
+var kubernetesVersionPattern = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)(?:\.([0-9]+))?(?:-rc[0-9]+)?(?:\+k3s([0-9]+))?$`)
+
+// kubeVersion is a parsed Kubernetes or k3s version. Patch and build are -1
+// when the version does not give them, as with EKS minor versions.
+type kubeVersion struct {
+	major, minor, patch, build int
+	raw                        string
+}
+
+func parseKubeVersion(version string) (kubeVersion, error) {
+	match := kubernetesVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return kubeVersion{}, fmt.Errorf("invalid Kubernetes version %q: expected e.g. 1.30 or v1.30.2+k3s1", version)
+	}
+
+	v := kubeVersion{patch: -1, build: -1, raw: version}
+	v.major, _ = strconv.Atoi(match[1])
+	v.minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		v.patch, _ = strconv.Atoi(match[3])
+	}
+	if match[4] != "" {
+		v.build, _ = strconv.Atoi(match[4])
+	}
+	return v, nil
+}
+
+func (v kubeVersion) String() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+// minorsBehind returns how many minor versions v is older than other.
+func (v kubeVersion) minorsBehind(other kubeVersion) int {
+	if v.major != other.major {
+		return (other.major - v.major) * 100
+	}
+	return other.minor - v.minor
+}
+
+// compare orders versions, treating missing patch and build numbers as
+// lower than any given one.
+func (v kubeVersion) compare(other kubeVersion) int {
+	for _, pair := range [][2]int{{v.major, other.major}, {v.minor, other.minor}, {v.patch, other.patch}, {v.build, other.build}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// maxKubeletSkew is how many minor versions nodes may lag the control plane:
+// three from Kubernetes 1.28 on, two before.
+func maxKubeletSkew(controlPlane kubeVersion) int {
+	if controlPlane.major == 1 && controlPlane.minor < 28 {
+		return 2
+	}
+	return 3
+}
+
+// checkUpgradeSkew applies the Kubernetes version skew policy to moving a
+// control plane from one version to another: no downgrades, one minor
+// version at a time, and no node left further behind than the kubelet skew
+// allows. nodes maps node pool names to their versions.
+func checkUpgradeSkew(cluster string, from, to kubeVersion, nodes map[string]kubeVersion) error {
+	if to.compare(from) < 0 {
+		return fmt.Errorf("cannot downgrade cluster '%s' from %s to %s", cluster, from, to)
+	}
+
+	if behind := from.minorsBehind(to); behind > 1 {
+		next := kubeVersion{major: from.major, minor: from.minor + 1}
+		return fmt.Errorf("the control plane moves one minor version at a time: upgrade '%s' to %s first", cluster, next)
+	}
+
+	for name, node := range nodes {
+		if node.minorsBehind(from) < 0 {
+			return fmt.Errorf("node pool %s is on %s, newer than the control plane %s", name, node, from)
+		}
+		if skew := node.minorsBehind(to); skew > maxKubeletSkew(to) {
+			return fmt.Errorf("node pool %s is on %s, more than %d minor versions behind %s; run 'cluster upgrade %s --to %s' to bring the node pools to the control plane first",
+				name, node, maxKubeletSkew(to), to, cluster, from)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package cloud
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var userDataTemplate = template.Must(
+	template.New("single-node.sh.tmpl").
+		Funcs(template.FuncMap{
+			"shellQuote": shellQuote,
+			"yamlQuote":  yamlQuote,
+		}).
+		ParseFS(templateFS, "templates/*.tmpl"),
+)
+
+var (
+	clusterNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+	packageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+:-]*$`)
+	bucketNamePattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	gitURLPattern      = regexp.MustCompile(`^(https|ssh)://[^\s]+$`)
+	fluxVersionPattern = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+$`)
+)
+
+// userDataParams is the data passed to the single-node bootstrap template
+// and, in part, to the template of nodes joining a cluster.
+type userDataParams struct {
+	ClusterName string
+	Arch        string
+	Bootstrap   config.BootstrapConfig
+	K3s         config.K3sConfig
+	K3sArgs     []string // extra k3s arguments; the ones from K3s are added when rendering
+	Spot        config.AWSSpotConfig
+	GitOps      config.GitOpsConfig
+	FluxVersion string // pinned Flux release; empty means latest
+
+	// SSM Parameter Store parameter holding the k3s token, which the
+	// instance writes to TokenFile before installing k3s
+	Region         string
+	TokenParameter string
+	TokenFile      string
+
+	// Nodes joining a cluster: "server" or "agent", and the API they join
+	JoinRole  string
+	ServerURL string
+
+	// systemd OnCalendar values for scheduled stops
+	AutoStopCalendar  string
+	ExpiresAtCalendar string
+
+	ProgressMarker string
+	FailedMarker   string
+	DoneMarker     string
+}
+
+// renderUserData renders the single-node bootstrap script. User-supplied
+// hooks are inserted verbatim; every other value is quoted for its context.
+func renderUserData(params userDataParams) (string, error) {
+	if err := ValidateClusterName(params.ClusterName); err != nil {
+		return "", err
+	}
+	for _, pkg := range params.Bootstrap.Packages {
+		if !packageNamePattern.MatchString(pkg) {
+			return "", fmt.Errorf("invalid package name in bootstrap.packages: %q", pkg)
+		}
+	}
+
+	if bucket := params.Spot.SnapshotBucket; bucket != "" && !bucketNamePattern.MatchString(bucket) {
+		return "", fmt.Errorf("invalid spot snapshot bucket name: %q", bucket)
+	}
+
+	if params.GitOps.URL != "" {
+		if err := ValidateGitOps(params.GitOps); err != nil {
+			return "", err
+		}
+		params.GitOps = params.GitOps.WithDefaults()
+	}
+
+	if params.FluxVersion != "" && !fluxVersionPattern.MatchString(params.FluxVersion) {
+		return "", fmt.Errorf("invalid components.flux %q: expected a release such as v2.3.0", params.FluxVersion)
+	}
+
+	k3sArgs, err := k3sServerArgs(params.K3s)
+	if err != nil {
+		return "", err
+	}
+	params.K3sArgs = append(k3sArgs, params.K3sArgs...)
+	params.TokenFile = k3sTokenFile
+
+	params.ProgressMarker = BootstrapProgressMarker
+	params.FailedMarker = BootstrapFailedMarker
+	params.DoneMarker = BootstrapDoneMarker
+
+	var buf bytes.Buffer
+	if err := userDataTemplate.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("failed to render user data: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// renderJoinUserData renders the bootstrap script of a k3s server or agent
+// that joins an existing cluster with the token in params.TokenParameter.
+// Joining servers take the same k3s arguments as the first one.
+func renderJoinUserData(params userDataParams) (string, error) {
+	if err := ValidateClusterName(params.ClusterName); err != nil {
+		return "", err
+	}
+	for _, pkg := range params.Bootstrap.Packages {
+		if !packageNamePattern.MatchString(pkg) {
+			return "", fmt.Errorf("invalid package name in bootstrap.packages: %q", pkg)
+		}
+	}
+	if params.ServerURL == "" || params.TokenParameter == "" {
+		return "", fmt.Errorf("joining nodes need a server URL and a token parameter")
+	}
+	if params.K3s.Version != "" && !k3sVersionPattern.MatchString(params.K3s.Version) {
+		return "", fmt.Errorf("invalid k3s version %q: expected a release such as v1.29.4+k3s1", params.K3s.Version)
+	}
+
+	switch params.JoinRole {
+	case "agent":
+		params.K3sArgs = nil
+	case "server":
+		// Joining servers use the datastore of the cluster they join
+		k3s := params.K3s
+		k3s.Datastore = ""
+		k3sArgs, err := k3sServerArgs(k3s)
+		if err != nil {
+			return "", err
+		}
+		params.K3sArgs = append(k3sArgs, params.K3sArgs...)
+	default:
+		return "", fmt.Errorf("unknown k3s role %q", params.JoinRole)
+	}
+	params.TokenFile = k3sTokenFile
+
+	params.ProgressMarker = BootstrapProgressMarker
+	params.FailedMarker = BootstrapFailedMarker
+	params.DoneMarker = BootstrapDoneMarker
+
+	var buf bytes.Buffer
+	if err := userDataTemplate.ExecuteTemplate(&buf, "k3s-node.sh.tmpl", params); err != nil {
+		return "", fmt.Errorf("failed to render %s user data: %w", params.JoinRole, err)
+	}
+	return buf.String(), nil
+}
+
+// ValidateClusterName checks that a cluster name is usable as a Kubernetes
+// label value and an AWS/Azure resource name.
+func ValidateClusterName(name string) error {
+	if !clusterNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid cluster name %q: use lowercase letters, digits and '-', at most 63 characters", name)
+	}
+	return nil
+}
+
+// ValidateGitOps checks that Flux can clone the GitOps repository URL.
+func ValidateGitOps(repo config.GitOpsConfig) error {
+	if !gitURLPattern.MatchString(repo.URL) {
+		return fmt.Errorf("invalid gitops url %q: use an https:// or ssh:// URL", repo.URL)
+	}
+	return nil
+}
+
+// shellQuote wraps s in single quotes so bash treats it as a literal.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// yamlQuote renders s as a double-quoted YAML scalar. JSON strings are valid
+// YAML, so the JSON encoder handles all escaping.
+func yamlQuote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,225 @@
+package cloud
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// Values bash or YAML would interpret if they were not quoted
+var (
+	hostileMirrors = map[string][]string{
+		"docker.io":               {"https://mirror.example.com/$HOME'x\"y"},
+		"quay.io\"\n  evil: true": {"https://line\nbreak.example.com"},
+	}
+	hostileGitOps = config.GitOpsConfig{
+		URL:    "https://git.example.com/o'rg/$(id).git",
+		Branch: "main'; rm -rf / #",
+		Path:   "./clusters/$USER\n./other",
+	}
+	// Hooks are inserted verbatim, so $VARS and quotes must survive as is
+	hooks = config.BootstrapConfig{
+		Packages: []string{"jq", "python3.11"},
+		PreK3s:   []string{`echo "pre $HOSTNAME" > /tmp/pre`, "sysctl -w vm.max_map_count=262144"},
+		PostK3s:  []string{`kubectl label node --all 'tier=dev'`},
+		RegistryMirrors: map[string][]string{
+			"docker.io": {"https://mirror.example.com"},
+		},
+	}
+)
+
+func TestRenderUserDataGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		render func() (string, error)
+	}{
+		{
+			golden: "single-node.golden",
+			render: func() (string, error) {
+				return renderUserData(userDataParams{
+					ClusterName:       "dev",
+					Arch:              "amd64",
+					Bootstrap:         hooks,
+					K3s:               config.K3sConfig{Version: "v1.29.4+k3s1", Disable: []string{"traefik"}},
+					Spot:              config.AWSSpotConfig{Enabled: true, SnapshotBucket: "snapshots.example"},
+					GitOps:            config.GitOpsConfig{URL: "https://github.com/example/fleet"},
+					FluxVersion:       "v2.3.0",
+					AutoStopCalendar:  "*-*-* 19:00:00 Europe/Berlin",
+					ExpiresAtCalendar: "2026-10-19 08:00:00 UTC",
+				})
+			},
+		},
+		{
+			golden: "single-node-escaping.golden",
+			render: func() (string, error) {
+				return renderUserData(userDataParams{
+					ClusterName: "dev",
+					Arch:        "arm64",
+					Bootstrap:   config.BootstrapConfig{RegistryMirrors: hostileMirrors},
+					GitOps:      hostileGitOps,
+				})
+			},
+		},
+		{
+			golden: "k3s-server.golden",
+			render: func() (string, error) {
+				return renderUserData(userDataParams{
+					ClusterName:    "prod",
+					Arch:           "amd64",
+					Bootstrap:      config.BootstrapConfig{RegistryMirrors: hostileMirrors},
+					K3s:            config.K3sConfig{Datastore: "etcd", TLSSANs: []string{"k3s.example.com"}},
+					K3sArgs:        []string{"--token-file", k3sTokenFile, "--tls-san", "k3s-prod-abc.elb.amazonaws.com"},
+					Region:         "eu-central-1",
+					TokenParameter: k3sTokenParameter("prod"),
+				})
+			},
+		},
+		{
+			golden: "k3s-join-server.golden",
+			render: func() (string, error) {
+				return renderJoinUserData(userDataParams{
+					ClusterName:    "prod",
+					Bootstrap:      config.BootstrapConfig{Packages: []string{"jq"}},
+					K3s:            config.K3sConfig{Version: "v1.29.4+k3s1", Datastore: "etcd", Disable: []string{"traefik"}},
+					Region:         "eu-central-1",
+					TokenParameter: k3sTokenParameter("prod"),
+					JoinRole:       "server",
+					ServerURL:      "https://10.0.101.12:6443",
+				})
+			},
+		},
+		{
+			golden: "k3s-agent.golden",
+			render: func() (string, error) {
+				return renderJoinUserData(userDataParams{
+					ClusterName:       "prod",
+					Bootstrap:         config.BootstrapConfig{RegistryMirrors: hostileMirrors},
+					K3s:               config.K3sConfig{Disable: []string{"traefik"}},
+					Region:            "eu-central-1",
+					TokenParameter:    k3sTokenParameter("prod"),
+					JoinRole:          "agent",
+					ServerURL:         "https://k3s-prod-abc.elb.amazonaws.com:6443",
+					ExpiresAtCalendar: "2026-10-19 08:00:00 UTC",
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := tt.render()
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			checkBashSyntax(t, got)
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run go test -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("render differs from %s (run go test -update to accept):\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestRenderUserDataQuoting(t *testing.T) {
+	got, err := renderUserData(userDataParams{
+		ClusterName: "dev",
+		Bootstrap:   config.BootstrapConfig{PreK3s: hooks.PreK3s, PostK3s: hooks.PostK3s, RegistryMirrors: hostileMirrors},
+		GitOps:      hostileGitOps,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		// Single quotes keep $ and ; literal; embedded quotes are closed and reopened
+		`--url='https://git.example.com/o'\''rg/$(id).git'`,
+		`--branch='main'\''; rm -rf / #'`,
+		"--path='./clusters/$USER\n./other'",
+		// JSON escaping keeps mirrors on one YAML line
+		`  "quay.io\"\n  evil: true":`,
+		`      - "https://mirror.example.com/$HOME'x\"y"`,
+		`      - "https://line\nbreak.example.com"`,
+		// Hooks are not quoted at all
+		`echo "pre $HOSTNAME" > /tmp/pre`,
+		`kubectl label node --all 'tier=dev'`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered script does not contain %q", want)
+		}
+	}
+	if strings.Contains(got, "\n  evil: true") {
+		t.Error("registry mirror key broke out of its YAML line")
+	}
+}
+
+func TestRenderUserDataRejects(t *testing.T) {
+	valid := userDataParams{ClusterName: "dev", Arch: "amd64"}
+
+	tests := []struct {
+		name string
+		// join is set when join scripts take the value too
+		join   bool
+		params func(p *userDataParams)
+	}{
+		{"quote in cluster name", true, func(p *userDataParams) { p.ClusterName = "dev'x" }},
+		{"dollar in cluster name", true, func(p *userDataParams) { p.ClusterName = "dev$HOME" }},
+		{"newline in cluster name", true, func(p *userDataParams) { p.ClusterName = "dev\nrm -rf /" }},
+		{"quote in package", true, func(p *userDataParams) { p.Bootstrap.Packages = []string{"jq'"} }},
+		{"dollar in package", true, func(p *userDataParams) { p.Bootstrap.Packages = []string{"$(id)"} }},
+		{"newline in package", true, func(p *userDataParams) { p.Bootstrap.Packages = []string{"jq\nreboot"} }},
+		{"newline in gitops url", false, func(p *userDataParams) { p.GitOps.URL = "https://example.com/x\nreboot" }},
+		{"space in gitops url", false, func(p *userDataParams) { p.GitOps.URL = "https://example.com/x y" }},
+		{"quote in snapshot bucket", false, func(p *userDataParams) { p.Spot.SnapshotBucket = "bucket'x" }},
+		{"invalid flux version", false, func(p *userDataParams) { p.FluxVersion = "v2.3.0; reboot" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid
+			tt.params(&params)
+			if _, err := renderUserData(params); err == nil {
+				t.Error("renderUserData accepted the params")
+			}
+
+			if !tt.join {
+				return
+			}
+			params.TokenParameter = k3sTokenParameter("dev")
+			params.ServerURL = "https://10.0.101.12:6443"
+			params.JoinRole = "agent"
+			if _, err := renderJoinUserData(params); err == nil {
+				t.Error("renderJoinUserData accepted the params")
+			}
+		})
+	}
+}
+
+// checkBashSyntax parses the script with bash -n when bash is installed.
+func checkBashSyntax(t *testing.T, script string) {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		return
+	}
+	cmd := exec.Command("bash", "-n")
+	cmd.Stdin = strings.NewReader(script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("bash -n: %v\n%s", err, output)
+	}
+}
@@ -4,17 +4,34 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Cloud CloudConfig `mapstructure:"cloud"`
+	// Provider is the default cloud, aws, azure or local; --cloud overrides it
+	Provider  string          `mapstructure:"provider"`
+	Cloud     CloudConfig     `mapstructure:"cloud"`
+	Bootstrap BootstrapConfig `mapstructure:"bootstrap"`
+	K3s       K3sConfig       `mapstructure:"k3s"`
+	Network   NetworkConfig   `mapstructure:"network"`
+	GitOps    GitOpsConfig    `mapstructure:"gitops"`
+	// Components pins the Flux, Crossplane and provider versions
+	Components ComponentsConfig `mapstructure:"components"`
+	// Tags are added to every resource xstrapolate creates
+	Tags map[string]string `mapstructure:"tags"`
+
+	// CurrentContext names the entry of Contexts in use; --context overrides it
+	CurrentContext string             `mapstructure:"current_context"`
+	Contexts       map[string]Context `mapstructure:"contexts"`
 }
 
 type CloudConfig struct {
 	AWS   AWSConfig   `mapstructure:"aws"`
 	Azure AzureConfig `mapstructure:"azure"`
+	Local LocalConfig `mapstructure:"local"`
 }
 
 type AWSConfig struct {
@@ -22,6 +39,71 @@ type AWSConfig struct {
 	AccessKeyID     string `mapstructure:"access_key_id"`
 	SecretAccessKey string `mapstructure:"secret_access_key"`
 	SessionToken    string `mapstructure:"session_token"`
+
+	// Profile selects an entry of Profiles, or else a profile in
+	// ~/.aws/config; --profile overrides it
+	Profile string `mapstructure:"profile"`
+	// RoleARN, ExternalID and MFASerial apply when the selected profile
+	// does not set them
+	RoleARN    string `mapstructure:"role_arn"`
+	ExternalID string `mapstructure:"external_id"`
+	MFASerial  string `mapstructure:"mfa_serial"`
+	// Profiles are per-environment settings, e.g. dev and prod
+	Profiles map[string]AWSProfile `mapstructure:"profiles"`
+
+	Instance AWSInstanceConfig `mapstructure:"instance"`
+	EKS      AWSEKSConfig      `mapstructure:"eks"`
+}
+
+// AWSEKSConfig describes EKS clusters.
+type AWSEKSConfig struct {
+	// Version is the Kubernetes minor version, e.g. "1.29"
+	Version string `mapstructure:"version"`
+	// NodePools become managed node groups; empty means one "default" pool
+	// of --node-count t3.medium nodes
+	NodePools []NodePoolConfig `mapstructure:"node_pools"`
+}
+
+// NodePoolConfig is a group of identical worker nodes.
+type NodePoolConfig struct {
+	Name         string `mapstructure:"name"`
+	InstanceType string `mapstructure:"instance_type"`
+	Count        int32  `mapstructure:"count"`
+	Spot         bool   `mapstructure:"spot"`
+}
+
+// AWSProfile is a named set of account settings selected with --profile.
+type AWSProfile struct {
+	// AWSProfile is the profile in ~/.aws/config to load, e.g. an SSO
+	// profile; empty uses the default credential chain
+	AWSProfile string `mapstructure:"aws_profile"`
+	Region     string `mapstructure:"region"`
+	// RoleARN is assumed on top of the profile's credentials
+	RoleARN    string `mapstructure:"role_arn"`
+	ExternalID string `mapstructure:"external_id"`
+	// MFASerial is the ARN of the MFA device; the token code is prompted for
+	MFASerial string `mapstructure:"mfa_serial"`
+}
+
+// AWSInstanceConfig describes the EC2 instance used by single-node clusters.
+type AWSInstanceConfig struct {
+	// Type is the EC2 instance type. Graviton types get an arm64 AMI.
+	Type           string `mapstructure:"type"`
+	RootVolumeSize int32  `mapstructure:"root_volume_size"`
+	RootVolumeType string `mapstructure:"root_volume_type"`
+	Encrypted      bool   `mapstructure:"encrypted"`
+	KMSKeyID       string `mapstructure:"kms_key_id"`
+
+	Spot AWSSpotConfig `mapstructure:"spot"`
+}
+
+// AWSSpotConfig enables spot capacity for single-node clusters.
+type AWSSpotConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxPrice is the hourly USD limit; empty means the on-demand price
+	MaxPrice string `mapstructure:"max_price"`
+	// SnapshotBucket receives a k3s state snapshot on interruption when set
+	SnapshotBucket string `mapstructure:"snapshot_bucket"`
 }
 
 type AzureConfig struct {
@@ -32,6 +114,110 @@ type AzureConfig struct {
 	Location       string `mapstructure:"location"`
 }
 
+// LocalConfig describes clusters run in Docker on this machine.
+type LocalConfig struct {
+	// Runtime is kind, k3d or k3s (k3s straight in Docker); empty picks the
+	// first of them installed. --runtime overrides it
+	Runtime string `mapstructure:"runtime"`
+}
+
+// NetworkConfig sets the address ranges of new clusters.
+type NetworkConfig struct {
+	// VPCCIDR is the /16 block of VPCs created on AWS; subnets are /24s in it
+	VPCCIDR string `mapstructure:"vpc_cidr" json:"vpc_cidr,omitempty" yaml:"vpc_cidr,omitempty"`
+	// ClusterCIDR and ServiceCIDR override k3s.cluster_cidr and
+	// k3s.service_cidr
+	ClusterCIDR string `mapstructure:"cluster_cidr" json:"cluster_cidr,omitempty" yaml:"cluster_cidr,omitempty"`
+	ServiceCIDR string `mapstructure:"service_cidr" json:"service_cidr,omitempty" yaml:"service_cidr,omitempty"`
+}
+
+// GitOpsConfig points Flux at the repository holding the cluster's
+// manifests. Empty URL leaves Flux installed without a source.
+type GitOpsConfig struct {
+	URL    string `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Branch string `mapstructure:"branch" json:"branch,omitempty" yaml:"branch,omitempty"`
+	// Path is the directory of the repository Flux applies
+	Path string `mapstructure:"path" json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// WithDefaults fills in the main branch and the repository root.
+func (g GitOpsConfig) WithDefaults() GitOpsConfig {
+	if g.Branch == "" {
+		g.Branch = "main"
+	}
+	if g.Path == "" {
+		g.Path = "./"
+	}
+	return g
+}
+
+// ComponentsConfig pins the versions of the components 'components upgrade'
+// manages. Empty means the latest release.
+type ComponentsConfig struct {
+	// Flux is a flux2 release, e.g. "v2.3.0"; new clusters get it too
+	Flux string `mapstructure:"flux" json:"flux,omitempty" yaml:"flux,omitempty"`
+	// Crossplane is a version of the crossplane-stable Helm chart, e.g. "1.16.0"
+	Crossplane string `mapstructure:"crossplane" json:"crossplane,omitempty" yaml:"crossplane,omitempty"`
+	// Providers maps Crossplane provider names to package versions
+	Providers map[string]string `mapstructure:"providers" json:"providers,omitempty" yaml:"providers,omitempty"`
+}
+
+// PinComponents records component versions in the config file, keeping the
+// rest of the file and its comments as they are. Empty versions are left
+// untouched.
+func PinComponents(path string, pins ComponentsConfig) error {
+	return editFile(path, func(root *yaml.Node) {
+		components := childMapping(root, "components")
+		if pins.Flux != "" {
+			setScalar(components, "flux", pins.Flux)
+		}
+		if pins.Crossplane != "" {
+			setScalar(components, "crossplane", pins.Crossplane)
+		}
+		if len(pins.Providers) == 0 {
+			return
+		}
+		providers := childMapping(components, "providers")
+		names := make([]string, 0, len(pins.Providers))
+		for name := range pins.Providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			setScalar(providers, name, pins.Providers[name])
+		}
+	})
+}
+
+// BootstrapConfig customizes the bootstrap script of single-node clusters.
+type BootstrapConfig struct {
+	// Packages are installed alongside the base tooling
+	Packages []string `mapstructure:"packages"`
+	// PreK3s and PostK3s are shell snippets run before k3s is installed and
+	// after Flux is up
+	PreK3s  []string `mapstructure:"pre_k3s"`
+	PostK3s []string `mapstructure:"post_k3s"`
+	// RegistryMirrors maps a registry host to its mirror endpoints
+	RegistryMirrors map[string][]string `mapstructure:"registry_mirrors"`
+}
+
+// K3sConfig controls how k3s is installed on single-node clusters.
+type K3sConfig struct {
+	// Version pins INSTALL_K3S_VERSION, e.g. "v1.29.4+k3s1". Empty means latest stable.
+	Version string `mapstructure:"version"`
+	// Disable lists packaged components to skip, e.g. traefik, servicelb
+	Disable     []string `mapstructure:"disable"`
+	ClusterCIDR string   `mapstructure:"cluster_cidr"`
+	ServiceCIDR string   `mapstructure:"service_cidr"`
+	// TLSSANs are extra hostnames or IPs for the API server certificate
+	TLSSANs []string `mapstructure:"tls_sans"`
+	// Datastore is "sqlite" (default) or "etcd" for embedded etcd
+	Datastore string `mapstructure:"datastore"`
+	// Servers (1, or 3 for HA) and Agents size clusters of type k3s
+	Servers int32 `mapstructure:"servers"`
+	Agents  int32 `mapstructure:"agents"`
+}
+
 func Load() (*Config, error) {
 	var cfg Config
 
@@ -39,6 +225,16 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := cfg.applyContext(); err != nil {
+		return nil, err
+	}
+	if cfg.Network.ClusterCIDR != "" {
+		cfg.K3s.ClusterCIDR = cfg.Network.ClusterCIDR
+	}
+	if cfg.Network.ServiceCIDR != "" {
+		cfg.K3s.ServiceCIDR = cfg.Network.ServiceCIDR
+	}
+
 	return &cfg, nil
 }
 
@@ -57,20 +253,125 @@ func CreateDefaultConfig() error {
 	defaultConfig := `# XstrapOlate Configuration
 # Copy this file to ~/.xstrapolate.yaml and fill in your credentials
 
+# Cloud used when --cloud is not given: aws, azure or local
+provider: ""
+
 cloud:
   aws:
     region: "us-west-2"
-    # AWS credentials (optional if using AWS CLI/IAM roles)
+    # Static AWS credentials (optional). AWS_ACCESS_KEY_ID in the environment
+    # wins over these; leave them empty to use the AWS CLI profile, SSO or
+    # an instance role
     access_key_id: ""
     secret_access_key: ""
     session_token: ""
+    # Named profile to use: an entry under profiles, or a profile in ~/.aws/config
+    profile: ""
+    # Role to assume on top of the credentials above (optional)
+    role_arn: ""
+    external_id: ""
+    mfa_serial: ""       # MFA device ARN; the token code is prompted for
+    # Per-environment settings, selected with --profile <name>
+    profiles: {}
+    #   prod:
+    #     aws_profile: "prod-sso"   # profile in ~/.aws/config, e.g. SSO
+    #     region: "us-east-1"
+    #     role_arn: "arn:aws:iam::123456789012:role/xstrapolate"
+    #     mfa_serial: "arn:aws:iam::111111111111:mfa/alice"
+    # EC2 instance for single-node clusters
+    instance:
+      type: "t3.medium"      # Graviton types such as t4g.medium use an arm64 AMI
+      root_volume_size: 0    # GiB, 0 keeps the AMI default
+      root_volume_type: ""   # e.g. gp3
+      encrypted: false
+      kms_key_id: ""         # implies encrypted
+      spot:
+        enabled: false
+        max_price: ""        # hourly USD, empty caps at the on-demand price
+        snapshot_bucket: ""  # S3 bucket for k3s state on interruption
+    # EKS clusters
+    eks:
+      version: "1.28"
+      node_pools: []         # empty: one "default" pool of --node-count t3.medium nodes
+      #   - name: "default"
+      #     instance_type: "t3.large"
+      #     count: 2
+      #     spot: false
 
   azure:
     subscription_id: ""
+    # Service principal (optional). Set all three to use it; AZURE_CLIENT_SECRET
+    # in the environment wins, and without either the Azure CLI login is used
     tenant_id: ""
     client_id: ""
     client_secret: ""
     location: "eastus"
+
+  # Clusters in Docker on this machine, for trying GitOps repositories
+  local:
+    runtime: ""              # kind, k3d or k3s (k3s in Docker); empty picks the first installed
+
+# k3s settings for single-node clusters
+k3s:
+  version: ""          # e.g. "v1.29.4+k3s1", empty for latest stable
+  disable: []          # e.g. ["traefik", "servicelb"]
+  cluster_cidr: ""
+  service_cidr: ""
+  tls_sans: []
+  datastore: "sqlite"  # or "etcd"
+  servers: 1           # k3s clusters: 1, or 3 for an HA control plane
+  agents: 2            # k3s clusters: worker nodes next to the servers
+
+# Address ranges of new clusters
+network:
+  vpc_cidr: "10.0.0.0/16"  # /16 block of AWS VPCs
+  cluster_cidr: ""         # overrides k3s.cluster_cidr
+  service_cidr: ""         # overrides k3s.service_cidr
+
+# Git repository Flux syncs the cluster from (optional)
+gitops:
+  url: ""              # e.g. "https://github.com/example/fleet"
+  branch: "main"
+  path: ""             # e.g. "./clusters/dev"
+
+# Component versions for new clusters and 'components upgrade' (default: latest)
+components: {}
+#   flux: "v2.3.0"
+#   crossplane: "1.16.0"
+#   providers:
+#     provider-aws-s3: "v1.5.0"
+
+# Tags added to every resource xstrapolate creates (--tag key=value adds more)
+tags: {}
+#   cost-center: "platform"
+#   owner: "team@example.com"
+
+# Extra steps for single-node cluster bootstrap
+bootstrap:
+  packages: []
+  pre_k3s: []
+  post_k3s: []
+  registry_mirrors: {}
+  #   docker.io:
+  #     - "https://mirror.example.com"
+
+# Named environments, switched with 'xstrapolate context use <name>' or
+# --context. A context's settings win over the ones above; flags win over both
+current_context: ""
+contexts: {}
+#   dev:
+#     cloud: "aws"
+#     region: "us-west-2"
+#     profile: "dev"
+#     type: "single-node"
+#     tags:
+#       env: "dev"
+#   sandbox-eu:
+#     cloud: "azure"
+#     region: "westeurope"   # the Azure location
+#     type: "aks"
+#     network:
+#       vpc_cidr: "10.20.0.0/16"
 `
 
 	if err := os.WriteFile(configPath, []byte(defaultConfig), 0600); err != nil {
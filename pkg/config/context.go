@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Context is a named environment such as dev or staging. Its settings win
+// over the top-level ones; command line flags win over both.
+type Context struct {
+	// Cloud is used when --cloud is not given: aws or azure
+	Cloud string `mapstructure:"cloud" json:"cloud,omitempty" yaml:"cloud,omitempty"`
+	// Region is the AWS region or Azure location
+	Region string `mapstructure:"region" json:"region,omitempty" yaml:"region,omitempty"`
+	// Profile selects an AWS profile like cloud.aws.profile
+	Profile string `mapstructure:"profile" json:"profile,omitempty" yaml:"profile,omitempty"`
+	// Type is the default cluster type for 'cluster create'
+	Type string `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
+	// Tags are merged into the top-level tags
+	Tags    map[string]string `mapstructure:"tags" json:"tags,omitempty" yaml:"tags,omitempty"`
+	Network NetworkConfig     `mapstructure:"network" json:"network,omitempty" yaml:"network,omitempty"`
+}
+
+// ActiveContext returns the context selected with --context or
+// current_context. It returns an empty name and nil when none is selected.
+func (c *Config) ActiveContext() (string, *Context, error) {
+	name := viper.GetString("context")
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return "", nil, nil
+	}
+
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return "", nil, fmt.Errorf("context '%s' not found in config (have: %v)", name, c.ContextNames())
+	}
+	return name, &ctx, nil
+}
+
+// ContextNames returns the names of the configured contexts, sorted.
+func (c *Config) ContextNames() []string {
+	names := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyContext overlays the active context on the top-level settings.
+func (c *Config) applyContext() error {
+	_, ctx, err := c.ActiveContext()
+	if err != nil || ctx == nil {
+		return err
+	}
+
+	if ctx.Cloud != "" {
+		c.Provider = ctx.Cloud
+	}
+	if ctx.Region != "" {
+		switch ctx.Cloud {
+		case "aws":
+			c.Cloud.AWS.Region = ctx.Region
+		case "azure":
+			c.Cloud.Azure.Location = ctx.Region
+		default:
+			c.Cloud.AWS.Region = ctx.Region
+			c.Cloud.Azure.Location = ctx.Region
+		}
+	}
+	if ctx.Profile != "" {
+		c.Cloud.AWS.Profile = ctx.Profile
+	}
+
+	if len(ctx.Tags) > 0 {
+		tags := make(map[string]string, len(c.Tags)+len(ctx.Tags))
+		for key, value := range c.Tags {
+			tags[key] = value
+		}
+		for key, value := range ctx.Tags {
+			tags[key] = value
+		}
+		c.Tags = tags
+	}
+
+	if ctx.Network.VPCCIDR != "" {
+		c.Network.VPCCIDR = ctx.Network.VPCCIDR
+	}
+	if ctx.Network.ClusterCIDR != "" {
+		c.Network.ClusterCIDR = ctx.Network.ClusterCIDR
+	}
+	if ctx.Network.ServiceCIDR != "" {
+		c.Network.ServiceCIDR = ctx.Network.ServiceCIDR
+	}
+	return nil
+}
+
+// SetCurrentContext records name as current_context in the config file,
+// keeping the rest of the file and its comments as they are.
+func SetCurrentContext(path, name string) error {
+	return editFile(path, func(root *yaml.Node) {
+		setScalar(root, "current_context", name)
+	})
+}
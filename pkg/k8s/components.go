@@ -0,0 +1,267 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+)
+
+// Kinds of components, in the order they are upgraded: Flux may manage the
+// Crossplane install, and providers depend on the Crossplane core.
+const (
+	KindFlux       = "flux"
+	KindCrossplane = "crossplane"
+	KindProvider   = "provider"
+)
+
+// Component is an installed component and the version it would move to.
+type Component struct {
+	Name      string `json:"name" yaml:"name"`
+	Kind      string `json:"kind" yaml:"kind"`
+	Installed string `json:"installed" yaml:"installed"`
+	Available string `json:"available,omitempty" yaml:"available,omitempty"`
+	// Target is the version to upgrade to; empty means no upgrade
+	Target  string `json:"target,omitempty" yaml:"target,omitempty"`
+	Pinned  bool   `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+	Healthy bool   `json:"healthy" yaml:"healthy"`
+	// ManagedBy names the Flux object that owns the component. Such
+	// components are upgraded in Git, not here.
+	ManagedBy string `json:"managedBy,omitempty" yaml:"managedBy,omitempty"`
+	// Note explains why there is no target
+	Note string `json:"note,omitempty" yaml:"note,omitempty"`
+
+	// Package is a provider's package without its tag
+	Package string `json:"package,omitempty" yaml:"package,omitempty"`
+}
+
+// Names and package references that are safe to put in kubectl commands
+var (
+	objectNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	packageRefPattern = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9./:_]*$`)
+)
+
+// inspectScript prints one "kind|name|version|ready|managed-by" line per
+// installed component. Missing components print nothing.
+var inspectScript = []string{
+	`flux=$(kubectl get namespace flux-system -o jsonpath='{.metadata.labels.app\.kubernetes\.io/version}' 2>/dev/null)`,
+	`if [ -n "$flux" ]; then`,
+	`  ready=$(kubectl -n flux-system get deployments -o jsonpath='{range .items[*]}{.status.readyReplicas}/{.spec.replicas} {end}')`,
+	`  owner=$(kubectl -n flux-system get deployment source-controller -o jsonpath='{.metadata.labels.kustomize\.toolkit\.fluxcd\.io/name}' 2>/dev/null)`,
+	`  echo "flux|flux|$flux|$ready|$owner"`,
+	`fi`,
+	`image=$(kubectl -n crossplane-system get deployment crossplane -o jsonpath='{.spec.template.spec.containers[0].image}' 2>/dev/null)`,
+	`if [ -n "$image" ]; then`,
+	`  ready=$(kubectl -n crossplane-system get deployment crossplane -o jsonpath='{.status.readyReplicas}/{.spec.replicas}')`,
+	`  owner=$(kubectl -n crossplane-system get deployment crossplane -o jsonpath='{.metadata.labels.helm\.toolkit\.fluxcd\.io/name}')`,
+	`  echo "crossplane|crossplane|$image|$ready|$owner"`,
+	`  kubectl get providers.pkg.crossplane.io -o jsonpath='{range .items[*]}provider|{.metadata.name}|{.spec.package}|{.status.conditions[?(@.type=="Healthy")].status}|{.metadata.labels.kustomize\.toolkit\.fluxcd\.io/name}{"\n"}{end}' 2>/dev/null || true`,
+	`fi`,
+}
+
+// InspectComponents lists the Flux, Crossplane and provider versions
+// installed in the cluster, with their health.
+func InspectComponents(ctx context.Context, run Runner) ([]Component, error) {
+	output, err := run(ctx, inspectScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect cluster components: %w", err)
+	}
+
+	var components []Component
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 5 {
+			continue
+		}
+		component := Component{Kind: fields[0], Name: fields[1], Installed: fields[2], ManagedBy: fields[4]}
+
+		switch component.Kind {
+		case KindFlux:
+			component.Healthy = allReady(strings.Fields(fields[3]))
+		case KindCrossplane:
+			_, tag := splitPackage(fields[2])
+			component.Installed = strings.TrimPrefix(tag, "v")
+			component.Healthy = allReady([]string{fields[3]})
+		case KindProvider:
+			component.Package, component.Installed = splitPackage(fields[2])
+			component.Healthy = fields[3] == "True"
+		default:
+			continue
+		}
+		components = append(components, component)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return kindOrder(components[i].Kind) < kindOrder(components[j].Kind)
+	})
+	return components, nil
+}
+
+// PlanComponents looks up the available versions and sets the target of
+// each component: the pinned version when there is one, else the latest
+// release. Crossplane moves one minor version at a time.
+func PlanComponents(ctx context.Context, components []Component, pins config.ComponentsConfig) {
+	for i := range components {
+		c := &components[i]
+
+		var pinned, nextMinor string
+		var err error
+		switch c.Kind {
+		case KindFlux:
+			pinned = pins.Flux
+			c.Available, err = latestFluxRelease(ctx)
+		case KindCrossplane:
+			pinned = pins.Crossplane
+			var versions []string
+			versions, err = crossplaneChartVersions(ctx)
+			if len(versions) > 0 {
+				c.Available = versions[len(versions)-1]
+				nextMinor = nextMinorRelease(c.Installed, versions)
+			}
+		case KindProvider:
+			pinned = pins.Providers[c.Name]
+			var tags []string
+			tags, err = packageTags(ctx, c.Package)
+			if len(tags) > 0 {
+				c.Available = tags[len(tags)-1]
+			}
+		}
+		if err != nil {
+			slog.Warn("could not look up available versions", "component", c.Name, "err", err)
+		}
+
+		c.Pinned = pinned != ""
+		target := firstNonEmpty(pinned, c.Available)
+		if nextMinor != "" && compareVersions(target, nextMinor) > 0 {
+			c.Note = fmt.Sprintf("Crossplane moves one minor version at a time; run again for %s", target)
+			target = nextMinor
+		}
+
+		switch {
+		case target == "":
+			c.Note = "no release found"
+		case compareVersions(target, c.Installed) == 0:
+			c.Note = ""
+		case compareVersions(target, c.Installed) < 0:
+			c.Note = fmt.Sprintf("%s is older than the installed version; downgrades are not supported", target)
+		case c.ManagedBy != "":
+			c.Note = fmt.Sprintf("managed by Flux (%s); change the version in Git", c.ManagedBy)
+		case !objectNamePattern.MatchString(c.Name),
+			c.Kind == KindProvider && !packageRefPattern.MatchString(c.Package):
+			c.Note = "name cannot be upgraded safely"
+		default:
+			c.Target = target
+		}
+	}
+}
+
+// UpgradeComponent moves one component to its target version and waits
+// until it is healthy again.
+func UpgradeComponent(ctx context.Context, run Runner, c Component) error {
+	if _, ok := parseSemver(c.Target); !ok {
+		return fmt.Errorf("invalid target version %q for %s", c.Target, c.Name)
+	}
+
+	var script []string
+	switch c.Kind {
+	case KindFlux:
+		script = []string{
+			"set -e",
+			"flux install --version=" + c.Target,
+			"flux check",
+		}
+	case KindCrossplane:
+		script = []string{
+			"set -e",
+			"helm repo add crossplane-stable " + crossplaneChartRepo + " --force-update",
+			"helm repo update crossplane-stable",
+			"helm upgrade crossplane crossplane-stable/crossplane --namespace crossplane-system --version " + c.Target + " --reuse-values --wait --timeout 10m",
+			"kubectl -n crossplane-system rollout status deployment/crossplane --timeout=5m",
+			"kubectl wait --for=condition=Established crd/providers.pkg.crossplane.io --timeout=2m",
+		}
+	case KindProvider:
+		pkg := c.Package + ":" + c.Target
+		provider := "providers.pkg.crossplane.io/" + c.Name
+		script = []string{
+			"set -e",
+			fmt.Sprintf(`kubectl patch %s --type merge -p '{"spec":{"package":"%s"}}'`, provider, pkg),
+			// Wait for the new revision before trusting the Healthy condition
+			fmt.Sprintf(`for i in $(seq 60); do [ "$(kubectl get %s -o jsonpath='{.status.currentIdentifier}')" = '%s' ] && break; sleep 5; done`, provider, pkg),
+			fmt.Sprintf("kubectl wait %s --for=condition=Healthy --timeout=5m", provider),
+		}
+	default:
+		return fmt.Errorf("unknown component kind %s", c.Kind)
+	}
+
+	slog.Info("⬆️  Upgrading component", "component", c.Name, "from", c.Installed, "to", c.Target)
+	if _, err := run(ctx, script); err != nil {
+		return fmt.Errorf("failed to upgrade %s to %s: %w", c.Name, c.Target, err)
+	}
+	slog.Info("✓ Component healthy", "component", c.Name, "version", c.Target)
+	return nil
+}
+
+// nextMinorRelease returns the newest release at most one minor version
+// ahead of installed.
+func nextMinorRelease(installed string, releases []string) string {
+	current, ok := parseSemver(installed)
+	if !ok {
+		return ""
+	}
+	next := ""
+	for _, release := range releases {
+		v, _ := parseSemver(release)
+		if v.major == current.major && v.minor <= current.minor+1 {
+			next = release
+		}
+	}
+	return next
+}
+
+// splitPackage splits an image or package reference into its repository
+// and tag.
+func splitPackage(ref string) (string, string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// allReady reports whether every "ready/wanted" count is complete.
+func allReady(counts []string) bool {
+	if len(counts) == 0 {
+		return false
+	}
+	for _, count := range counts {
+		ready, wanted, _ := strings.Cut(count, "/")
+		if ready == "" || ready != wanted {
+			return false
+		}
+	}
+	return true
+}
+
+func kindOrder(kind string) int {
+	switch kind {
+	case KindFlux:
+		return 0
+	case KindCrossplane:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -1,13 +1,15 @@
 package k8s
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os/exec"
 	"strings"
 )
 
-func InstallCrossplane(kubeconfigPath string) error {
-	fmt.Println("Installing Crossplane using Helm...")
+func InstallCrossplane(ctx context.Context, kubeconfigPath string) error {
+	slog.Info("Installing Crossplane using Helm...")
 
 	commands := [][]string{
 		{"helm", "repo", "add", "crossplane-stable", "https://charts.crossplane.io/stable"},
@@ -23,32 +25,32 @@ func InstallCrossplane(kubeconfigPath string) error {
 	for i, cmd := range commands {
 		if i == 2 {
 			// Create namespace if it doesn't exist
-			createNsCmd := exec.Command(cmd[0], cmd[1:]...)
+			createNsCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
 			output, err := createNsCmd.Output()
 			if err != nil {
-				fmt.Printf("Namespace might already exist: %v\n", err)
+				slog.Debug("Namespace might already exist", "err", err)
 				continue
 			}
 
-			applyCmd := exec.Command("kubectl", "--kubeconfig", kubeconfigPath, "apply", "-f", "-")
+			applyCmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "apply", "-f", "-")
 			applyCmd.Stdin = strings.NewReader(string(output))
 			if err := applyCmd.Run(); err != nil {
-				fmt.Printf("Failed to create namespace: %v\n", err)
+				slog.Warn("failed to create namespace", "err", err)
 			}
 			continue
 		}
 
-		execCmd := exec.Command(cmd[0], cmd[1:]...)
-		fmt.Printf("Running: %s\n", execCmd.String())
+		execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+		slog.Debug("Running", "command", execCmd.String())
 
 		output, err := execCmd.CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("failed to run command %v: %w\nOutput: %s", cmd, err, string(output))
 		}
 
-		fmt.Printf("✓ %s completed\n", cmd[0])
+		slog.Info("✓ Command completed", "command", cmd[0])
 	}
 
-	fmt.Println("✅ Crossplane installed successfully!")
+	slog.Info("✅ Crossplane installed successfully!")
 	return nil
 }
\ No newline at end of file
@@ -1,33 +1,68 @@
 package k8s
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os/exec"
+
+	"github.com/drduker/xstrapolate/pkg/config"
 )
 
-func InstallFlux(kubeconfigPath string) error {
-	fmt.Println("Installing Flux...")
+// InstallFlux installs the Flux controllers, at version when it is set or
+// else the release matching the flux CLI.
+func InstallFlux(ctx context.Context, kubeconfigPath, version string) error {
+	slog.Info("Installing Flux...")
 
+	install := []string{"flux", "install", "--kubeconfig", kubeconfigPath}
+	if version != "" {
+		install = append(install, "--version", version)
+	}
 	commands := [][]string{
 		{"flux", "check", "--pre"},
-		{"flux", "install", "--kubeconfig", kubeconfigPath},
+		install,
 	}
 
 	for _, cmd := range commands {
-		execCmd := exec.Command(cmd[0], cmd[1:]...)
-		fmt.Printf("Running: %s\n", execCmd.String())
+		execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+		slog.Debug("Running", "command", execCmd.String())
 
 		output, err := execCmd.CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("failed to run command %v: %w\nOutput: %s", cmd, err, string(output))
 		}
 
-		fmt.Printf("✓ %s completed\n", cmd[0])
+		slog.Info("✓ Command completed", "command", cmd[0])
+	}
+
+	slog.Info("✅ Flux installed successfully!")
+	slog.Info("💡 To bootstrap a Git repository, run:")
+	slog.Info("   flux bootstrap github --owner=<user> --repository=<repo> --path=clusters/my-cluster")
+
+	return nil
+}
+
+// ConfigureGitOps points Flux at the GitOps repository so it starts syncing
+// the cluster from it.
+func ConfigureGitOps(ctx context.Context, kubeconfigPath string, repo config.GitOpsConfig) error {
+	repo = repo.WithDefaults()
+	slog.Info("Configuring GitOps repository...", "url", repo.URL, "branch", repo.Branch, "path", repo.Path)
+
+	commands := [][]string{
+		{"flux", "create", "source", "git", "xstrapolate", "--url", repo.URL, "--branch", repo.Branch, "--interval", "1m", "--kubeconfig", kubeconfigPath},
+		{"flux", "create", "kustomization", "xstrapolate", "--source", "GitRepository/xstrapolate", "--path", repo.Path, "--prune=true", "--interval", "10m", "--kubeconfig", kubeconfigPath},
 	}
 
-	fmt.Println("✅ Flux installed successfully!")
-	fmt.Println("💡 To bootstrap a Git repository, run:")
-	fmt.Println("   flux bootstrap github --owner=<user> --repository=<repo> --path=clusters/my-cluster")
+	for _, cmd := range commands {
+		execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+		slog.Debug("Running", "command", execCmd.String())
+
+		output, err := execCmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to run command %v: %w\nOutput: %s", cmd, err, string(output))
+		}
+	}
 
+	slog.Info("✅ Flux is syncing from the GitOps repository")
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,214 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	fluxReleaseURL      = "https://api.github.com/repos/fluxcd/flux2/releases/latest"
+	crossplaneChartRepo = "https://charts.crossplane.io/stable"
+	// Crossplane resolves provider packages without a registry here
+	defaultPackageRegistry = "xpkg.upbound.io"
+)
+
+// Release versions such as v2.3.0 or 1.16.0; pre-releases are not offered
+var releasePattern = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.([0-9]+)$`)
+
+// semver is a parsed release version.
+type semver struct {
+	major, minor, patch int
+}
+
+func parseSemver(version string) (semver, bool) {
+	match := releasePattern.FindStringSubmatch(version)
+	if match == nil {
+		return semver{}, false
+	}
+	var v semver
+	v.major, _ = strconv.Atoi(match[1])
+	v.minor, _ = strconv.Atoi(match[2])
+	v.patch, _ = strconv.Atoi(match[3])
+	return v, true
+}
+
+func (v semver) less(other semver) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	if v.minor != other.minor {
+		return v.minor < other.minor
+	}
+	return v.patch < other.patch
+}
+
+// compareVersions orders two release versions; versions that do not parse
+// sort first.
+func compareVersions(a, b string) int {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	switch {
+	case !okA && !okB:
+		return strings.Compare(a, b)
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	case va.less(vb):
+		return -1
+	case vb.less(va):
+		return 1
+	}
+	return 0
+}
+
+// sortReleases drops pre-releases and sorts the rest, oldest first.
+func sortReleases(versions []string) []string {
+	var releases []string
+	for _, version := range versions {
+		if _, ok := parseSemver(version); ok {
+			releases = append(releases, version)
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return compareVersions(releases[i], releases[j]) < 0 })
+	return releases
+}
+
+// getJSON decodes the JSON body of a GET request. The response is returned
+// on HTTP errors too, so callers can answer authentication challenges.
+func getJSON(ctx context.Context, target, token string, v interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// latestFluxRelease returns the newest flux2 release.
+func latestFluxRelease(ctx context.Context) (string, error) {
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if _, err := getJSON(ctx, fluxReleaseURL, "", &release); err != nil {
+		return "", fmt.Errorf("failed to look up the latest Flux release: %w", err)
+	}
+	return release.TagName, nil
+}
+
+// crossplaneChartVersions lists the releases of the crossplane-stable Helm
+// chart, oldest first.
+func crossplaneChartVersions(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, crossplaneChartRepo+"/index.yaml", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Crossplane chart index: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read the Crossplane chart index: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Crossplane chart index: %w", err)
+	}
+
+	var index struct {
+		Entries map[string][]struct {
+			Version string `yaml:"version"`
+		} `yaml:"entries"`
+	}
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the Crossplane chart index: %w", err)
+	}
+
+	var versions []string
+	for _, entry := range index.Entries["crossplane"] {
+		versions = append(versions, entry.Version)
+	}
+	return sortReleases(versions), nil
+}
+
+// packageTags lists the release tags of a Crossplane package repository,
+// oldest first, using the anonymous token flow of OCI registries.
+func packageTags(ctx context.Context, repository string) ([]string, error) {
+	registry, path := defaultPackageRegistry, repository
+	if first, rest, ok := strings.Cut(repository, "/"); ok && strings.ContainsAny(first, ".:") {
+		registry, path = first, rest
+	}
+	tagsURL := fmt.Sprintf("https://%s/v2/%s/tags/list", registry, path)
+
+	var tags struct {
+		Tags []string `json:"tags"`
+	}
+	resp, err := getJSON(ctx, tagsURL, "", &tags)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		token, tokenErr := registryToken(ctx, resp.Header.Get("WWW-Authenticate"))
+		if tokenErr != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", repository, tokenErr)
+		}
+		_, err = getJSON(ctx, tagsURL, token, &tags)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", repository, err)
+	}
+	return sortReleases(tags.Tags), nil
+}
+
+// Matches the key="value" pairs of a WWW-Authenticate challenge
+var challengePattern = regexp.MustCompile(`([a-z]+)="([^"]*)"`)
+
+// registryToken gets an anonymous pull token for the challenge an OCI
+// registry answered with.
+func registryToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+	params := map[string]string{}
+	for _, match := range challengePattern.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if _, err := getJSON(ctx, params["realm"]+"?"+query.Encode(), "", &token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
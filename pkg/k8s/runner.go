@@ -0,0 +1,35 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Runner runs a shell script with kubectl, flux and helm pointed at a
+// cluster and returns its standard output. Scripts run on this machine for
+// clusters with a local kubeconfig, or on a node for clusters only reachable
+// through it.
+type Runner func(ctx context.Context, script []string) (string, error)
+
+// LocalRunner runs scripts on this machine against the cluster in
+// kubeconfigPath.
+func LocalRunner(kubeconfigPath string) Runner {
+	return func(ctx context.Context, script []string) (string, error) {
+		execCmd := exec.CommandContext(ctx, "bash", "-c", strings.Join(script, "\n"))
+		execCmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath)
+		slog.Debug("Running", "script", script)
+
+		var stderr bytes.Buffer
+		execCmd.Stderr = &stderr
+		output, err := execCmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run %q: %w\nOutput: %s", script[len(script)-1], err, strings.TrimSpace(stderr.String()))
+		}
+		return string(output), nil
+	}
+}
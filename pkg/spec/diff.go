@@ -0,0 +1,174 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drduker/xstrapolate/pkg/cloud"
+)
+
+// Plan is what 'cluster apply' has to do to reach the spec.
+type Plan struct {
+	// Create is set when the cluster does not exist yet
+	Create  bool     `json:"create" yaml:"create"`
+	Changes []Change `json:"changes,omitempty" yaml:"changes,omitempty"`
+}
+
+// Change is one difference between the spec and the running cluster.
+type Change struct {
+	Path string `json:"path" yaml:"path"`
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+	To   string `json:"to,omitempty" yaml:"to,omitempty"`
+	// Immutable changes need the cluster to be recreated
+	Immutable bool `json:"immutable,omitempty" yaml:"immutable,omitempty"`
+
+	// AddPool or ScalePool is set for node pool changes made in place
+	AddPool   *cloud.NodePool `json:"-" yaml:"-"`
+	ScalePool string          `json:"-" yaml:"-"`
+	Count     int32           `json:"-" yaml:"-"`
+}
+
+// Diff compares the spec with the current state of the cluster; current is
+// nil when the cluster does not exist. Network, bootstrap, GitOps and tag
+// settings only apply at create time and are not compared.
+func Diff(c *ClusterSpec, current *cloud.ClusterInfo) *Plan {
+	if current == nil {
+		return &Plan{Create: true}
+	}
+
+	s := c.Spec
+	plan := &Plan{}
+	immutable := func(path, from, to string) {
+		plan.Changes = append(plan.Changes, Change{Path: path, From: from, To: to, Immutable: true})
+	}
+
+	if s.Provider != current.Provider {
+		immutable("spec.provider", current.Provider, s.Provider)
+	}
+	if s.Type != current.Type {
+		immutable("spec.type", current.Type, s.Type)
+	}
+	if s.Region != "" && s.Region != current.Region {
+		immutable("spec.region", current.Region, s.Region)
+	}
+	if s.KubernetesVersion != "" && current.Version != "" && s.KubernetesVersion != current.Version {
+		immutable("spec.kubernetesVersion", current.Version, s.KubernetesVersion)
+	}
+
+	if len(s.NodePools) == 0 {
+		return plan
+	}
+
+	existing := map[string]cloud.NodePool{}
+	for _, pool := range current.NodePools {
+		existing[pool.Name] = pool
+	}
+	wanted := map[string]bool{}
+
+	for _, pool := range s.NodePools {
+		wanted[pool.Name] = true
+		path := fmt.Sprintf("spec.nodePools[%s]", pool.Name)
+
+		have, ok := existing[pool.Name]
+		if s.Type == "single-node" && len(current.NodePools) > 0 {
+			// The server's pool matches whatever the spec calls it; agents
+			// added by 'cluster scale' are left alone
+			have, ok = current.NodePools[0], true
+		}
+		if s.Type == "k3s" && pool.Name == k3sAgentPool && !ok && len(current.NodePools) > 0 {
+			// A cluster without agents lists no agents pool; agents run on
+			// the servers' instance type
+			have, ok = cloud.NodePool{Name: k3sAgentPool, InstanceType: current.NodePools[0].InstanceType}, true
+		}
+		if !ok {
+			add := cloud.NodePool{Name: pool.Name, InstanceType: pool.InstanceType, Count: pool.Count, Spot: pool.Spot}
+			plan.Changes = append(plan.Changes, Change{Path: path, To: describePool(add), AddPool: &add})
+			continue
+		}
+
+		if pool.InstanceType != "" && pool.InstanceType != have.InstanceType {
+			immutable(path+".instanceType", have.InstanceType, pool.InstanceType)
+		}
+		if pool.Spot != have.Spot {
+			immutable(path+".spot", fmt.Sprint(have.Spot), fmt.Sprint(pool.Spot))
+		}
+		if s.Type == "k3s" && pool.Name == k3sServerPool && pool.Count != have.Count {
+			// The etcd members are fixed when the cluster is created
+			immutable(path+".count", fmt.Sprint(have.Count), fmt.Sprint(pool.Count))
+			continue
+		}
+		if pool.Count != have.Count {
+			plan.Changes = append(plan.Changes, Change{
+				Path:      path + ".count",
+				From:      fmt.Sprint(have.Count),
+				To:        fmt.Sprint(pool.Count),
+				ScalePool: pool.Name,
+				Count:     pool.Count,
+			})
+		}
+	}
+
+	if s.Type != "single-node" {
+		for _, pool := range current.NodePools {
+			if !wanted[pool.Name] {
+				immutable(fmt.Sprintf("spec.nodePools[%s]", pool.Name), describePool(pool), "")
+			}
+		}
+	}
+
+	return plan
+}
+
+// Empty reports whether the cluster already matches the spec.
+func (p *Plan) Empty() bool {
+	return !p.Create && len(p.Changes) == 0
+}
+
+// Immutable returns the changes that cannot be made in place.
+func (p *Plan) Immutable() []Change {
+	var changes []Change
+	for _, change := range p.Changes {
+		if change.Immutable {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// String renders the plan for the terminal: + adds, ~ changes in place and
+// ! changes that need the cluster to be recreated.
+func (p *Plan) String() string {
+	if p.Create {
+		return "+ create cluster\n"
+	}
+	if len(p.Changes) == 0 {
+		return "No changes; the cluster matches the spec\n"
+	}
+
+	var b strings.Builder
+	for _, change := range p.Changes {
+		switch {
+		case change.Immutable && change.To == "":
+			fmt.Fprintf(&b, "! %s: %s (removing node pools is not supported)\n", change.Path, change.From)
+		case change.Immutable:
+			fmt.Fprintf(&b, "! %s: %s -> %s (cannot be changed in place)\n", change.Path, change.From, change.To)
+		case change.From == "":
+			fmt.Fprintf(&b, "+ %s: %s\n", change.Path, change.To)
+		default:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", change.Path, change.From, change.To)
+		}
+	}
+	return b.String()
+}
+
+func describePool(pool cloud.NodePool) string {
+	instanceType := pool.InstanceType
+	if instanceType == "" {
+		instanceType = "default instance type"
+	}
+	description := fmt.Sprintf("%d x %s", pool.Count, instanceType)
+	if pool.Spot {
+		description += " (spot)"
+	}
+	return description
+}
@@ -0,0 +1,202 @@
+package spec
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON schema of ClusterSpec files, e.g. for editors.
+func Schema() []byte {
+	return schemaJSON
+}
+
+// schema is the subset of JSON Schema that schema.json uses.
+type schema struct {
+	Type                 string             `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                interface{}        `json:"const"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MinItems             *int               `json:"minItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	pattern      *regexp.Regexp
+	additional   *schema
+	noAdditional bool
+}
+
+var clusterSchema = mustCompileSchema(schemaJSON)
+
+func mustCompileSchema(data []byte) *schema {
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic(fmt.Sprintf("invalid embedded schema: %v", err))
+	}
+	if err := s.compile(); err != nil {
+		panic(fmt.Sprintf("invalid embedded schema: %v", err))
+	}
+	return &s
+}
+
+func (s *schema) compile() error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = pattern
+	}
+
+	switch raw := strings.TrimSpace(string(s.AdditionalProperties)); {
+	case raw == "false":
+		s.noAdditional = true
+	case strings.HasPrefix(raw, "{"):
+		s.additional = &schema{}
+		if err := json.Unmarshal(s.AdditionalProperties, s.additional); err != nil {
+			return err
+		}
+	}
+
+	children := []*schema{s.Items, s.additional}
+	for _, property := range s.Properties {
+		children = append(children, property)
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if err := child.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSchema checks a decoded YAML document against the ClusterSpec
+// schema and returns one message per problem.
+func validateSchema(document interface{}) []string {
+	return clusterSchema.validate(document, "")
+}
+
+func (s *schema) validate(value interface{}, path string) []string {
+	at := path
+	if at == "" {
+		at = "(root)"
+	}
+
+	if s.Const != nil && !reflect.DeepEqual(value, s.Const) {
+		return []string{fmt.Sprintf("%s: must be %v", at, s.Const)}
+	}
+	if len(s.Enum) > 0 && !enumContains(s.Enum, value) {
+		return []string{fmt.Sprintf("%s: must be one of %v", at, s.Enum)}
+	}
+	if s.Type != "" && !hasType(value, s.Type) {
+		return []string{fmt.Sprintf("%s: must be of type %s", at, s.Type)}
+	}
+
+	var problems []string
+	switch v := value.(type) {
+	case string:
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			problems = append(problems, fmt.Sprintf("%s: must not be empty", at))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			problems = append(problems, fmt.Sprintf("%s: %q does not match %s", at, v, s.Pattern))
+		}
+	case int:
+		problems = append(problems, s.validateNumber(float64(v), at)...)
+	case float64:
+		problems = append(problems, s.validateNumber(v, at)...)
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			problems = append(problems, fmt.Sprintf("%s: must have at least %d item(s)", at, *s.MinItems))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				problems = append(problems, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required field %q", at, key))
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := joinPath(path, key)
+			switch property, ok := s.Properties[key]; {
+			case ok:
+				problems = append(problems, property.validate(v[key], child)...)
+			case s.additional != nil:
+				problems = append(problems, s.additional.validate(v[key], child)...)
+			case s.noAdditional:
+				problems = append(problems, fmt.Sprintf("%s: unknown field", child))
+			}
+		}
+	}
+	return problems
+}
+
+func (s *schema) validateNumber(v float64, at string) []string {
+	if s.Minimum != nil && v < *s.Minimum {
+		return []string{fmt.Sprintf("%s: must be at least %v", at, *s.Minimum)}
+	}
+	if s.Maximum != nil && v > *s.Maximum {
+		return []string{fmt.Sprintf("%s: must be at most %v", at, *s.Maximum)}
+	}
+	return nil
+}
+
+func hasType(value interface{}, typ string) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case int:
+		return typ == "integer" || typ == "number"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == float64(int64(v)))
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	default:
+		return typ == "null" && value == nil
+	}
+}
+
+func enumContains(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,327 @@
+// Package spec reads declarative ClusterSpec files for 'cluster apply'.
+package spec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/drduker/xstrapolate/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	APIVersion = "xstrapolate.io/v1alpha1"
+	Kind       = "Cluster"
+)
+
+// Node pools of k3s clusters, which map to their servers and agents
+const (
+	k3sServerPool = "servers"
+	k3sAgentPool  = "agents"
+)
+
+// ClusterSpec is the desired state of one cluster.
+type ClusterSpec struct {
+	APIVersion string   `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string   `json:"kind" yaml:"kind"`
+	Metadata   Metadata `json:"metadata" yaml:"metadata"`
+	Spec       Spec     `json:"spec" yaml:"spec"`
+}
+
+type Metadata struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+type Spec struct {
+	Provider string `json:"provider" yaml:"provider"`
+	Type     string `json:"type" yaml:"type"`
+	// Region is the AWS region or Azure location
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+	// Runtime picks kind, k3d or k3s for local clusters; empty means the
+	// first one installed
+	Runtime string `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	// KubernetesVersion is a minor version such as "1.29" for EKS and AKS,
+	// or a k3s release such as "v1.29.4+k3s1" for single-node and k3s
+	// clusters
+	KubernetesVersion string            `json:"kubernetesVersion,omitempty" yaml:"kubernetesVersion,omitempty"`
+	Network           Network           `json:"network,omitempty" yaml:"network,omitempty"`
+	NodePools         []NodePool        `json:"nodePools,omitempty" yaml:"nodePools,omitempty"`
+	Bootstrap         Bootstrap         `json:"bootstrap,omitempty" yaml:"bootstrap,omitempty"`
+	GitOps            GitOps            `json:"gitops,omitempty" yaml:"gitops,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+type Network struct {
+	VPCCIDR     string `json:"vpcCIDR,omitempty" yaml:"vpcCIDR,omitempty"`
+	ClusterCIDR string `json:"clusterCIDR,omitempty" yaml:"clusterCIDR,omitempty"`
+	ServiceCIDR string `json:"serviceCIDR,omitempty" yaml:"serviceCIDR,omitempty"`
+}
+
+type NodePool struct {
+	Name         string `json:"name" yaml:"name"`
+	InstanceType string `json:"instanceType,omitempty" yaml:"instanceType,omitempty"`
+	Count        int32  `json:"count" yaml:"count"`
+	Spot         bool   `json:"spot,omitempty" yaml:"spot,omitempty"`
+}
+
+type Bootstrap struct {
+	Packages        []string            `json:"packages,omitempty" yaml:"packages,omitempty"`
+	PreK3s          []string            `json:"preK3s,omitempty" yaml:"preK3s,omitempty"`
+	PostK3s         []string            `json:"postK3s,omitempty" yaml:"postK3s,omitempty"`
+	RegistryMirrors map[string][]string `json:"registryMirrors,omitempty" yaml:"registryMirrors,omitempty"`
+}
+
+type GitOps struct {
+	URL    string `json:"url" yaml:"url"`
+	Branch string `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// Load reads a ClusterSpec file and validates it against the JSON schema
+// and the rules the schema cannot express.
+func Load(path string) (*ClusterSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster spec: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a ClusterSpec document.
+func Parse(data []byte) (*ClusterSpec, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster spec: %w", err)
+	}
+	if problems := validateSchema(document); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid cluster spec:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	var spec ClusterSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to decode cluster spec: %w", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid cluster spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// validate checks the combinations of provider, type and settings.
+func (c *ClusterSpec) validate() error {
+	s := c.Spec
+
+	switch {
+	case s.Type == "eks" && s.Provider != "aws":
+		return fmt.Errorf("spec.type eks requires provider aws")
+	case s.Type == "aks" && s.Provider != "azure":
+		return fmt.Errorf("spec.type aks requires provider azure")
+	case s.Type == "k3s" && s.Provider != "aws":
+		return fmt.Errorf("spec.type k3s requires provider aws")
+	case s.Runtime != "" && s.Provider != "local":
+		return fmt.Errorf("spec.runtime only applies to provider local")
+	}
+
+	if s.Provider == "local" {
+		if err := validateLocal(s); err != nil {
+			return err
+		}
+	}
+
+	runsK3s := s.Type == "single-node" || s.Type == "k3s"
+	isK3sRelease := strings.Contains(s.KubernetesVersion, "+k3s")
+	if s.KubernetesVersion != "" && isK3sRelease != runsK3s {
+		if runsK3s {
+			return fmt.Errorf("spec.kubernetesVersion: %s clusters need a k3s release such as v1.29.4+k3s1", s.Type)
+		}
+		return fmt.Errorf("spec.kubernetesVersion: %s clusters need a minor version such as 1.29", s.Type)
+	}
+
+	if s.Type == "single-node" {
+		if len(s.NodePools) > 1 {
+			return fmt.Errorf("spec.nodePools: single-node clusters have one node pool")
+		}
+		if len(s.NodePools) == 1 && s.NodePools[0].Count != 1 {
+			return fmt.Errorf("spec.nodePools[0].count: single-node clusters have exactly one node")
+		}
+	}
+
+	if s.Type == "k3s" {
+		if err := validateK3sPools(s.NodePools); err != nil {
+			return err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, pool := range s.NodePools {
+		if seen[pool.Name] {
+			return fmt.Errorf("spec.nodePools: duplicate pool name %q", pool.Name)
+		}
+		seen[pool.Name] = true
+	}
+	return nil
+}
+
+// validateLocal checks a spec for a cluster in Docker on this machine,
+// which has one node of the runtime's image.
+func validateLocal(s Spec) error {
+	switch {
+	case s.Type != "single-node":
+		return fmt.Errorf("spec.type: local clusters are single-node, not %s", s.Type)
+	case s.Region != "":
+		return fmt.Errorf("spec.region does not apply to provider local")
+	case s.Runtime == "kind" && s.KubernetesVersion != "":
+		return fmt.Errorf("spec.kubernetesVersion: kind clusters use the kind node image; use runtime k3d or k3s to pick a k3s release")
+	}
+	for i, pool := range s.NodePools {
+		if pool.InstanceType != "" {
+			return fmt.Errorf("spec.nodePools[%d].instanceType does not apply to provider local", i)
+		}
+		if pool.Spot {
+			return fmt.Errorf("spec.nodePools[%d].spot does not apply to provider local", i)
+		}
+	}
+	return nil
+}
+
+// validateK3sPools checks the servers and agents pools of a k3s cluster,
+// which all run on one instance type.
+func validateK3sPools(pools []NodePool) error {
+	if len(pools) == 0 {
+		return nil
+	}
+
+	hasServers := false
+	instanceType := ""
+	for i, pool := range pools {
+		path := fmt.Sprintf("spec.nodePools[%d]", i)
+		switch pool.Name {
+		case k3sServerPool:
+			hasServers = true
+			if pool.Count != 1 && pool.Count != 3 {
+				return fmt.Errorf("%s.count: k3s clusters need 1 or 3 servers, not %d", path, pool.Count)
+			}
+		case k3sAgentPool:
+		default:
+			return fmt.Errorf("%s.name: k3s clusters have the %s and %s pools, not %q", path, k3sServerPool, k3sAgentPool, pool.Name)
+		}
+		if pool.Spot {
+			return fmt.Errorf("%s.spot: spot instances are only supported for single-node clusters", path)
+		}
+		if pool.InstanceType != "" {
+			if instanceType != "" && pool.InstanceType != instanceType {
+				return fmt.Errorf("%s.instanceType: the servers and agents of a k3s cluster share one instance type", path)
+			}
+			instanceType = pool.InstanceType
+		}
+	}
+	if !hasServers {
+		return fmt.Errorf("spec.nodePools: k3s clusters need a %s pool", k3sServerPool)
+	}
+	return nil
+}
+
+// ApplyTo overrides the loaded config with the settings of the spec, so the
+// cloud managers build exactly what the spec describes.
+func (c *ClusterSpec) ApplyTo(conf *config.Config) {
+	s := c.Spec
+
+	conf.Provider = s.Provider
+	if s.Region != "" {
+		switch s.Provider {
+		case "aws":
+			conf.Cloud.AWS.Region = s.Region
+		case "azure":
+			conf.Cloud.Azure.Location = s.Region
+		}
+	}
+	if s.Provider == "local" && s.Runtime != "" {
+		conf.Cloud.Local.Runtime = s.Runtime
+	}
+
+	if s.Network.VPCCIDR != "" {
+		conf.Network.VPCCIDR = s.Network.VPCCIDR
+	}
+	if s.Network.ClusterCIDR != "" {
+		conf.K3s.ClusterCIDR = s.Network.ClusterCIDR
+	}
+	if s.Network.ServiceCIDR != "" {
+		conf.K3s.ServiceCIDR = s.Network.ServiceCIDR
+	}
+
+	switch s.Type {
+	case "eks":
+		if s.KubernetesVersion != "" {
+			conf.Cloud.AWS.EKS.Version = s.KubernetesVersion
+		}
+		if len(s.NodePools) > 0 {
+			conf.Cloud.AWS.EKS.NodePools = nil
+			for _, pool := range s.NodePools {
+				conf.Cloud.AWS.EKS.NodePools = append(conf.Cloud.AWS.EKS.NodePools, config.NodePoolConfig{
+					Name:         pool.Name,
+					InstanceType: pool.InstanceType,
+					Count:        pool.Count,
+					Spot:         pool.Spot,
+				})
+			}
+		}
+	case "single-node":
+		if s.KubernetesVersion != "" {
+			conf.K3s.Version = s.KubernetesVersion
+		}
+		if len(s.NodePools) == 1 {
+			if s.NodePools[0].InstanceType != "" {
+				conf.Cloud.AWS.Instance.Type = s.NodePools[0].InstanceType
+			}
+			conf.Cloud.AWS.Instance.Spot.Enabled = s.NodePools[0].Spot
+		}
+	case "k3s":
+		if s.KubernetesVersion != "" {
+			conf.K3s.Version = s.KubernetesVersion
+		}
+		if len(s.NodePools) > 0 {
+			conf.K3s.Agents = 0
+		}
+		for _, pool := range s.NodePools {
+			switch pool.Name {
+			case k3sServerPool:
+				conf.K3s.Servers = pool.Count
+			case k3sAgentPool:
+				conf.K3s.Agents = pool.Count
+			}
+			if pool.InstanceType != "" {
+				conf.Cloud.AWS.Instance.Type = pool.InstanceType
+			}
+		}
+		conf.Cloud.AWS.Instance.Spot.Enabled = false
+	}
+
+	if len(s.Bootstrap.Packages) > 0 {
+		conf.Bootstrap.Packages = s.Bootstrap.Packages
+	}
+	if len(s.Bootstrap.PreK3s) > 0 {
+		conf.Bootstrap.PreK3s = s.Bootstrap.PreK3s
+	}
+	if len(s.Bootstrap.PostK3s) > 0 {
+		conf.Bootstrap.PostK3s = s.Bootstrap.PostK3s
+	}
+	if len(s.Bootstrap.RegistryMirrors) > 0 {
+		conf.Bootstrap.RegistryMirrors = s.Bootstrap.RegistryMirrors
+	}
+
+	if s.GitOps.URL != "" {
+		conf.GitOps = config.GitOpsConfig{URL: s.GitOps.URL, Branch: s.GitOps.Branch, Path: s.GitOps.Path}
+	}
+
+	if len(s.Tags) > 0 {
+		tags := make(map[string]string, len(conf.Tags)+len(s.Tags))
+		for key, value := range conf.Tags {
+			tags[key] = value
+		}
+		for key, value := range s.Tags {
+			tags[key] = value
+		}
+		conf.Tags = tags
+	}
+}